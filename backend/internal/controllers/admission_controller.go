@@ -0,0 +1,396 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"clinic-backend/internal/config"
+	"clinic-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var validDispositions = map[string]bool{
+	"Home":                 true,
+	"Transferred":          true,
+	"AgainstMedicalAdvice": true,
+	"Deceased":             true,
+	"Other":                true,
+}
+
+// admissionError is an admission, transfer or discharge the patient or room
+// cannot take in their current state.
+type admissionError string
+
+func (e admissionError) Error() string { return string(e) }
+
+// AdmitPatient admits a patient into an available room under the admitting
+// doctor, opening the Admission encounter the stay is documented in.
+func AdmitPatient(c *gin.Context) {
+	var input struct {
+		PatientID uint   `json:"patientId" binding:"required"`
+		DoctorID  uint   `json:"doctorId" binding:"required"`
+		RoomID    uint   `json:"roomId" binding:"required"`
+		Reason    string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var patient models.Patient
+	if err := config.DB.First(&patient, input.PatientID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Patient not found"})
+		return
+	}
+	var doctor models.Doctor
+	if err := config.DB.First(&doctor, input.DoctorID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Doctor not found"})
+		return
+	}
+
+	now := time.Now()
+	admission := models.Admission{
+		PatientID:  patient.ID,
+		DoctorID:   doctor.ID,
+		Reason:     strings.TrimSpace(input.Reason),
+		Status:     "Admitted",
+		AdmittedAt: now,
+		AdmittedBy: currentUserID(c),
+	}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// Lock the patient so they cannot be admitted twice at once
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&patient, patient.ID).Error; err != nil {
+			return err
+		}
+		var admitted int64
+		if err := tx.Model(&models.Admission{}).
+			Where("patient_id = ? AND status = ?", patient.ID, "Admitted").
+			Count(&admitted).Error; err != nil {
+			return err
+		}
+		if admitted > 0 {
+			return admissionError("Patient is already admitted")
+		}
+		var stay models.RoomStay
+		if err := tx.Preload("Room").Where("patient_id = ? AND ended_at IS NULL", patient.ID).First(&stay).Error; err == nil {
+			return admissionError("Patient already occupies room " + stay.Room.RoomNumber)
+		}
+
+		encounter := models.Encounter{
+			Type:           "Admission",
+			Status:         "Open",
+			PatientID:      patient.ID,
+			DoctorID:       doctor.ID,
+			ChiefComplaint: admission.Reason,
+			StartedAt:      now,
+		}
+		if err := tx.Create(&encounter).Error; err != nil {
+			return err
+		}
+		admission.EncounterID = encounter.ID
+
+		if err := tx.Create(&admission).Error; err != nil {
+			return err
+		}
+		admission.Reference = fmt.Sprintf("AD-%06d", admission.ID)
+		if err := tx.Model(&admission).Update("reference", admission.Reference).Error; err != nil {
+			return err
+		}
+
+		return occupyRoom(tx, input.RoomID, admission, "Admission")
+	})
+	if !respondAdmissionError(c, err, "Failed to admit patient") {
+		return
+	}
+
+	loadAdmission(&admission, admission.ID)
+	c.JSON(http.StatusCreated, admission)
+}
+
+// GetAdmissions lists admissions, the most recent first, optionally by
+// status, patient or admitting doctor.
+func GetAdmissions(c *gin.Context) {
+	query := config.DB.Preload("Patient").Preload("Doctor").
+		Preload("Stays", func(db *gorm.DB) *gorm.DB { return db.Order("started_at ASC") }).
+		Preload("Stays.Room").
+		Order("admitted_at DESC")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if patientID := c.Query("patientId"); patientID != "" {
+		query = query.Where("patient_id = ?", patientID)
+	}
+	if doctorID := c.Query("doctorId"); doctorID != "" {
+		query = query.Where("doctor_id = ?", doctorID)
+	}
+
+	var admissions []models.Admission
+	if err := query.Find(&admissions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch admissions"})
+		return
+	}
+	for i := range admissions {
+		setLengthOfStay(&admissions[i])
+	}
+
+	c.JSON(http.StatusOK, admissions)
+}
+
+func GetAdmissionByID(c *gin.Context) {
+	admission, ok := findAdmission(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, admission)
+}
+
+// GetPatientAdmissions returns a patient's admission history with the rooms
+// of each stay and the total length of stay.
+func GetPatientAdmissions(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid patient ID"})
+		return
+	}
+
+	var admissions []models.Admission
+	if err := config.DB.Preload("Doctor").
+		Preload("Stays", func(db *gorm.DB) *gorm.DB { return db.Order("started_at ASC") }).
+		Preload("Stays.Room").
+		Where("patient_id = ?", id).
+		Order("admitted_at DESC").
+		Find(&admissions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch admissions"})
+		return
+	}
+
+	totalDays := 0
+	for i := range admissions {
+		setLengthOfStay(&admissions[i])
+		totalDays += admissions[i].LengthOfStay
+	}
+
+	c.JSON(http.StatusOK, gin.H{"admissions": admissions, "totalDays": totalDays})
+}
+
+// TransferPatient moves an admitted patient to another available room. The
+// stay in the old room ends and the stay in the new one starts at the same
+// moment, so each room's days are charged at its own rate.
+func TransferPatient(c *gin.Context) {
+	admission, ok := findAdmission(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		RoomID uint   `json:"roomId" binding:"required"`
+		Reason string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockAdmitted(tx, &admission); err != nil {
+			return err
+		}
+
+		var current models.RoomStay
+		err := tx.Where("admission_id = ? AND ended_at IS NULL", admission.ID).First(&current).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil {
+			if current.RoomID == input.RoomID {
+				return admissionError("Patient is already in this room")
+			}
+			if err := vacateRoom(tx, current.RoomID); err != nil {
+				return err
+			}
+		}
+
+		reason := strings.TrimSpace(input.Reason)
+		if reason == "" {
+			reason = "Transfer"
+		}
+		return occupyRoom(tx, input.RoomID, admission, reason)
+	})
+	if !respondAdmissionError(c, err, "Failed to transfer patient") {
+		return
+	}
+
+	loadAdmission(&admission, admission.ID)
+	c.JSON(http.StatusOK, admission)
+}
+
+// DischargePatient ends an admission with the discharge summary and where
+// the patient went. The room is freed, its last days are charged and the
+// Admission encounter is closed.
+func DischargePatient(c *gin.Context) {
+	admission, ok := findAdmission(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Disposition string `json:"disposition" binding:"required"`
+		Summary     string `json:"summary" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !validDispositions[input.Disposition] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid disposition. Must be: Home, Transferred, AgainstMedicalAdvice, Deceased or Other"})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockAdmitted(tx, &admission); err != nil {
+			return err
+		}
+
+		var current models.RoomStay
+		if err := tx.Where("admission_id = ? AND ended_at IS NULL", admission.ID).First(&current).Error; err == nil {
+			if err := vacateRoom(tx, current.RoomID); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		dischargedBy := currentUserID(c)
+		admission.Status = "Discharged"
+		admission.DischargedAt = &now
+		admission.DischargedBy = &dischargedBy
+		admission.Disposition = input.Disposition
+		admission.DischargeSummary = strings.TrimSpace(input.Summary)
+		if err := tx.Model(&admission).Updates(map[string]interface{}{
+			"status":            admission.Status,
+			"discharged_at":     admission.DischargedAt,
+			"discharged_by":     admission.DischargedBy,
+			"disposition":       admission.Disposition,
+			"discharge_summary": admission.DischargeSummary,
+		}).Error; err != nil {
+			return err
+		}
+
+		var encounter models.Encounter
+		if err := tx.First(&encounter, admission.EncounterID).Error; err != nil {
+			return err
+		}
+		return closeEncounter(tx, &encounter)
+	})
+	if !respondAdmissionError(c, err, "Failed to discharge patient") {
+		return
+	}
+
+	loadAdmission(&admission, admission.ID)
+	c.JSON(http.StatusOK, admission)
+}
+
+// occupyRoom moves the admitted patient into the room, which must be
+// available, and starts the stay there.
+func occupyRoom(tx *gorm.DB, roomID uint, admission models.Admission, reason string) error {
+	var room models.Room
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&room, roomID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return admissionError("Room not found")
+		}
+		return err
+	}
+	if room.Status != "Available" || room.PatientID != nil {
+		return admissionError(fmt.Sprintf("Room %s is not available", room.RoomNumber))
+	}
+
+	if err := tx.Model(&room).Updates(map[string]interface{}{
+		"patient_id": admission.PatientID,
+		"status":     "Occupied",
+	}).Error; err != nil {
+		return err
+	}
+	return startRoomStay(tx, room.ID, admission.PatientID, &admission.ID, reason)
+}
+
+// vacateRoom ends the stay in the room, charging its remaining days, and
+// makes the room available again.
+func vacateRoom(tx *gorm.DB, roomID uint) error {
+	if err := endRoomStay(tx, roomID); err != nil {
+		return err
+	}
+	return tx.Model(&models.Room{}).Where("id = ?", roomID).Updates(map[string]interface{}{
+		"patient_id": nil,
+		"status":     "Available",
+	}).Error
+}
+
+// lockAdmitted reloads the admission locked for the rest of the transaction
+// and checks the patient is still admitted.
+func lockAdmitted(tx *gorm.DB, admission *models.Admission) error {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(admission, admission.ID).Error; err != nil {
+		return err
+	}
+	if admission.Status != "Admitted" {
+		return admissionError("Patient has already been discharged")
+	}
+	return nil
+}
+
+// respondAdmissionError answers a failed admission, transfer or discharge and
+// reports whether there was no error to answer.
+func respondAdmissionError(c *gin.Context, err error, message string) bool {
+	var rejected admissionError
+	switch {
+	case errors.As(err, &rejected):
+		c.JSON(http.StatusConflict, gin.H{"error": rejected.Error()})
+		return false
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+		return false
+	}
+	return true
+}
+
+// setLengthOfStay counts the midnights between admission and discharge, or
+// now for patients still admitted.
+func setLengthOfStay(admission *models.Admission) {
+	end := time.Now()
+	if admission.DischargedAt != nil {
+		end = *admission.DischargedAt
+	}
+	admission.LengthOfStay = daysBetween(admission.AdmittedAt, end)
+}
+
+func loadAdmission(admission *models.Admission, id uint) error {
+	err := config.DB.Preload("Patient").Preload("Doctor").
+		Preload("Stays", func(db *gorm.DB) *gorm.DB { return db.Order("started_at ASC") }).
+		Preload("Stays.Room").
+		First(admission, id).Error
+	setLengthOfStay(admission)
+	return err
+}
+
+func findAdmission(c *gin.Context) (models.Admission, bool) {
+	var admission models.Admission
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid admission ID"})
+		return admission, false
+	}
+
+	if err := loadAdmission(&admission, uint(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Admission not found"})
+		return admission, false
+	}
+
+	return admission, true
+}
@@ -0,0 +1,220 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"clinic-backend/internal/config"
+	"clinic-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+var validAllergyCategories = map[string]bool{
+	"Drug":        true,
+	"Food":        true,
+	"Environment": true,
+	"Other":       true,
+}
+
+var validAllergySeverities = map[string]bool{
+	"Mild":     true,
+	"Moderate": true,
+	"Severe":   true,
+}
+
+var validVerificationStatuses = map[string]bool{
+	"Unconfirmed": true,
+	"Confirmed":   true,
+	"Refuted":     true,
+}
+
+// GetPatientAllergies lists the patient's allergies. Refuted entries are
+// left out unless ?all=true.
+func GetPatientAllergies(c *gin.Context) {
+	patient, ok := findPatient(c)
+	if !ok {
+		return
+	}
+
+	var allergies []models.Allergy
+	query := config.DB.Where("patient_id = ?", patient.ID).Order("substance ASC")
+	if c.Query("all") != "true" {
+		query = query.Where("verification_status <> ?", "Refuted")
+	}
+
+	if err := query.Find(&allergies).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch allergies"})
+		return
+	}
+
+	c.JSON(http.StatusOK, allergies)
+}
+
+func CreatePatientAllergy(c *gin.Context) {
+	patient, ok := findPatient(c)
+	if !ok {
+		return
+	}
+
+	var allergy models.Allergy
+	if err := c.ShouldBindJSON(&allergy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	allergy.ID = 0
+	allergy.PatientID = patient.ID
+	allergy.RecordedBy = currentUserID(c)
+
+	if err := validateAllergy(&allergy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if allergyRecorded(allergy) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Allergy to " + allergy.Substance + " is already recorded"})
+		return
+	}
+
+	if err := config.DB.Create(&allergy).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record allergy"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, allergy)
+}
+
+func UpdatePatientAllergy(c *gin.Context) {
+	allergy, ok := findPatientAllergy(c)
+	if !ok {
+		return
+	}
+
+	allergyID, patientID, recordedBy := allergy.ID, allergy.PatientID, allergy.RecordedBy
+	if err := c.ShouldBindJSON(&allergy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	allergy.ID = allergyID
+	allergy.PatientID = patientID
+	allergy.RecordedBy = recordedBy
+
+	if err := validateAllergy(&allergy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if allergyRecorded(allergy) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Allergy to " + allergy.Substance + " is already recorded"})
+		return
+	}
+
+	if err := config.DB.Save(&allergy).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update allergy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, allergy)
+}
+
+func DeletePatientAllergy(c *gin.Context) {
+	allergy, ok := findPatientAllergy(c)
+	if !ok {
+		return
+	}
+
+	if err := config.DB.Delete(&allergy).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete allergy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Allergy deleted successfully"})
+}
+
+func validateAllergy(allergy *models.Allergy) error {
+	allergy.Substance = strings.TrimSpace(allergy.Substance)
+	if allergy.Substance == "" {
+		return errors.New("Substance is required")
+	}
+
+	if allergy.Category == "" {
+		allergy.Category = "Drug"
+	}
+	if !validAllergyCategories[allergy.Category] {
+		return errors.New("Invalid allergy category. Must be: Drug, Food, Environment, or Other")
+	}
+
+	if allergy.Severity == "" {
+		allergy.Severity = "Moderate"
+	}
+	if !validAllergySeverities[allergy.Severity] {
+		return errors.New("Invalid severity. Must be: Mild, Moderate, or Severe")
+	}
+
+	if allergy.VerificationStatus == "" {
+		allergy.VerificationStatus = "Unconfirmed"
+	}
+	if !validVerificationStatuses[allergy.VerificationStatus] {
+		return errors.New("Invalid verification status. Must be: Unconfirmed, Confirmed, or Refuted")
+	}
+	return nil
+}
+
+// allergyRecorded reports whether the patient already has another,
+// unrefuted allergy to the same substance. The same substance is kept once
+// per patient.
+func allergyRecorded(allergy models.Allergy) bool {
+	if allergy.VerificationStatus == "Refuted" {
+		return false
+	}
+
+	var existing int64
+	config.DB.Model(&models.Allergy{}).
+		Where("patient_id = ? AND id <> ? AND LOWER(substance) = LOWER(?) AND verification_status <> ?",
+			allergy.PatientID, allergy.ID, allergy.Substance, "Refuted").
+		Count(&existing)
+	return existing > 0
+}
+
+func findPatientAllergy(c *gin.Context) (models.Allergy, bool) {
+	var allergy models.Allergy
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid patient ID"})
+		return allergy, false
+	}
+	allergyID, err := strconv.ParseUint(c.Param("allergyId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid allergy ID"})
+		return allergy, false
+	}
+
+	if err := config.DB.Where("patient_id = ?", id).First(&allergy, allergyID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Allergy not found"})
+		return allergy, false
+	}
+
+	return allergy, true
+}
+
+// findPatient loads the patient named by the :id route parameter, writing the
+// error response when it is missing.
+func findPatient(c *gin.Context) (models.Patient, bool) {
+	var patient models.Patient
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid patient ID"})
+		return patient, false
+	}
+
+	if err := config.DB.First(&patient, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Patient not found"})
+		return patient, false
+	}
+
+	return patient, true
+}
@@ -1,13 +1,18 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
+	"sort"
 	"strconv"
+	"time"
 
 	"clinic-backend/internal/config"
 	"clinic-backend/internal/models"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 func CreateAppointment(c *gin.Context) {
@@ -24,11 +29,35 @@ func CreateAppointment(c *gin.Context) {
 		return
 	}
 
-	if err := config.DB.Create(&a).Error; err != nil {
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockDoctorSchedules(tx, a.DoctorID); err != nil {
+			return err
+		}
+		if a.Status != "Cancelled" {
+			if _, taken := findAppointmentConflict(tx, a.DoctorID, a.Date, a.Time); taken {
+				return errScheduleConflict
+			}
+			// Slots offered to the waitlist stay held until the offer runs out
+			if slotHeld(tx, a.DoctorID, a.Date, a.Time, a.PatientID) {
+				return errSlotHeld
+			}
+		}
+		return tx.Create(&a).Error
+	})
+	switch {
+	case errors.Is(err, errScheduleConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": "Doctor already has an appointment in this slot"})
+		return
+	case errors.Is(err, errSlotHeld):
+		c.JSON(http.StatusConflict, gin.H{"error": "Slot is held for a waitlisted patient"})
+		return
+	case err != nil:
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create appointment"})
 		return
 	}
 
+	notifyAppointment(a, "Confirmation")
+
 	c.JSON(http.StatusCreated, a)
 }
 
@@ -96,20 +125,70 @@ func UpdateAppointment(c *gin.Context) {
 		return
 	}
 
+	previous := appointment
+
 	if err := c.ShouldBindJSON(&appointment); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	appointment.Sequence = previous.Sequence + 1
 
-	if err := config.DB.Save(&appointment).Error; err != nil {
+	slotMoved := previous.DoctorID != appointment.DoctorID || !previous.Date.Equal(appointment.Date) || previous.Time != appointment.Time
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		// A booking moved into a slot, or back from cancelled, takes the slot
+		// again, so it must be free the way a new booking must be
+		if appointment.Status != "Cancelled" && (slotMoved || previous.Status == "Cancelled") {
+			if err := lockDoctorSchedules(tx, previous.DoctorID, appointment.DoctorID); err != nil {
+				return err
+			}
+			if _, taken := findAppointmentConflict(tx, appointment.DoctorID, appointment.Date, appointment.Time, appointment.ID); taken {
+				return errScheduleConflict
+			}
+			if slotHeld(tx, appointment.DoctorID, appointment.Date, appointment.Time, appointment.PatientID) {
+				return errSlotHeld
+			}
+		}
+		return tx.Save(&appointment).Error
+	})
+	switch {
+	case errors.Is(err, errScheduleConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": "Doctor already has an appointment in this slot"})
+		return
+	case errors.Is(err, errSlotHeld):
+		c.JSON(http.StatusConflict, gin.H{"error": "Slot is held for a waitlisted patient"})
+		return
+	case err != nil:
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update appointment"})
 		return
 	}
 
+	// Offer the old slot to the waitlist if it was cancelled or moved
+	if previous.Status == "Scheduled" && (appointment.Status == "Cancelled" || slotMoved) {
+		offerFreedSlot(previous.DoctorID, previous.Date, previous.Time)
+	}
+
+	// Completing the visit closes its encounter, opening one if nobody did
+	if previous.Status != "Completed" && appointment.Status == "Completed" {
+		if encounter, err := openAppointmentEncounter(config.DB, appointment); err == nil {
+			closeEncounter(config.DB, &encounter)
+		}
+		chargeAppointment(appointment)
+	}
+
+	// Let the patient know about cancellations and new times
+	if previous.Status != "Cancelled" && appointment.Status == "Cancelled" {
+		notifyAppointment(appointment, "Cancellation")
+	} else if appointment.Status == "Scheduled" && slotMoved {
+		notifyAppointment(appointment, "Confirmation")
+	}
+
 	config.DB.Preload("Patient").Preload("Doctor").First(&appointment, appointment.ID)
 	c.JSON(http.StatusOK, appointment)
 }
 
+// DeleteAppointment cancels the appointment rather than deleting it, so
+// subscribed calendars pick up the cancellation instead of keeping a stale
+// entry forever.
 func DeleteAppointment(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
@@ -117,10 +196,63 @@ func DeleteAppointment(c *gin.Context) {
 		return
 	}
 
-	if err := config.DB.Delete(&models.Appointment{}, id).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete appointment"})
+	var appointment models.Appointment
+	if err := config.DB.First(&appointment, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Appointment not found"})
 		return
 	}
+	if appointment.Status == "Cancelled" {
+		c.JSON(http.StatusOK, gin.H{"message": "Appointment cancelled successfully"})
+		return
+	}
+
+	scheduled := appointment.Status == "Scheduled"
+	if err := config.DB.Model(&appointment).Updates(cancelledAppointment()).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel appointment"})
+		return
+	}
+
+	// Offer the freed slot to the waitlist
+	if scheduled {
+		offerFreedSlot(appointment.DoctorID, appointment.Date, appointment.Time)
+		notifyAppointment(appointment, "Cancellation")
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Appointment cancelled successfully"})
+}
+
+// findAppointmentConflict returns an active appointment that already holds the
+// doctor's slot on the given day, ignoring the appointment IDs in exclude.
+// Run it inside the transaction that books the slot, after
+// lockDoctorSchedules, so the answer still holds when the booking commits.
+func findAppointmentConflict(db *gorm.DB, doctorID uint, date time.Time, slot string, exclude ...uint) (*models.Appointment, bool) {
+	startOfDay := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	endOfDay := startOfDay.Add(24 * time.Hour)
+
+	query := db.
+		Where("doctor_id = ? AND date >= ? AND date < ? AND time = ?", doctorID, startOfDay, endOfDay, slot).
+		Where("status <> ?", "Cancelled")
+	if len(exclude) > 0 {
+		query = query.Where("id NOT IN ?", exclude)
+	}
+
+	var existing models.Appointment
+	if err := query.First(&existing).Error; err != nil {
+		return nil, false
+	}
+	return &existing, true
+}
+
+// lockDoctorSchedules locks the doctors' rows until the transaction ends, so
+// bookings for the same doctor are checked for conflicts one at a time.
+// Doctors are locked in ID order to avoid deadlocks.
+func lockDoctorSchedules(tx *gorm.DB, doctorIDs ...uint) error {
+	ids := append([]uint(nil), doctorIDs...)
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
 
-	c.JSON(http.StatusOK, gin.H{"message": "Appointment deleted successfully"})
+	var doctors []models.Doctor
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ?", ids).
+		Order("id ASC").
+		Find(&doctors).Error
 }
@@ -0,0 +1,598 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"clinic-backend/internal/config"
+	"clinic-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxSeriesOccurrences caps how many appointments a single series may generate.
+const maxSeriesOccurrences = 200
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// errScheduleConflict aborts a booking transaction when a slot is taken.
+var errScheduleConflict = errors.New("Slot is already booked")
+
+// errSlotHeld aborts a booking of a slot held for a waitlist offer.
+var errSlotHeld = errors.New("Slot is held for a waitlisted patient")
+
+type seriesConflict struct {
+	Date          time.Time `json:"date"`
+	Time          string    `json:"time"`
+	AppointmentID uint      `json:"appointmentId,omitempty"`
+	Held          bool      `json:"held,omitempty"` // for a waitlist offer
+}
+
+func CreateAppointmentSeries(c *gin.Context) {
+	var series models.AppointmentSeries
+	if err := c.ShouldBindJSON(&series); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	series.ID = 0
+	series.Appointments = nil
+
+	// Verify patient exists
+	var patient models.Patient
+	if err := config.DB.First(&patient, series.PatientID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Patient not found"})
+		return
+	}
+
+	// Verify doctor exists
+	var doctor models.Doctor
+	if err := config.DB.First(&doctor, series.DoctorID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Doctor not found"})
+		return
+	}
+
+	if series.Interval <= 0 {
+		series.Interval = 1
+	}
+	series.Status = "Active"
+
+	dates, err := expandSeries(series)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var conflicts []seriesConflict
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		// Every occurrence must be free before anything is booked
+		if err := lockDoctorSchedules(tx, series.DoctorID); err != nil {
+			return err
+		}
+		for _, date := range dates {
+			if existing, found := findAppointmentConflict(tx, series.DoctorID, date, series.Time); found {
+				conflicts = append(conflicts, seriesConflict{Date: date, Time: series.Time, AppointmentID: existing.ID})
+			} else if slotHeld(tx, series.DoctorID, date, series.Time, series.PatientID) {
+				conflicts = append(conflicts, seriesConflict{Date: date, Time: series.Time, Held: true})
+			}
+		}
+		if len(conflicts) > 0 {
+			return errScheduleConflict
+		}
+
+		if err := tx.Create(&series).Error; err != nil {
+			return err
+		}
+		for _, date := range dates {
+			appointment := models.Appointment{
+				PatientID: series.PatientID,
+				DoctorID:  series.DoctorID,
+				Date:      date,
+				Time:      series.Time,
+				Status:    "Scheduled",
+				Notes:     series.Notes,
+				SeriesID:  &series.ID,
+			}
+			if err := tx.Create(&appointment).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errScheduleConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Series conflicts with existing appointments", "conflicts": conflicts})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create appointment series"})
+		return
+	}
+
+	loadSeries(&series, series.ID)
+
+	// One confirmation for the series rather than one per visit
+	notifySeriesConfirmation(series)
+
+	c.JSON(http.StatusCreated, series)
+}
+
+func GetAppointmentSeries(c *gin.Context) {
+	var series []models.AppointmentSeries
+	query := config.DB.Preload("Patient").Preload("Doctor").Order("start_date DESC")
+
+	// Filter by patient if provided
+	if patientID := c.Query("patientId"); patientID != "" {
+		query = query.Where("patient_id = ?", patientID)
+	}
+
+	// Filter by doctor if provided
+	if doctorID := c.Query("doctorId"); doctorID != "" {
+		query = query.Where("doctor_id = ?", doctorID)
+	}
+
+	if err := query.Find(&series).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch appointment series"})
+		return
+	}
+
+	c.JSON(http.StatusOK, series)
+}
+
+func GetAppointmentSeriesByID(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid series ID"})
+		return
+	}
+
+	var series models.AppointmentSeries
+	if err := loadSeries(&series, uint(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Appointment series not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, series)
+}
+
+// UpdateSeriesOccurrence edits one occurrence (scope=single) or the occurrence
+// and every later one (scope=following). Editing the following occurrences
+// splits the series so earlier visits keep their original details.
+func UpdateSeriesOccurrence(c *gin.Context) {
+	series, occurrence, ok := findSeriesOccurrence(c)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		DoctorID *uint      `json:"doctorId"`
+		Date     *time.Time `json:"date"`
+		Time     *string    `json:"time"`
+		Notes    *string    `json:"notes"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	scope := c.DefaultQuery("scope", "single")
+	if scope != "single" && scope != "following" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scope. Must be: single or following"})
+		return
+	}
+	if scope == "following" && body.Date != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Date can only be changed for a single occurrence"})
+		return
+	}
+	if scope == "single" && occurrence.Status != "Scheduled" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only scheduled occurrences can be changed"})
+		return
+	}
+
+	if body.DoctorID != nil {
+		var doctor models.Doctor
+		if err := config.DB.First(&doctor, *body.DoctorID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Doctor not found"})
+			return
+		}
+	}
+
+	targets := []models.Appointment{occurrence}
+	if scope == "following" {
+		targets = nil
+		if err := config.DB.
+			Where("series_id = ? AND date >= ? AND status = ?", series.ID, occurrence.Date, "Scheduled").
+			Order("date ASC").
+			Find(&targets).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch series occurrences"})
+			return
+		}
+	}
+
+	previous := append([]models.Appointment(nil), targets...)
+	targetIDs := make([]uint, 0, len(targets))
+	for i := range targets {
+		targetIDs = append(targetIDs, targets[i].ID)
+		if body.DoctorID != nil {
+			targets[i].DoctorID = *body.DoctorID
+		}
+		if body.Date != nil {
+			targets[i].Date = *body.Date
+		}
+		if body.Time != nil {
+			targets[i].Time = *body.Time
+		}
+		if body.Notes != nil {
+			targets[i].Notes = *body.Notes
+		}
+		targets[i].Sequence++
+	}
+
+	var conflicts []seriesConflict
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		doctorIDs := make([]uint, 0, len(targets))
+		for _, target := range targets {
+			doctorIDs = append(doctorIDs, target.DoctorID)
+		}
+		if err := lockDoctorSchedules(tx, doctorIDs...); err != nil {
+			return err
+		}
+		for _, target := range targets {
+			if existing, found := findAppointmentConflict(tx, target.DoctorID, target.Date, target.Time, targetIDs...); found {
+				conflicts = append(conflicts, seriesConflict{Date: target.Date, Time: target.Time, AppointmentID: existing.ID})
+			} else if slotHeld(tx, target.DoctorID, target.Date, target.Time, target.PatientID) {
+				conflicts = append(conflicts, seriesConflict{Date: target.Date, Time: target.Time, Held: true})
+			}
+		}
+		if len(conflicts) > 0 {
+			return errScheduleConflict
+		}
+
+		if scope == "following" {
+			next, err := splitSeries(tx, &series, occurrence, len(targets))
+			if err != nil {
+				return err
+			}
+			if body.DoctorID != nil {
+				next.DoctorID = *body.DoctorID
+			}
+			if body.Time != nil {
+				next.Time = *body.Time
+			}
+			if body.Notes != nil {
+				next.Notes = *body.Notes
+			}
+			if err := tx.Save(next).Error; err != nil {
+				return err
+			}
+			for i := range targets {
+				targets[i].SeriesID = &next.ID
+			}
+		}
+		for i := range targets {
+			if err := tx.Save(&targets[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errScheduleConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Changes conflict with existing appointments", "conflicts": conflicts})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update series occurrence"})
+		return
+	}
+
+	// Offer slots the occurrences moved away from to the waitlist
+	for i, old := range previous {
+		moved := old.DoctorID != targets[i].DoctorID || !old.Date.Equal(targets[i].Date) || old.Time != targets[i].Time
+		if moved && old.Status == "Scheduled" {
+			offerFreedSlot(old.DoctorID, old.Date, old.Time)
+			notifyAppointment(targets[i], "Confirmation")
+		}
+	}
+
+	c.JSON(http.StatusOK, targets)
+}
+
+// CancelSeriesOccurrence cancels one occurrence (scope=single) or the
+// occurrence and every later one (scope=following), which ends the series there.
+func CancelSeriesOccurrence(c *gin.Context) {
+	series, occurrence, ok := findSeriesOccurrence(c)
+	if !ok {
+		return
+	}
+
+	scope := c.DefaultQuery("scope", "single")
+	if scope != "single" && scope != "following" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scope. Must be: single or following"})
+		return
+	}
+	if scope == "single" && occurrence.Status != "Scheduled" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only scheduled occurrences can be cancelled"})
+		return
+	}
+
+	freed := []models.Appointment{occurrence}
+	if scope == "following" {
+		freed = nil
+		config.DB.Where("series_id = ? AND date >= ? AND status = ?", series.ID, occurrence.Date, "Scheduled").Find(&freed)
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if scope == "single" {
+			return tx.Model(&occurrence).Updates(cancelledAppointment()).Error
+		}
+
+		if err := tx.Model(&models.Appointment{}).
+			Where("series_id = ? AND date >= ? AND status = ?", series.ID, occurrence.Date, "Scheduled").
+			Updates(cancelledAppointment()).Error; err != nil {
+			return err
+		}
+
+		var earlier int64
+		tx.Model(&models.Appointment{}).
+			Where("series_id = ? AND date < ?", series.ID, occurrence.Date).
+			Count(&earlier)
+		if earlier == 0 {
+			series.Status = "Cancelled"
+		} else {
+			until := dayBefore(occurrence.Date)
+			series.Until = &until
+			series.Count = int(earlier)
+		}
+		return tx.Save(&series).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel series occurrence"})
+		return
+	}
+
+	for _, appointment := range freed {
+		if appointment.Status == "Scheduled" {
+			offerFreedSlot(appointment.DoctorID, appointment.Date, appointment.Time)
+			notifyAppointment(appointment, "Cancellation")
+		}
+	}
+
+	loadSeries(&series, series.ID)
+	c.JSON(http.StatusOK, series)
+}
+
+// CancelAppointmentSeries cancels every remaining scheduled occurrence.
+func CancelAppointmentSeries(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid series ID"})
+		return
+	}
+
+	var series models.AppointmentSeries
+	if err := config.DB.First(&series, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Appointment series not found"})
+		return
+	}
+
+	today := time.Now()
+	startOfDay := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, today.Location())
+
+	var freed []models.Appointment
+	config.DB.Where("series_id = ? AND date >= ? AND status = ?", series.ID, startOfDay, "Scheduled").Find(&freed)
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Appointment{}).
+			Where("series_id = ? AND date >= ? AND status = ?", series.ID, startOfDay, "Scheduled").
+			Updates(cancelledAppointment()).Error; err != nil {
+			return err
+		}
+		return tx.Model(&series).Update("status", "Cancelled").Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel appointment series"})
+		return
+	}
+
+	for _, appointment := range freed {
+		offerFreedSlot(appointment.DoctorID, appointment.Date, appointment.Time)
+		notifyAppointment(appointment, "Cancellation")
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Appointment series cancelled successfully"})
+}
+
+func loadSeries(series *models.AppointmentSeries, id uint) error {
+	return config.DB.Preload("Patient").Preload("Doctor").
+		Preload("Appointments", func(db *gorm.DB) *gorm.DB {
+			return db.Order("date ASC")
+		}).
+		First(series, id).Error
+}
+
+// findSeriesOccurrence loads the series and occurrence named in the URL,
+// writing the error response itself when either is missing.
+func findSeriesOccurrence(c *gin.Context) (models.AppointmentSeries, models.Appointment, bool) {
+	var series models.AppointmentSeries
+	var occurrence models.Appointment
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid series ID"})
+		return series, occurrence, false
+	}
+	appointmentID, err := strconv.ParseUint(c.Param("appointmentId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid appointment ID"})
+		return series, occurrence, false
+	}
+
+	if err := config.DB.First(&series, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Appointment series not found"})
+		return series, occurrence, false
+	}
+	if err := config.DB.Where("series_id = ?", series.ID).First(&occurrence, appointmentID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Appointment not found in series"})
+		return series, occurrence, false
+	}
+
+	return series, occurrence, true
+}
+
+// splitSeries ends series just before occurrence and returns a new series
+// that carries the same rule from occurrence onwards. If occurrence is the
+// first one in the series, the series itself is returned unchanged.
+func splitSeries(tx *gorm.DB, series *models.AppointmentSeries, occurrence models.Appointment, remaining int) (*models.AppointmentSeries, error) {
+	var earlier int64
+	if err := tx.Model(&models.Appointment{}).
+		Where("series_id = ? AND date < ?", series.ID, occurrence.Date).
+		Count(&earlier).Error; err != nil {
+		return nil, err
+	}
+	if earlier == 0 {
+		return series, nil
+	}
+
+	next := *series
+	next.ID = 0
+	next.StartDate = occurrence.Date
+	next.Appointments = nil
+	if next.Count > 0 {
+		next.Count = remaining
+	}
+	if err := tx.Create(&next).Error; err != nil {
+		return nil, err
+	}
+
+	until := dayBefore(occurrence.Date)
+	series.Until = &until
+	series.Count = int(earlier)
+	if err := tx.Save(series).Error; err != nil {
+		return nil, err
+	}
+
+	return &next, nil
+}
+
+// expandSeries returns the occurrence dates produced by the series rule.
+func expandSeries(series models.AppointmentSeries) ([]time.Time, error) {
+	if series.Until == nil && series.Count <= 0 {
+		return nil, errors.New("Series requires either an until date or a count")
+	}
+	if series.Count > maxSeriesOccurrences {
+		return nil, fmt.Errorf("Series cannot have more than %d occurrences", maxSeriesOccurrences)
+	}
+
+	interval := series.Interval
+	if interval <= 0 {
+		interval = 1
+	}
+
+	var until time.Time
+	if series.Until != nil {
+		u := *series.Until
+		until = time.Date(u.Year(), u.Month(), u.Day(), 23, 59, 59, 0, u.Location())
+		if until.Before(series.StartDate) {
+			return nil, errors.New("Series until date is before its start date")
+		}
+	}
+
+	var dates []time.Time
+	// add records the date and reports whether expansion should continue
+	add := func(date time.Time) (bool, error) {
+		if series.Until != nil && date.After(until) {
+			return false, nil
+		}
+		if len(dates) == maxSeriesOccurrences {
+			return false, fmt.Errorf("Series cannot have more than %d occurrences", maxSeriesOccurrences)
+		}
+		dates = append(dates, date)
+		return series.Count <= 0 || len(dates) < series.Count, nil
+	}
+
+	switch series.Frequency {
+	case "Daily":
+		for date := series.StartDate; ; date = date.AddDate(0, 0, interval) {
+			more, err := add(date)
+			if err != nil {
+				return nil, err
+			}
+			if !more {
+				return dates, nil
+			}
+		}
+	case "Weekly":
+		days, err := parseByDay(series.ByDay)
+		if err != nil {
+			return nil, err
+		}
+		if len(days) == 0 {
+			days = []time.Weekday{series.StartDate.Weekday()}
+		}
+		weekStart := series.StartDate.AddDate(0, 0, -int(series.StartDate.Weekday()))
+		for week := weekStart; ; week = week.AddDate(0, 0, 7*interval) {
+			for _, day := range days {
+				date := week.AddDate(0, 0, int(day))
+				if date.Before(series.StartDate) {
+					continue
+				}
+				more, err := add(date)
+				if err != nil {
+					return nil, err
+				}
+				if !more {
+					return dates, nil
+				}
+			}
+		}
+	default:
+		return nil, errors.New("Invalid frequency. Must be: Daily or Weekly")
+	}
+}
+
+// parseByDay turns an RRULE BYDAY list such as "MO,WE,FR" into sorted weekdays.
+func parseByDay(byDay string) ([]time.Weekday, error) {
+	var days []time.Weekday
+	seen := map[time.Weekday]bool{}
+	for _, code := range strings.Split(byDay, ",") {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if code == "" {
+			continue
+		}
+		day, ok := weekdayCodes[code]
+		if !ok {
+			return nil, fmt.Errorf("Invalid weekday code %q", code)
+		}
+		if !seen[day] {
+			seen[day] = true
+			days = append(days, day)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i] < days[j] })
+	return days, nil
+}
+
+func dayBefore(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location()).AddDate(0, 0, -1)
+}
+
+// cancelledAppointment is the column update that cancels appointments while
+// bumping their iCalendar sequence so subscribed calendars pick it up.
+func cancelledAppointment() map[string]interface{} {
+	return map[string]interface{}{
+		"status":   "Cancelled",
+		"sequence": gorm.Expr("sequence + 1"),
+	}
+}
@@ -30,10 +30,11 @@ func Register(c *gin.Context) {
 		"admin":        true,
 		"doctor":       true,
 		"receptionist": true,
+		"pharmacist":   true,
 		"patient":      true,
 	}
 	if !validRoles[user.Role] {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role. Must be: admin, doctor, receptionist, or patient"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role. Must be: admin, doctor, receptionist, pharmacist, or patient"})
 		return
 	}
 
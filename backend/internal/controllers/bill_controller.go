@@ -1,16 +1,39 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"clinic-backend/internal/config"
 	"clinic-backend/internal/models"
+	"clinic-backend/internal/money"
 
 	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+var hundred = decimal.NewFromInt(100)
+
+// errBillBelowPaid is returned when changed items would bring a bill's total
+// below what has already been paid.
+var errBillBelowPaid = errors.New("Bill total cannot be less than the amount already paid; refund first")
+
+// billSources are the kinds of record a bill item can charge for
+var billSources = map[string]bool{
+	"Appointment": true,
+	"Procedure":   true,
+	"RoomStay":    true,
+	"Dispense":    true,
+	"Other":       true,
+}
+
 func CreateBill(c *gin.Context) {
 	var bill models.Bill
 	if err := c.ShouldBindJSON(&bill); err != nil {
@@ -25,26 +48,52 @@ func CreateBill(c *gin.Context) {
 		return
 	}
 
-	// Set payment date if status is Paid
-	if bill.Status == "Paid" && bill.PaymentDate == nil {
-		now := time.Now()
-		bill.PaymentDate = &now
+	// Verify encounter belongs to the patient if provided
+	if bill.EncounterID != nil {
+		var encounter models.Encounter
+		if err := config.DB.First(&encounter, *bill.EncounterID).Error; err != nil || encounter.PatientID != bill.PatientID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Encounter not found for patient"})
+			return
+		}
+	}
+
+	// Bills go to the patient's active policy unless another is named
+	if bill.PolicyID != nil {
+		var policy models.PatientPolicy
+		if err := config.DB.First(&policy, *bill.PolicyID).Error; err != nil || policy.PatientID != bill.PatientID || policy.Status != "Active" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Active policy not found for patient"})
+			return
+		}
+	}
+
+	currency, err := billCurrency(bill.Currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	bill.Currency = currency.Code
+
+	if err := prepareBillItems(bill.PatientID, bill.Currency, bill.Items); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	if err := config.DB.Create(&bill).Error; err != nil {
+	if err := config.DB.Transaction(func(tx *gorm.DB) error {
+		return issueBill(tx, &bill, currency)
+	}); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create bill"})
 		return
 	}
 
 	// Load relations
-	config.DB.Preload("Patient").First(&bill, bill.ID)
+	config.DB.Preload("Patient").Preload("Items").First(&bill, bill.ID)
 
 	c.JSON(http.StatusCreated, bill)
 }
 
 func GetBills(c *gin.Context) {
 	var bills []models.Bill
-	query := config.DB.Preload("Patient").Order("created_at DESC")
+	query := config.DB.Preload("Patient").Preload("Items").Order("created_at DESC")
 
 	// Filter by patient if provided
 	if patientID := c.Query("patientId"); patientID != "" {
@@ -56,6 +105,15 @@ func GetBills(c *gin.Context) {
 		query = query.Where("status = ?", status)
 	}
 
+	if invoiceNumber := c.Query("invoiceNumber"); invoiceNumber != "" {
+		query = query.Where("invoice_number = ?", invoiceNumber)
+	}
+
+	// Filter by encounter if provided
+	if encounterID := c.Query("encounterId"); encounterID != "" {
+		query = query.Where("encounter_id = ?", encounterID)
+	}
+
 	if err := query.Find(&bills).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bills"})
 		return
@@ -72,14 +130,26 @@ func GetBillByID(c *gin.Context) {
 	}
 
 	var bill models.Bill
-	if err := config.DB.Preload("Patient").First(&bill, id).Error; err != nil {
+	if err := config.DB.Preload("Patient").Preload("Items").Preload("Payments", func(db *gorm.DB) *gorm.DB {
+		return db.Order("received_at ASC, id ASC")
+	}).First(&bill, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Bill not found"})
 		return
 	}
 
+	if wantsPDF(c) {
+		renderInvoicePDF(c, bill)
+		return
+	}
+
 	c.JSON(http.StatusOK, bill)
 }
 
+// UpdateBill changes a bill's details. Items sent with the request replace
+// the existing ones, which is only allowed until the bill is settled, written
+// off or claimed from the insurer; totals and the insurance split are always
+// recomputed. The status follows from the payments ledger and cannot be set
+// here.
 func UpdateBill(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
@@ -88,31 +158,113 @@ func UpdateBill(c *gin.Context) {
 	}
 
 	var bill models.Bill
-	if err := config.DB.First(&bill, id).Error; err != nil {
+	if err := config.DB.Preload("Items").First(&bill, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Bill not found"})
 		return
 	}
 
-	if err := c.ShouldBindJSON(&bill); err != nil {
+	var input struct {
+		DueDate     *time.Time         `json:"dueDate"`
+		Description *string            `json:"description"`
+		Items       *[]models.BillItem `json:"items"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Set payment date if status changed to Paid
-	if bill.Status == "Paid" && bill.PaymentDate == nil {
-		now := time.Now()
-		bill.PaymentDate = &now
+	if bill.Status == "Void" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Void bills cannot be changed"})
+		return
+	}
+	if input.Items != nil {
+		if bill.Status == "Paid" || bill.Status == "WrittenOff" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Items of a " + bill.Status + " bill cannot be changed"})
+			return
+		}
+		if err := prepareBillItems(bill.PatientID, bill.Currency, *input.Items); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		bill.Items = *input.Items
+	}
+	if input.DueDate != nil {
+		bill.DueDate = input.DueDate
+	}
+	if input.Description != nil {
+		bill.Description = *input.Description
 	}
+	currency, err := billCurrency(bill.Currency)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// Bills from before itemized billing keep the amount they were issued for
+	if input.Items != nil || len(bill.Items) > 0 {
+		priceBill(&bill, currency.Rule())
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		// Payments taken meanwhile must not be lost or overpaid
+		var locked models.Bill
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, bill.ID).Error; err != nil {
+			return err
+		}
 
-	if err := config.DB.Save(&bill).Error; err != nil {
+		if input.Items != nil {
+			if claimSubmitted(tx, bill.ID) {
+				return errClaimSubmitted
+			}
+			if err := applyCoverage(tx, &bill, currency.Rule()); err != nil {
+				return err
+			}
+			// Nothing has come from the insurer before the claim is submitted
+			if bill.PatientAmount.LessThan(locked.AmountPaid) {
+				return errBillBelowPaid
+			}
+		}
+		if bill.Amount.LessThan(locked.AmountPaid) {
+			return errBillBelowPaid
+		}
+
+		if input.Items != nil {
+			if err := tx.Where("bill_id = ?", bill.ID).Delete(&models.BillItem{}).Error; err != nil {
+				return err
+			}
+			for i := range bill.Items {
+				bill.Items[i].ID = 0
+				bill.Items[i].BillID = bill.ID
+			}
+			if len(bill.Items) > 0 {
+				if err := tx.Create(&bill.Items).Error; err != nil {
+					return err
+				}
+			}
+		}
+		if err := tx.Omit("Items", "Patient", "Payments", "AmountPaid", "Balance", "PatientBalance", "PayerBalance", "Status", "PaymentDate").Save(&bill).Error; err != nil {
+			return err
+		}
+		if err := syncClaim(tx, &bill); err != nil {
+			return err
+		}
+		return refreshBillBalance(tx, &bill)
+	})
+	if errors.Is(err, errBillBelowPaid) || errors.Is(err, errClaimSubmitted) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update bill"})
 		return
 	}
 
-	config.DB.Preload("Patient").First(&bill, bill.ID)
+	config.DB.Preload("Patient").Preload("Items").First(&bill, bill.ID)
 	c.JSON(http.StatusOK, bill)
 }
 
+// DeleteBill voids a bill issued in error, with an optional ?reason=. The
+// bill and its items are kept so invoice numbers stay gap-free; it no longer
+// counts as owed and what it charged for can be billed again.
 func DeleteBill(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
@@ -120,10 +272,222 @@ func DeleteBill(c *gin.Context) {
 		return
 	}
 
-	if err := config.DB.Delete(&models.Bill{}, id).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete bill"})
+	var bill models.Bill
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&bill, id).Error; err != nil {
+			return err
+		}
+		if bill.Status == "Void" {
+			return nil
+		}
+
+		// Money taken against a bill must stay on record. Payments lock the
+		// bill, and claims are locked here so a batch being submitted is
+		// waited for.
+		var payments int64
+		if err := tx.Model(&models.Payment{}).Where("bill_id = ?", id).Count(&payments).Error; err != nil {
+			return err
+		}
+		if payments > 0 {
+			return paymentError("Bill has payments and cannot be voided; write it off instead")
+		}
+		var claims []models.Claim
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("bill_id = ?", id).Find(&claims).Error; err != nil {
+			return err
+		}
+		if claimSubmitted(tx, uint(id)) {
+			return paymentError("Bill has been claimed from the insurer and cannot be voided")
+		}
+
+		if err := tx.Model(&models.Dispense{}).Where("bill_id = ?", id).Update("bill_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.RoomStay{}).Where("bill_id = ?", id).Update("bill_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("bill_id = ? AND status = ?", id, "Pending").Delete(&models.Claim{}).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		bill.Status = "Void"
+		bill.VoidedAt = &now
+		bill.VoidReason = strings.TrimSpace(c.Query("reason"))
+		bill.Balance, bill.PatientBalance, bill.PayerBalance = decimal.Zero, decimal.Zero, decimal.Zero
+		return tx.Model(&bill).Updates(map[string]interface{}{
+			"status":          bill.Status,
+			"voided_at":       bill.VoidedAt,
+			"void_reason":     bill.VoidReason,
+			"balance":         bill.Balance,
+			"patient_balance": bill.PatientBalance,
+			"payer_balance":   bill.PayerBalance,
+		}).Error
+	})
+	var rejected paymentError
+	if errors.As(err, &rejected) {
+		c.JSON(http.StatusConflict, gin.H{"error": rejected.Error()})
+		return
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bill not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to void bill"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Bill deleted successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Bill voided successfully"})
+}
+
+// prepareBillItems validates the items of a bill for the patient and checks
+// that every linked source exists and concerns that patient. Items naming a
+// catalog service are priced from the catalog rather than from the input.
+func prepareBillItems(patientID uint, currency string, items []models.BillItem) error {
+	if len(items) == 0 {
+		return errors.New("A bill needs at least one item")
+	}
+
+	for i := range items {
+		item := &items[i]
+		item.ID, item.BillID = 0, 0
+		if item.ServiceID != nil {
+			if err := applyCatalogPrice(item, currency); err != nil {
+				return err
+			}
+		}
+		item.Service = strings.TrimSpace(item.Service)
+		if item.SourceType == "" {
+			item.SourceType = "Other"
+		}
+
+		switch {
+		case item.Service == "":
+			return errors.New("Every item needs a service")
+		case !billSources[item.SourceType]:
+			return fmt.Errorf("Unknown item source %q", item.SourceType)
+		case !item.Quantity.IsPositive():
+			return fmt.Errorf("Quantity of %s must be positive", item.Service)
+		case item.UnitPrice.IsNegative():
+			return fmt.Errorf("Unit price of %s cannot be negative", item.Service)
+		case item.TaxRate.IsNegative() || item.TaxRate.GreaterThan(hundred):
+			return fmt.Errorf("Tax rate of %s must be between 0 and 100", item.Service)
+		case item.Discount.IsNegative() || item.Discount.GreaterThan(item.UnitPrice.Mul(item.Quantity)):
+			return fmt.Errorf("Discount on %s cannot exceed the line amount", item.Service)
+		}
+
+		if item.SourceID == nil {
+			continue
+		}
+		if err := checkBillSource(patientID, item.SourceType, *item.SourceID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyCatalogPrice fills in the item from its catalog service at today's
+// price. A discount given with the item is kept.
+func applyCatalogPrice(item *models.BillItem, currency string) error {
+	var service models.Service
+	if err := config.DB.First(&service, *item.ServiceID).Error; err != nil || service.Discontinued {
+		return errors.New("Service not found in the catalog")
+	}
+
+	priced, err := catalogItem(service, currency, time.Now())
+	if err != nil {
+		return err
+	}
+	if item.Service == "" {
+		item.Service = priced.Service
+	}
+	item.UnitPrice = priced.UnitPrice
+	item.TaxRate = priced.TaxRate
+	if item.SourceType == "" && service.Category == "Procedure" {
+		item.SourceType = "Procedure"
+	}
+	return nil
+}
+
+func checkBillSource(patientID uint, sourceType string, sourceID uint) error {
+	var owner uint
+	switch sourceType {
+	case "Appointment":
+		var appointment models.Appointment
+		if err := config.DB.First(&appointment, sourceID).Error; err != nil {
+			return errors.New("Appointment not found")
+		}
+		owner = appointment.PatientID
+	case "Procedure":
+		// Procedures are documented as medical records, e.g. operative notes
+		var record models.MedicalRecord
+		if err := config.DB.First(&record, sourceID).Error; err != nil {
+			return errors.New("Procedure record not found")
+		}
+		owner = record.PatientID
+	case "RoomStay":
+		var stay models.RoomStay
+		if err := config.DB.First(&stay, sourceID).Error; err != nil {
+			return errors.New("Room stay not found")
+		}
+		owner = stay.PatientID
+	case "Dispense":
+		var dispense models.Dispense
+		if err := config.DB.First(&dispense, sourceID).Error; err != nil {
+			return errors.New("Dispense not found")
+		}
+		owner = dispense.PatientID
+	default:
+		return nil
+	}
+
+	if owner != patientID {
+		return fmt.Errorf("%s %d does not belong to the patient", sourceType, sourceID)
+	}
+	return nil
+}
+
+// priceBill computes each item's amounts and the bill totals. Every line
+// amount is rounded by the currency's rule and the totals are exact sums of
+// the rounded lines. The discount comes off the line before tax is applied.
+func priceBill(bill *models.Bill, rule money.Rule) {
+	bill.Subtotal, bill.DiscountTotal, bill.TaxTotal = decimal.Zero, decimal.Zero, decimal.Zero
+
+	for i := range bill.Items {
+		item := &bill.Items[i]
+		item.Subtotal = rule.Round(item.UnitPrice.Mul(item.Quantity))
+		item.Discount = rule.Round(item.Discount)
+		item.TaxAmount = rule.Round(item.Subtotal.Sub(item.Discount).Mul(item.TaxRate).Div(hundred))
+		item.Total = item.Subtotal.Sub(item.Discount).Add(item.TaxAmount)
+
+		bill.Subtotal = bill.Subtotal.Add(item.Subtotal)
+		bill.DiscountTotal = bill.DiscountTotal.Add(item.Discount)
+		bill.TaxTotal = bill.TaxTotal.Add(item.TaxAmount)
+	}
+
+	bill.Amount = bill.Subtotal.Sub(bill.DiscountTotal).Add(bill.TaxTotal)
+}
+
+// openBill sets up the balances, due date and status of a new bill. Bills are
+// due BILL_DUE_DAYS (default 30) after issue unless a due date is given.
+func openBill(bill *models.Bill) {
+	now := time.Now()
+	if bill.DueDate == nil {
+		days, err := strconv.Atoi(os.Getenv("BILL_DUE_DAYS"))
+		if err != nil || days <= 0 {
+			days = 30
+		}
+		due := now.AddDate(0, 0, days)
+		bill.DueDate = &due
+	}
+
+	bill.Payments = nil
+	bill.Status = ""
+	bill.PaymentDate = nil
+	bill.WriteOffReason = ""
+	bill.AmountPaid = decimal.Zero
+	bill.Balance = bill.Amount
+	bill.PatientBalance = bill.PatientAmount
+	bill.PayerBalance = bill.PayerAmount
+	settleBillStatus(bill, now)
 }
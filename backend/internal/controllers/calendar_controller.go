@@ -0,0 +1,172 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"clinic-backend/internal/config"
+	"clinic-backend/internal/ical"
+	"clinic-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// appointmentDuration is the calendar length given to each appointment slot.
+const appointmentDuration = 30 * time.Minute
+
+// RotateDoctorCalendarToken issues a new private feed token for a doctor,
+// which invalidates any previously shared subscription URL.
+func RotateDoctorCalendarToken(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid doctor ID"})
+		return
+	}
+
+	// Doctors may only manage their own feed
+	userRole, _ := c.Get("userRole")
+	userID, _ := c.Get("userID")
+	if userRole == "doctor" && userID != uint(id) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
+	var doctor models.Doctor
+	if err := config.DB.First(&doctor, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Doctor not found"})
+		return
+	}
+
+	token, err := newCalendarToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate calendar token"})
+		return
+	}
+
+	if err := config.DB.Model(&doctor).Update("calendar_token", token).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save calendar token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"feedUrl": publicBaseURL() + "/calendar/feeds/" + token + ".ics"})
+}
+
+// GetDoctorCalendarFeed serves a doctor's appointments as an iCalendar
+// subscription. The token in the URL is the only credential.
+func GetDoctorCalendarFeed(c *gin.Context) {
+	token := strings.TrimSuffix(c.Param("token"), ".ics")
+	if token == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendar not found"})
+		return
+	}
+
+	var doctor models.Doctor
+	if err := config.DB.Where("calendar_token = ?", token).First(&doctor).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendar not found"})
+		return
+	}
+
+	// Recent history plus everything upcoming
+	since := time.Now().AddDate(0, 0, -30)
+
+	var appointments []models.Appointment
+	if err := config.DB.Preload("Patient").
+		Where("doctor_id = ? AND date >= ?", doctor.ID, since).
+		Order("date ASC").
+		Find(&appointments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch appointments"})
+		return
+	}
+
+	calendar := ical.Calendar{Name: "Appointments - " + doctor.Name}
+	for _, a := range appointments {
+		calendar.Events = append(calendar.Events, appointmentEvent(a))
+	}
+
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(calendar.String()))
+}
+
+// GetAppointmentICS downloads a single appointment as an .ics file.
+func GetAppointmentICS(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid appointment ID"})
+		return
+	}
+
+	var appointment models.Appointment
+	if err := config.DB.Preload("Patient").Preload("Doctor").First(&appointment, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Appointment not found"})
+		return
+	}
+
+	calendar := ical.Calendar{
+		Method: "PUBLISH",
+		Events: []ical.Event{appointmentEvent(appointment)},
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="appointment-%d.ics"`, appointment.ID))
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(calendar.String()))
+}
+
+func appointmentEvent(a models.Appointment) ical.Event {
+	event := ical.Event{
+		UID:          appointmentUID(a.ID),
+		Sequence:     a.Sequence,
+		Summary:      "Appointment",
+		Description:  a.Notes,
+		Status:       "CONFIRMED",
+		Created:      a.CreatedAt,
+		LastModified: a.UpdatedAt,
+	}
+	if a.Patient.Name != "" {
+		event.Summary = "Appointment: " + a.Patient.Name
+	}
+	if a.Doctor.Name != "" {
+		event.Location = "Dr. " + a.Doctor.Name
+	}
+
+	switch a.Status {
+	case "Cancelled":
+		event.Status = "CANCELLED"
+	case "Scheduled":
+		event.Status = "CONFIRMED"
+	}
+
+	if start, ok := a.ScheduledAt(); ok {
+		event.Start = start
+		event.End = start.Add(appointmentDuration)
+		event.Floating = true
+	} else {
+		// No usable time of day, show it as an all-day entry
+		event.Start = start
+		event.End = start.AddDate(0, 0, 1)
+		event.AllDay = true
+	}
+
+	return event
+}
+
+// appointmentUID is stable for the lifetime of the appointment so calendar
+// clients update the existing entry instead of adding a new one.
+func appointmentUID(id uint) string {
+	domain := os.Getenv("CALENDAR_DOMAIN")
+	if domain == "" {
+		domain = "clinic-backend"
+	}
+	return fmt.Sprintf("appointment-%d@%s", id, domain)
+}
+
+func newCalendarToken() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,458 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"clinic-backend/internal/config"
+	"clinic-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OpenCashierSession starts the caller's shift at the cash desk with the
+// float counted into the drawer. A cashier has one open session at a time.
+func OpenCashierSession(c *gin.Context) {
+	var input struct {
+		OpeningFloat decimal.Decimal `json:"openingFloat"`
+		Currency     string          `json:"currency"`
+		Notes        string          `json:"notes"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.OpeningFloat.IsNegative() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Opening float cannot be negative"})
+		return
+	}
+	currency, err := billCurrency(input.Currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !input.OpeningFloat.Equal(input.OpeningFloat.Round(currency.Decimals)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s amounts have at most %d decimals", currency.Code, currency.Decimals)})
+		return
+	}
+
+	now := time.Now()
+	session := models.CashierSession{
+		CashierID:    currentUserID(c),
+		Currency:     currency.Code,
+		OpeningFloat: input.OpeningFloat,
+		Status:       "Open",
+		OpenedAt:     now,
+		Notes:        strings.TrimSpace(input.Notes),
+	}
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		closed, err := dayClosed(tx, now)
+		if err != nil {
+			return err
+		}
+		if closed {
+			return paymentError("Today's payments are already closed")
+		}
+		// Lock the cashier so two sessions cannot be opened at once.
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&models.User{}, session.CashierID).Error; err != nil {
+			return err
+		}
+		var open int64
+		if err := tx.Model(&models.CashierSession{}).
+			Where("cashier_id = ? AND status = ?", session.CashierID, "Open").
+			Count(&open).Error; err != nil {
+			return err
+		}
+		if open > 0 {
+			return paymentError("Close your open cashier session first")
+		}
+
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+		session.Reference = fmt.Sprintf("SH-%06d", session.ID)
+		return tx.Model(&session).Update("reference", session.Reference).Error
+	})
+	var rejected paymentError
+	switch {
+	case errors.As(err, &rejected):
+		c.JSON(http.StatusConflict, gin.H{"error": rejected.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open cashier session"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, session)
+}
+
+// GetCashierSessions lists cashier sessions opened in a date range (the last
+// 30 days by default), optionally by status. Receptionists see their own
+// sessions; admins see everyone's and can filter by cashier.
+func GetCashierSessions(c *gin.Context) {
+	from, to, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+
+	query := config.DB.Preload("Cashier").
+		Where("opened_at >= ? AND opened_at < ?", from, to).
+		Order("opened_at DESC, id DESC")
+	if role, _ := c.Get("userRole"); role != "admin" {
+		query = query.Where("cashier_id = ?", currentUserID(c))
+	} else if cashierID := c.Query("cashierId"); cashierID != "" {
+		query = query.Where("cashier_id = ?", cashierID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var sessions []models.CashierSession
+	if err := query.Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cashier sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// GetCurrentCashierSession returns the caller's open session with what it has
+// taken so far.
+func GetCurrentCashierSession(c *gin.Context) {
+	var session models.CashierSession
+	if err := config.DB.Preload("Cashier").
+		Where("cashier_id = ? AND status = ?", currentUserID(c), "Open").
+		First(&session).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No open cashier session"})
+		return
+	}
+
+	respondCashierSession(c, session)
+}
+
+// GetCashierSessionByID returns a session with its totals and ledger entries.
+func GetCashierSessionByID(c *gin.Context) {
+	session, ok := findCashierSession(c)
+	if !ok {
+		return
+	}
+
+	respondCashierSession(c, session)
+}
+
+// CloseCashierSession ends a shift with the cash counted in the drawer. The
+// expected cash is the opening float plus the net cash taken in the session;
+// the variance is what was counted less what was expected.
+func CloseCashierSession(c *gin.Context) {
+	session, ok := findCashierSession(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		CountedCash *decimal.Decimal `json:"countedCash" binding:"required"`
+		Notes       string           `json:"notes"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.CountedCash.IsNegative() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Counted cash cannot be negative"})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// Payments lock the session too, so none slips in while counting
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&session, session.ID).Error; err != nil {
+			return err
+		}
+		if session.Status != "Open" {
+			return paymentError("Cashier session is already closed")
+		}
+
+		var taken decimal.Decimal
+		if err := tx.Model(&models.Payment{}).
+			Select("COALESCE(SUM(amount), 0)").
+			Where("session_id = ? AND method = ? AND currency = ?", session.ID, "Cash", session.Currency).
+			Scan(&taken).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		closedBy := currentUserID(c)
+		expected := session.OpeningFloat.Add(taken)
+		variance := input.CountedCash.Sub(expected)
+		session.Status = "Closed"
+		session.ClosedAt = &now
+		session.ClosedBy = &closedBy
+		session.ExpectedCash = &expected
+		session.CountedCash = input.CountedCash
+		session.Variance = &variance
+		if notes := strings.TrimSpace(input.Notes); notes != "" {
+			session.Notes = strings.TrimSpace(session.Notes + "\n" + notes)
+		}
+		return tx.Model(&session).Updates(map[string]interface{}{
+			"status":        session.Status,
+			"closed_at":     session.ClosedAt,
+			"closed_by":     session.ClosedBy,
+			"expected_cash": session.ExpectedCash,
+			"counted_cash":  session.CountedCash,
+			"variance":      session.Variance,
+			"notes":         session.Notes,
+		}).Error
+	})
+	var rejected paymentError
+	switch {
+	case errors.As(err, &rejected):
+		c.JSON(http.StatusConflict, gin.H{"error": rejected.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to close cashier session"})
+		return
+	}
+
+	respondCashierSession(c, session)
+}
+
+// CloseDay closes the payments ledger for a day (today by default) once all
+// cashier sessions opened by then are closed. The day's entries are totalled
+// by method and currency, and nothing can be recorded on the day afterwards.
+func CloseDay(c *gin.Context) {
+	var input struct {
+		Date  string `json:"date"` // YYYY-MM-DD
+		Notes string `json:"notes"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	day := startOfToday()
+	if input.Date != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", input.Date, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date. Use YYYY-MM-DD"})
+			return
+		}
+		if parsed.After(day) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only days up to today can be closed"})
+			return
+		}
+		day = parsed
+	}
+	next := day.AddDate(0, 0, 1)
+
+	dayClose := models.DailyClose{
+		Date:     day,
+		Notes:    strings.TrimSpace(input.Notes),
+		ClosedBy: currentUserID(c),
+	}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// Wait for the entries being recorded on the day and keep new ones
+		// out until the close is in.
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?, ?)", dayLockClass, dayLockKey(day)).Error; err != nil {
+			return err
+		}
+		closed, err := dayClosed(tx, day)
+		if err != nil {
+			return err
+		}
+		if closed {
+			return paymentError("Payments of " + day.Format("02 Jan 2006") + " are already closed")
+		}
+
+		var open []models.CashierSession
+		if err := tx.Where("status = ? AND opened_at < ?", "Open", next).Find(&open).Error; err != nil {
+			return err
+		}
+		if len(open) > 0 {
+			references := make([]string, len(open))
+			for i, session := range open {
+				references[i] = session.Reference
+			}
+			return paymentError("Close these cashier sessions first: " + strings.Join(references, ", "))
+		}
+
+		var sessions int64
+		if err := tx.Model(&models.CashierSession{}).
+			Where("opened_at >= ? AND opened_at < ?", day, next).
+			Count(&sessions).Error; err != nil {
+			return err
+		}
+		dayClose.Sessions = int(sessions)
+
+		if err := tx.Model(&models.Payment{}).
+			Select("method, currency, COUNT(*) AS entries, "+
+				"COALESCE(SUM(amount) FILTER (WHERE amount > 0), 0) AS received, "+
+				"COALESCE(-SUM(amount) FILTER (WHERE amount < 0), 0) AS returned, "+
+				"SUM(amount) AS net").
+			Where("received_at >= ? AND received_at < ?", day, next).
+			Group("method, currency").
+			Order("method ASC, currency ASC").
+			Scan(&dayClose.Lines).Error; err != nil {
+			return err
+		}
+		for _, line := range dayClose.Lines {
+			dayClose.Payments += line.Entries
+		}
+
+		return tx.Create(&dayClose).Error
+	})
+	var rejected paymentError
+	switch {
+	case errors.As(err, &rejected):
+		c.JSON(http.StatusConflict, gin.H{"error": rejected.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to close the day"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, dayClose)
+}
+
+// GetDailyCloses lists the days closed in a date range, the last 30 days by
+// default.
+func GetDailyCloses(c *gin.Context) {
+	from, to, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+
+	var closes []models.DailyClose
+	if err := config.DB.Preload("Lines").
+		Where("date >= ? AND date < ?", from, to).
+		Order("date DESC").
+		Find(&closes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch daily closes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, closes)
+}
+
+// GetDailyCloseByID returns the close report of a day: its totals and the
+// cashier sessions opened that day with their cash variances.
+func GetDailyCloseByID(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid daily close ID"})
+		return
+	}
+
+	var dayClose models.DailyClose
+	if err := config.DB.Preload("Lines").First(&dayClose, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Daily close not found"})
+		return
+	}
+
+	var sessions []models.CashierSession
+	config.DB.Preload("Cashier").
+		Where("opened_at >= ? AND opened_at < ?", dayClose.Date, dayClose.Date.AddDate(0, 0, 1)).
+		Order("opened_at ASC").
+		Find(&sessions)
+
+	c.JSON(http.StatusOK, gin.H{"close": dayClose, "sessions": sessions})
+}
+
+// attachCashierSession attributes a ledger entry on the bill to the session
+// its recorder has open. The session row is locked so it cannot be closed
+// while the entry is being recorded. Cash must be in the drawer's currency.
+func attachCashierSession(tx *gorm.DB, entry *models.Payment, bill models.Bill, required bool) error {
+	var session models.CashierSession
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("cashier_id = ? AND status = ?", entry.ReceivedBy, "Open").
+		First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if required {
+			return paymentError("Open a cashier session first")
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if entry.Method == "Cash" && bill.Currency != session.Currency {
+		return paymentError(fmt.Sprintf("The cash drawer of session %s takes %s only", session.Reference, session.Currency))
+	}
+	entry.SessionID = &session.ID
+	return nil
+}
+
+// dayClosed reports whether the day t falls on has been closed. It holds the
+// day's lock shared until the transaction ends, so the day cannot be closed
+// while an entry dated on it is being recorded.
+func dayClosed(tx *gorm.DB, t time.Time) (bool, error) {
+	t = t.In(time.Local)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+	if err := tx.Exec("SELECT pg_advisory_xact_lock_shared(?, ?)", dayLockClass, dayLockKey(day)).Error; err != nil {
+		return false, err
+	}
+
+	var count int64
+	err := tx.Model(&models.DailyClose{}).Where("date = ?", day).Count(&count).Error
+	return count > 0, err
+}
+
+// dayLockClass namespaces the advisory locks taken on payment days.
+const dayLockClass = 4201
+
+// dayLockKey identifies the day by its date so it is the same lock whatever
+// the time of day.
+func dayLockKey(day time.Time) int {
+	return day.Year()*10000 + int(day.Month())*100 + day.Day()
+}
+
+// findCashierSession loads the session in the :id param. Receptionists can
+// only get at their own sessions.
+func findCashierSession(c *gin.Context) (models.CashierSession, bool) {
+	var session models.CashierSession
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cashier session ID"})
+		return session, false
+	}
+
+	if err := config.DB.Preload("Cashier").First(&session, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cashier session not found"})
+		return session, false
+	}
+	if role, _ := c.Get("userRole"); role != "admin" && session.CashierID != currentUserID(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not your cashier session"})
+		return session, false
+	}
+
+	return session, true
+}
+
+func respondCashierSession(c *gin.Context, session models.CashierSession) {
+	var totals []struct {
+		Method   string          `json:"method"`
+		Currency string          `json:"currency"`
+		Entries  int64           `json:"entries"`
+		Net      decimal.Decimal `json:"net"`
+	}
+	config.DB.Model(&models.Payment{}).
+		Select("method, currency, COUNT(*) AS entries, SUM(amount) AS net").
+		Where("session_id = ?", session.ID).
+		Group("method, currency").
+		Order("method ASC, currency ASC").
+		Scan(&totals)
+
+	var payments []models.Payment
+	config.DB.Where("session_id = ?", session.ID).Order("received_at ASC, id ASC").Find(&payments)
+
+	c.JSON(http.StatusOK, gin.H{"session": session, "totals": totals, "payments": payments})
+}
@@ -0,0 +1,330 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"clinic-backend/internal/config"
+	"clinic-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func GetRoomStays(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
+		return
+	}
+
+	var stays []models.RoomStay
+	if err := config.DB.Where("room_id = ?", id).Order("started_at DESC").Find(&stays).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch room stays"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stays)
+}
+
+// ChargeRoomStays charges every occupied room for the days passed since it
+// was last charged.
+func ChargeRoomStays() {
+	var stays []models.RoomStay
+	if err := config.DB.Where("ended_at IS NULL").Find(&stays).Error; err != nil {
+		log.Println("Failed to fetch room stays:", err)
+		return
+	}
+
+	for _, open := range stays {
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			// The stay may have ended since it was listed
+			var stay models.RoomStay
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("ended_at IS NULL").
+				First(&stay, open.ID).Error; err != nil {
+				return nil
+			}
+			return chargeRoomStay(tx, &stay)
+		})
+		if err != nil {
+			log.Printf("Failed to charge room stay %d: %v", open.ID, err)
+		}
+	}
+}
+
+// SeedRoomStays opens a stay for rooms that were occupied before stays were
+// tracked. Charging starts from now, as earlier days were billed by hand.
+func SeedRoomStays() {
+	if err := config.DB.Exec(`INSERT INTO room_stays (room_id, patient_id, reason, started_at, charged_days, created_at, updated_at)
+		SELECT r.id, r.patient_id, 'Occupied before stays were tracked', CURRENT_TIMESTAMP, 0, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
+		FROM rooms r
+		WHERE r.status = 'Occupied' AND r.patient_id IS NOT NULL
+		AND NOT EXISTS (SELECT 1 FROM room_stays s WHERE s.room_id = r.id AND s.ended_at IS NULL)`).Error; err != nil {
+		log.Println("Failed to seed room stays:", err)
+	}
+}
+
+// chargeAppointment bills the consultation fee of a completed appointment,
+// at the price in force on the day of the visit. Appointments are charged
+// once, and not at all when the catalog has no fee for the doctor.
+func chargeAppointment(appointment models.Appointment) {
+	var doctor models.Doctor
+	if err := config.DB.First(&doctor, appointment.DoctorID).Error; err != nil {
+		return
+	}
+	service, ok := catalogService("Consultation", "specialization", doctor.Specialization)
+	if !ok {
+		return
+	}
+	currency, err := billCurrency("")
+	if err != nil {
+		log.Println("Failed to charge appointment:", err)
+		return
+	}
+	item, err := catalogItem(service, currency.Code, appointment.Date)
+	if err != nil {
+		log.Printf("Failed to charge appointment %d: %v", appointment.ID, err)
+		return
+	}
+	item.SourceType = "Appointment"
+	item.SourceID = &appointment.ID
+
+	bill := models.Bill{
+		PatientID:   appointment.PatientID,
+		Currency:    currency.Code,
+		Description: fmt.Sprintf("Consultation with Dr. %s on %s", doctor.Name, appointment.Date.Format("02 Jan 2006")),
+		Items:       []models.BillItem{item},
+	}
+	var encounter models.Encounter
+	if config.DB.Where("appointment_id = ?", appointment.ID).First(&encounter).Error == nil {
+		bill.EncounterID = &encounter.ID
+	}
+
+	// Completing the appointment and its encounter at the same time must
+	// not charge it twice, so the check runs under the appointment's lock
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		var locked models.Appointment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, appointment.ID).Error; err != nil {
+			return err
+		}
+		// Items on void bills no longer count, so the visit can be billed again
+		var existing int64
+		if err := tx.Model(&models.BillItem{}).
+			Joins("JOIN bills ON bills.id = bill_items.bill_id").
+			Where("bill_items.source_type = ? AND bill_items.source_id = ? AND bills.status <> ?", "Appointment", appointment.ID, "Void").
+			Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return nil
+		}
+		return issueBill(tx, &bill, currency)
+	})
+	if err != nil {
+		log.Printf("Failed to charge appointment %d: %v", appointment.ID, err)
+	}
+}
+
+// startRoomStay records the patient moving into the room, as part of the
+// admission when they are admitted.
+func startRoomStay(tx *gorm.DB, roomID, patientID uint, admissionID *uint, reason string) error {
+	stay := models.RoomStay{RoomID: roomID, PatientID: patientID, AdmissionID: admissionID, Reason: reason, StartedAt: time.Now()}
+	return tx.Create(&stay).Error
+}
+
+// endRoomStay closes the room's open stay and charges its remaining days.
+func endRoomStay(tx *gorm.DB, roomID uint) error {
+	var stay models.RoomStay
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("room_id = ? AND ended_at IS NULL", roomID).
+		First(&stay).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	stay.EndedAt = &now
+	return chargeRoomStay(tx, &stay)
+}
+
+// chargeRoomStay charges a stay for each midnight passed in the room, each
+// day at the rate in force on it. A stay that ends before midnight is
+// charged one day. Days go onto the stay's bill while it is unsettled, and
+// onto a new bill otherwise.
+func chargeRoomStay(tx *gorm.DB, stay *models.RoomStay) error {
+	end := time.Now()
+	if stay.EndedAt != nil {
+		end = *stay.EndedAt
+	}
+	days := daysBetween(stay.StartedAt, end)
+	if stay.EndedAt != nil && days == 0 {
+		days = 1
+	}
+
+	if days > stay.ChargedDays {
+		items, err := roomStayItems(stay, days)
+		if err != nil {
+			return err
+		}
+		if len(items) > 0 {
+			if err := addStayCharges(tx, stay, items); err != nil {
+				return err
+			}
+		}
+		stay.ChargedDays = days
+	}
+
+	return tx.Model(stay).Updates(map[string]interface{}{
+		"ended_at":     stay.EndedAt,
+		"charged_days": stay.ChargedDays,
+		"bill_id":      stay.BillID,
+	}).Error
+}
+
+// roomStayItems prices the uncharged days of a stay, one item per run of
+// days at the same rate. Rooms without a rate in the catalog are not charged.
+func roomStayItems(stay *models.RoomStay, days int) ([]models.BillItem, error) {
+	var room models.Room
+	if err := config.DB.First(&room, stay.RoomID).Error; err != nil {
+		return nil, err
+	}
+	service, ok := catalogService("RoomRate", "room_type", room.Type)
+	if !ok {
+		return nil, nil
+	}
+	currency, err := billCurrency("")
+	if err != nil {
+		return nil, err
+	}
+
+	start := stay.StartedAt
+	firstDay := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+
+	var items []models.BillItem
+	for d := stay.ChargedDays; d < days; d++ {
+		item, err := catalogItem(service, currency.Code, firstDay.AddDate(0, 0, d))
+		if err != nil {
+			return nil, err
+		}
+		if n := len(items); n > 0 && items[n-1].UnitPrice.Equal(item.UnitPrice) {
+			items[n-1].Quantity = items[n-1].Quantity.Add(decimal.NewFromInt(1))
+			continue
+		}
+		item.Service = fmt.Sprintf("%s, room %s", service.Name, room.RoomNumber)
+		item.SourceType = "RoomStay"
+		item.SourceID = &stay.ID
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func addStayCharges(tx *gorm.DB, stay *models.RoomStay, items []models.BillItem) error {
+	currency, err := billCurrency("")
+	if err != nil {
+		return err
+	}
+
+	if stay.BillID != nil {
+		var bill models.Bill
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Items").First(&bill, *stay.BillID).Error; err != nil {
+			return err
+		}
+		if bill.Status != "Paid" && bill.Status != "WrittenOff" && bill.Status != "Void" && bill.Currency == currency.Code && !claimSubmitted(tx, bill.ID) {
+			return addBillItems(tx, &bill, items, currency)
+		}
+	}
+
+	bill := models.Bill{
+		PatientID:   stay.PatientID,
+		Currency:    currency.Code,
+		Description: "Room charges",
+		Items:       items,
+	}
+	if stay.AdmissionID != nil {
+		var admission models.Admission
+		if err := tx.First(&admission, *stay.AdmissionID).Error; err == nil {
+			bill.EncounterID = &admission.EncounterID
+		}
+	}
+	if err := issueBill(tx, &bill, currency); err != nil {
+		return err
+	}
+	stay.BillID = &bill.ID
+	return nil
+}
+
+// issueBill numbers, prices and stores a new bill, splitting it under the
+// patient's active policy when it has none set, and opens its claim.
+func issueBill(tx *gorm.DB, bill *models.Bill, currency models.Currency) error {
+	bill.Currency = currency.Code
+	priceBill(bill, currency.Rule())
+	if bill.PolicyID == nil {
+		if policy, ok := activePolicy(tx, bill.PatientID, time.Now()); ok {
+			bill.PolicyID = &policy.ID
+		}
+	}
+	if err := applyCoverage(tx, bill, currency.Rule()); err != nil {
+		return err
+	}
+	openBill(bill)
+	if err := assignInvoiceNumber(tx, bill, time.Now()); err != nil {
+		return err
+	}
+	if err := tx.Create(bill).Error; err != nil {
+		return err
+	}
+	return syncClaim(tx, bill)
+}
+
+// addBillItems appends items to an unsettled, unclaimed bill and updates its
+// totals, insurance split and balances. The bill must be locked by the
+// caller.
+func addBillItems(tx *gorm.DB, bill *models.Bill, items []models.BillItem, currency models.Currency) error {
+	existing := len(bill.Items)
+	bill.Items = append(bill.Items, items...)
+	priceBill(bill, currency.Rule())
+
+	added := bill.Items[existing:]
+	for i := range added {
+		added[i].BillID = bill.ID
+	}
+	if err := tx.Create(&added).Error; err != nil {
+		return err
+	}
+
+	if err := applyCoverage(tx, bill, currency.Rule()); err != nil {
+		return err
+	}
+	if err := tx.Model(bill).Updates(map[string]interface{}{
+		"subtotal":       bill.Subtotal,
+		"discount_total": bill.DiscountTotal,
+		"tax_total":      bill.TaxTotal,
+		"amount":         bill.Amount,
+		"policy_id":      bill.PolicyID,
+		"patient_amount": bill.PatientAmount,
+		"payer_amount":   bill.PayerAmount,
+	}).Error; err != nil {
+		return err
+	}
+	if err := syncClaim(tx, bill); err != nil {
+		return err
+	}
+	return refreshBillBalance(tx, bill)
+}
+
+// daysBetween counts the midnights between two times.
+func daysBetween(from, to time.Time) int {
+	start := time.Date(from.Year(), from.Month(), from.Day(), 12, 0, 0, 0, time.Local)
+	end := time.Date(to.Year(), to.Month(), to.Day(), 12, 0, 0, 0, time.Local)
+	return int(math.Round(end.Sub(start).Hours() / 24))
+}
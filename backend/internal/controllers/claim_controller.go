@@ -0,0 +1,667 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"clinic-backend/internal/config"
+	"clinic-backend/internal/models"
+	"clinic-backend/internal/money"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// errClaimSubmitted is returned when a bill's charges would change after its
+// claim has gone to the insurer.
+var errClaimSubmitted = errors.New("Bill has been claimed from the insurer and its items cannot be changed")
+
+// GetClaims lists claims, optionally by status, insurer, patient or batch.
+func GetClaims(c *gin.Context) {
+	query := config.DB.Preload("Policy.Plan").Order("created_at DESC")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if insurerID := c.Query("insurerId"); insurerID != "" {
+		query = query.Where("insurer_id = ?", insurerID)
+	}
+	if patientID := c.Query("patientId"); patientID != "" {
+		query = query.Where("patient_id = ?", patientID)
+	}
+	if batchID := c.Query("batchId"); batchID != "" {
+		query = query.Where("batch_id = ?", batchID)
+	}
+
+	var claims []models.Claim
+	if err := query.Find(&claims).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch claims"})
+		return
+	}
+
+	c.JSON(http.StatusOK, claims)
+}
+
+func GetClaimByID(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid claim ID"})
+		return
+	}
+
+	var claim models.Claim
+	if err := config.DB.Preload("Bill.Items").Preload("Policy.Plan.Insurer").First(&claim, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Claim not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, claim)
+}
+
+// CreateClaimBatch submits every pending claim on an insurer in one currency,
+// the default currency unless another is given.
+func CreateClaimBatch(c *gin.Context) {
+	var input struct {
+		InsurerID uint   `json:"insurerId" binding:"required"`
+		Currency  string `json:"currency"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var insurer models.Insurer
+	if err := config.DB.First(&insurer, input.InsurerID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Insurer not found"})
+		return
+	}
+	currency, err := billCurrency(input.Currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	batch := models.ClaimBatch{
+		InsurerID:   insurer.ID,
+		Currency:    currency.Code,
+		TotalAmount: decimal.Zero,
+		CreatedBy:   currentUserID(c),
+		SubmittedAt: now,
+	}
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		var claims []models.Claim
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("insurer_id = ? AND currency = ? AND status = ?", insurer.ID, currency.Code, "Pending").
+			Order("id ASC").
+			Find(&claims).Error; err != nil {
+			return err
+		}
+		if len(claims) == 0 {
+			return paymentError("No pending " + currency.Code + " claims for " + insurer.Name)
+		}
+
+		ids := make([]uint, len(claims))
+		for i, claim := range claims {
+			ids[i] = claim.ID
+			batch.TotalAmount = batch.TotalAmount.Add(claim.ClaimedAmount)
+		}
+		batch.ClaimCount = len(claims)
+		if err := tx.Create(&batch).Error; err != nil {
+			return err
+		}
+		batch.BatchNumber = fmt.Sprintf("CB-%06d", batch.ID)
+		if err := tx.Model(&batch).Update("batch_number", batch.BatchNumber).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&models.Claim{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"batch_id":     batch.ID,
+			"status":       "Submitted",
+			"submitted_at": now,
+		}).Error
+	})
+	var rejected paymentError
+	if errors.As(err, &rejected) {
+		c.JSON(http.StatusConflict, gin.H{"error": rejected.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create claim batch"})
+		return
+	}
+
+	config.DB.Preload("Insurer").Preload("Claims").First(&batch, batch.ID)
+	c.JSON(http.StatusCreated, batch)
+}
+
+func GetClaimBatches(c *gin.Context) {
+	query := config.DB.Preload("Insurer").Order("submitted_at DESC")
+	if insurerID := c.Query("insurerId"); insurerID != "" {
+		query = query.Where("insurer_id = ?", insurerID)
+	}
+
+	var batches []models.ClaimBatch
+	if err := query.Find(&batches).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch claim batches"})
+		return
+	}
+
+	c.JSON(http.StatusOK, batches)
+}
+
+// GetClaimBatchByID returns a batch with its claims, or with ?format=csv the
+// submission file for the insurer, one row per billed item.
+func GetClaimBatchByID(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid batch ID"})
+		return
+	}
+
+	var batch models.ClaimBatch
+	if err := config.DB.Preload("Insurer").
+		Preload("Claims.Bill.Patient").
+		Preload("Claims.Bill.Items").
+		Preload("Claims.Policy").
+		First(&batch, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Claim batch not found"})
+		return
+	}
+
+	if c.Query("format") != "csv" {
+		c.JSON(http.StatusOK, batch)
+		return
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"batch", "payer_id", "claim", "policy_number", "holder", "patient", "bill", "bill_date",
+		"service", "quantity", "unit_price", "line_total", "bill_total", "claimed_amount", "currency"})
+	for _, claim := range batch.Claims {
+		if claim.Bill == nil {
+			continue
+		}
+		policyNumber, holder := "", ""
+		if claim.Policy != nil {
+			policyNumber, holder = claim.Policy.PolicyNumber, claim.Policy.HolderName
+		}
+		for _, item := range claim.Bill.Items {
+			w.Write([]string{
+				batch.BatchNumber,
+				batch.Insurer.PayerID,
+				claim.ClaimNumber,
+				policyNumber,
+				holder,
+				claim.Bill.Patient.Name,
+				strconv.FormatUint(uint64(claim.BillID), 10),
+				claim.Bill.CreatedAt.Format("2006-01-02"),
+				item.Service,
+				item.Quantity.String(),
+				item.UnitPrice.String(),
+				item.Total.String(),
+				claim.Bill.Amount.String(),
+				claim.ClaimedAmount.String(),
+				claim.Currency,
+			})
+		}
+	}
+	w.Flush()
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, batch.BatchNumber))
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
+
+// AdjudicateClaim records the insurer's decision on a submitted claim. The
+// insurer pays the approved amount and writes off the adjustment; whatever
+// else was claimed becomes the patient's to pay.
+func AdjudicateClaim(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid claim ID"})
+		return
+	}
+
+	var input struct {
+		ApprovedAmount   decimal.Decimal `json:"approvedAmount"`
+		AdjustmentAmount decimal.Decimal `json:"adjustmentAmount"`
+		DenialReason     string          `json:"denialReason"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	input.DenialReason = strings.TrimSpace(input.DenialReason)
+	if input.ApprovedAmount.IsNegative() || input.AdjustmentAmount.IsNegative() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Amounts cannot be negative"})
+		return
+	}
+	if input.ApprovedAmount.IsZero() && input.DenialReason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A denial reason is required"})
+		return
+	}
+
+	var claim models.Claim
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&claim, id).Error; err != nil {
+			return err
+		}
+		if claim.Status != "Submitted" {
+			return paymentError("Only submitted claims can be adjudicated")
+		}
+		covered := input.ApprovedAmount.Add(input.AdjustmentAmount)
+		if covered.GreaterThan(claim.ClaimedAmount) {
+			return paymentError(fmt.Sprintf("Approved and adjusted amounts exceed the %s %s claimed", claim.ClaimedAmount.String(), claim.Currency))
+		}
+
+		var bill models.Bill
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&bill, claim.BillID).Error; err != nil {
+			return err
+		}
+		// What was claimed and not covered becomes the patient's. Earlier
+		// claims on a resubmitted bill keep what they covered.
+		bill.PayerAmount = bill.PayerAmount.Sub(claim.ClaimedAmount).Add(covered)
+		bill.PatientAmount = bill.Amount.Sub(bill.PayerAmount)
+		if err := tx.Model(&bill).Updates(map[string]interface{}{
+			"payer_amount":   bill.PayerAmount,
+			"patient_amount": bill.PatientAmount,
+		}).Error; err != nil {
+			return err
+		}
+
+		if input.AdjustmentAmount.IsPositive() {
+			entry := models.Payment{
+				Kind:       "Adjustment",
+				Method:     "Insurance",
+				Amount:     input.AdjustmentAmount,
+				Reference:  claim.ClaimNumber,
+				Reason:     "Contractual adjustment on claim " + claim.ClaimNumber,
+				ReceivedBy: currentUserID(c),
+				ReceivedAt: time.Now(),
+			}
+			if err := addLedgerEntry(tx, bill.ID, &entry, func(*gorm.DB, models.Bill) error { return nil }); err != nil {
+				return err
+			}
+		} else if err := refreshBillBalance(tx, &bill); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		claim.ApprovedAmount = input.ApprovedAmount
+		claim.AdjustmentAmount = input.AdjustmentAmount
+		claim.DenialReason = input.DenialReason
+		claim.AdjudicatedAt = &now
+		switch {
+		case input.ApprovedAmount.IsZero():
+			claim.Status = "Denied"
+		case input.ApprovedAmount.Equal(claim.ClaimedAmount):
+			claim.Status = "Approved"
+		default:
+			claim.Status = "PartiallyApproved"
+		}
+		return tx.Model(&claim).Updates(map[string]interface{}{
+			"approved_amount":   claim.ApprovedAmount,
+			"adjustment_amount": claim.AdjustmentAmount,
+			"denial_reason":     claim.DenialReason,
+			"adjudicated_at":    claim.AdjudicatedAt,
+			"status":            claim.Status,
+		}).Error
+	})
+	var rejected paymentError
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Claim not found"})
+		return
+	case errors.As(err, &rejected):
+		c.JSON(http.StatusConflict, gin.H{"error": rejected.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to adjudicate claim"})
+		return
+	}
+
+	c.JSON(http.StatusOK, claim)
+}
+
+// ResubmitClaim claims again, on a new claim, what a denied or partially
+// approved claim left uncovered, e.g. after the missing information was
+// supplied. Only what the patient has not paid yet moves back to the insurer.
+func ResubmitClaim(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid claim ID"})
+		return
+	}
+
+	var resubmitted models.Claim
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		var claim models.Claim
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&claim, id).Error; err != nil {
+			return err
+		}
+		if claim.Status != "Denied" && claim.Status != "PartiallyApproved" {
+			return paymentError("Only denied or partially approved claims can be resubmitted")
+		}
+
+		var bill models.Bill
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&bill, claim.BillID).Error; err != nil {
+			return err
+		}
+		if bill.Status == "Void" || bill.Status == "WrittenOff" {
+			return paymentError("Claims on a " + bill.Status + " bill cannot be resubmitted")
+		}
+		var newer int64
+		tx.Model(&models.Claim{}).Where("bill_id = ? AND id > ?", bill.ID, claim.ID).Count(&newer)
+		if newer > 0 {
+			return paymentError("Claim " + claim.ClaimNumber + " has already been resubmitted")
+		}
+
+		amount := claim.ClaimedAmount.Sub(claim.ApprovedAmount).Sub(claim.AdjustmentAmount)
+		amount = decimal.Min(amount, bill.PatientBalance)
+		if !amount.IsPositive() {
+			return paymentError("Nothing is left to claim on " + claim.ClaimNumber)
+		}
+
+		bill.PayerAmount = bill.PayerAmount.Add(amount)
+		bill.PatientAmount = bill.PatientAmount.Sub(amount)
+		if err := tx.Model(&bill).Updates(map[string]interface{}{
+			"payer_amount":   bill.PayerAmount,
+			"patient_amount": bill.PatientAmount,
+		}).Error; err != nil {
+			return err
+		}
+		if err := refreshBillBalance(tx, &bill); err != nil {
+			return err
+		}
+
+		resubmitted = models.Claim{
+			BillID:        bill.ID,
+			PatientID:     claim.PatientID,
+			PolicyID:      claim.PolicyID,
+			InsurerID:     claim.InsurerID,
+			Currency:      claim.Currency,
+			ClaimedAmount: amount,
+			Status:        "Pending",
+		}
+		return createClaim(tx, &resubmitted)
+	})
+	var rejected paymentError
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Claim not found"})
+		return
+	case errors.As(err, &rejected):
+		c.JSON(http.StatusConflict, gin.H{"error": rejected.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resubmit claim"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, resubmitted)
+}
+
+// RecordRemittance records a payment from an insurer and posts each of its
+// lines to the ledger of the claimed bill. A claim cannot be paid beyond its
+// approved amount.
+func RecordRemittance(c *gin.Context) {
+	var input struct {
+		InsurerID  uint       `json:"insurerId" binding:"required"`
+		Reference  string     `json:"reference" binding:"required"`
+		ReceivedAt *time.Time `json:"receivedAt"`
+		Lines      []struct {
+			ClaimID uint            `json:"claimId"`
+			Amount  decimal.Decimal `json:"amount"`
+		} `json:"lines" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(input.Lines) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A remittance needs at least one line"})
+		return
+	}
+
+	remittance := models.Remittance{
+		InsurerID:  input.InsurerID,
+		Reference:  strings.TrimSpace(input.Reference),
+		Amount:     decimal.Zero,
+		ReceivedAt: time.Now(),
+		RecordedBy: currentUserID(c),
+	}
+	if input.ReceivedAt != nil {
+		if !checkReceivedAt(c, *input.ReceivedAt) {
+			return
+		}
+		remittance.ReceivedAt = *input.ReceivedAt
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&remittance).Error; err != nil {
+			return err
+		}
+
+		for _, line := range input.Lines {
+			if !line.Amount.IsPositive() {
+				return paymentError("Remittance amounts must be positive")
+			}
+
+			var claim models.Claim
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&claim, line.ClaimID).Error; err != nil {
+				return paymentError(fmt.Sprintf("Claim %d not found", line.ClaimID))
+			}
+			if claim.InsurerID != remittance.InsurerID {
+				return paymentError("Claim " + claim.ClaimNumber + " is not on this insurer")
+			}
+			if claim.Status != "Approved" && claim.Status != "PartiallyApproved" {
+				return paymentError("Claim " + claim.ClaimNumber + " is not awaiting payment")
+			}
+			if remittance.Currency == "" {
+				remittance.Currency = claim.Currency
+			}
+			if claim.Currency != remittance.Currency {
+				return paymentError("All claims of a remittance must be in one currency")
+			}
+			if due := claim.ApprovedAmount.Sub(claim.PaidAmount); line.Amount.GreaterThan(due) {
+				return paymentError(fmt.Sprintf("Only %s %s is due on claim %s", due.String(), claim.Currency, claim.ClaimNumber))
+			}
+
+			entry := models.Payment{
+				Kind:       "Payment",
+				Method:     "Insurance",
+				Amount:     line.Amount,
+				Reference:  remittance.Reference,
+				ReceivedBy: remittance.RecordedBy,
+				ReceivedAt: remittance.ReceivedAt,
+			}
+			if err := addLedgerEntry(tx, claim.BillID, &entry, func(*gorm.DB, models.Bill) error { return nil }); err != nil {
+				return err
+			}
+
+			remittance.Lines = append(remittance.Lines, models.RemittanceLine{
+				RemittanceID: remittance.ID,
+				ClaimID:      claim.ID,
+				PaymentID:    entry.ID,
+				Amount:       line.Amount,
+			})
+			remittance.Amount = remittance.Amount.Add(line.Amount)
+
+			claim.PaidAmount = claim.PaidAmount.Add(line.Amount)
+			if !claim.PaidAmount.LessThan(claim.ApprovedAmount) {
+				claim.Status = "Paid"
+			}
+			if err := tx.Model(&claim).Updates(map[string]interface{}{
+				"paid_amount": claim.PaidAmount,
+				"status":      claim.Status,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Create(&remittance.Lines).Error; err != nil {
+			return err
+		}
+		return tx.Model(&remittance).Updates(map[string]interface{}{
+			"amount":   remittance.Amount,
+			"currency": remittance.Currency,
+		}).Error
+	})
+	var rejected paymentError
+	switch {
+	case errors.As(err, &rejected):
+		c.JSON(http.StatusConflict, gin.H{"error": rejected.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record remittance"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, remittance)
+}
+
+func GetRemittances(c *gin.Context) {
+	from, to, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+
+	query := config.DB.Preload("Lines").
+		Where("received_at >= ? AND received_at < ?", from, to).
+		Order("received_at DESC")
+	if insurerID := c.Query("insurerId"); insurerID != "" {
+		query = query.Where("insurer_id = ?", insurerID)
+	}
+
+	var remittances []models.Remittance
+	if err := query.Find(&remittances).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch remittances"})
+		return
+	}
+
+	c.JSON(http.StatusOK, remittances)
+}
+
+// SeedBillShares makes uninsured bills, including those from before
+// insurance billing, owed in full by the patient.
+func SeedBillShares() {
+	if err := config.DB.Exec(`UPDATE bills SET
+		patient_amount = amount, payer_amount = 0,
+		patient_balance = balance, payer_balance = 0
+		WHERE policy_id IS NULL AND (patient_amount <> amount OR patient_balance <> balance)`).Error; err != nil {
+		log.Println("Failed to seed bill shares:", err)
+	}
+}
+
+// applyCoverage splits the bill into the patient's and the insurer's portion
+// under its policy. The patient pays the copay and the coinsurance share of
+// the rest; the insurer pays the remainder within the plan's per-bill limit
+// and what is left of its annual limit on the policy. Bills without a policy,
+// or in another currency than the plan, are the patient's alone.
+func applyCoverage(tx *gorm.DB, bill *models.Bill, rule money.Rule) error {
+	bill.PatientAmount, bill.PayerAmount = bill.Amount, decimal.Zero
+	if bill.PolicyID == nil {
+		return nil
+	}
+
+	// Bills on the same policy take from its annual limit one at a time
+	var policy models.PatientPolicy
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Plan").First(&policy, *bill.PolicyID).Error; err != nil {
+		return err
+	}
+	plan := policy.Plan
+	if plan == nil || plan.Currency != bill.Currency {
+		bill.PolicyID = nil
+		return nil
+	}
+
+	covered := bill.Amount.Sub(decimal.Min(plan.Copay, bill.Amount))
+	payer := covered.Sub(rule.Round(covered.Mul(plan.Coinsurance).Div(hundred)))
+	if plan.PerBillLimit.IsPositive() {
+		payer = decimal.Min(payer, plan.PerBillLimit)
+	}
+	if plan.AnnualLimit.IsPositive() {
+		// The limit is counted in the year the bill was issued
+		issued := bill.CreatedAt
+		if issued.IsZero() {
+			issued = time.Now()
+		}
+		yearStart := time.Date(issued.Year(), 1, 1, 0, 0, 0, 0, time.Local)
+		var used decimal.Decimal
+		if err := tx.Model(&models.Bill{}).
+			Select("COALESCE(SUM(payer_amount), 0)").
+			Where("policy_id = ? AND id <> ? AND status <> ? AND created_at >= ? AND created_at < ?", policy.ID, bill.ID, "Void", yearStart, yearStart.AddDate(1, 0, 0)).
+			Row().Scan(&used); err != nil {
+			return err
+		}
+		payer = decimal.Min(payer, decimal.Max(plan.AnnualLimit.Sub(used), decimal.Zero))
+	}
+
+	bill.PayerAmount = payer
+	bill.PatientAmount = bill.Amount.Sub(payer)
+	return nil
+}
+
+// syncClaim keeps the bill's latest pending claim in step with its insurer portion,
+// opening a claim when the insurer owes something and dropping it when it
+// no longer does. Claims already submitted are left alone.
+func syncClaim(tx *gorm.DB, bill *models.Bill) error {
+	var claim models.Claim
+	err := tx.Where("bill_id = ?", bill.ID).Order("id DESC").First(&claim).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	exists := err == nil
+
+	if exists && claim.Status != "Pending" {
+		return nil
+	}
+	if bill.PolicyID == nil || !bill.PayerAmount.IsPositive() {
+		if exists {
+			return tx.Delete(&claim).Error
+		}
+		return nil
+	}
+
+	var policy models.PatientPolicy
+	if err := tx.Preload("Plan").First(&policy, *bill.PolicyID).Error; err != nil {
+		return err
+	}
+
+	claim.BillID = bill.ID
+	claim.PatientID = bill.PatientID
+	claim.PolicyID = policy.ID
+	claim.InsurerID = policy.Plan.InsurerID
+	claim.Currency = bill.Currency
+	claim.ClaimedAmount = bill.PayerAmount
+	claim.Status = "Pending"
+	if exists {
+		return tx.Omit("Bill", "Policy").Save(&claim).Error
+	}
+
+	return createClaim(tx, &claim)
+}
+
+// createClaim stores a new claim and numbers it.
+func createClaim(tx *gorm.DB, claim *models.Claim) error {
+	if err := tx.Omit("Bill", "Policy").Create(claim).Error; err != nil {
+		return err
+	}
+	claim.ClaimNumber = fmt.Sprintf("CL-%06d", claim.ID)
+	return tx.Model(claim).Update("claim_number", claim.ClaimNumber).Error
+}
+
+// claimSubmitted reports whether the bill's claim has gone to the insurer.
+func claimSubmitted(tx *gorm.DB, billID uint) bool {
+	var count int64
+	tx.Model(&models.Claim{}).Where("bill_id = ? AND status <> ?", billID, "Pending").Count(&count)
+	return count > 0
+}
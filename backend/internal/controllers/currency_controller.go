@@ -0,0 +1,239 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"clinic-backend/internal/config"
+	"clinic-backend/internal/models"
+	"clinic-backend/internal/money"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+func GetCurrencies(c *gin.Context) {
+	var currencies []models.Currency
+	if err := config.DB.Order("code ASC").Find(&currencies).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch currencies"})
+		return
+	}
+
+	c.JSON(http.StatusOK, currencies)
+}
+
+func CreateCurrency(c *gin.Context) {
+	var currency models.Currency
+	if err := c.ShouldBindJSON(&currency); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	currency.ID = 0
+
+	if err := validateCurrency(&currency); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var existing int64
+	config.DB.Model(&models.Currency{}).Where("code = ?", currency.Code).Count(&existing)
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Currency " + currency.Code + " already exists"})
+		return
+	}
+
+	if err := saveCurrency(&currency); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create currency"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, currency)
+}
+
+// UpdateCurrency changes a currency's rounding rule. Bills already issued
+// keep the amounts they were rounded to.
+func UpdateCurrency(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid currency ID"})
+		return
+	}
+
+	var currency models.Currency
+	if err := config.DB.First(&currency, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Currency not found"})
+		return
+	}
+	code, wasDefault := currency.Code, currency.IsDefault
+
+	if err := c.ShouldBindJSON(&currency); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	currency.ID = uint(id)
+
+	if err := validateCurrency(&currency); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if currency.Code != code {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Currency code cannot be changed"})
+		return
+	}
+	if wasDefault && !currency.IsDefault {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Make another currency the default instead"})
+		return
+	}
+
+	if err := saveCurrency(&currency); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update currency"})
+		return
+	}
+
+	c.JSON(http.StatusOK, currency)
+}
+
+func DeleteCurrency(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid currency ID"})
+		return
+	}
+
+	var currency models.Currency
+	if err := config.DB.First(&currency, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Currency not found"})
+		return
+	}
+
+	if currency.IsDefault {
+		c.JSON(http.StatusConflict, gin.H{"error": "The default currency cannot be deleted"})
+		return
+	}
+	var used int64
+	config.DB.Model(&models.Bill{}).Where("currency = ?", currency.Code).Count(&used)
+	if used > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Currency is used by bills"})
+		return
+	}
+
+	if err := config.DB.Delete(&currency).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete currency"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Currency deleted successfully"})
+}
+
+// SeedCurrencies creates the default currency, DEFAULT_CURRENCY or USD, on an
+// empty table and assigns it to bills issued before currencies existed.
+func SeedCurrencies() {
+	var count int64
+	config.DB.Model(&models.Currency{}).Count(&count)
+	if count == 0 {
+		code := strings.ToUpper(strings.TrimSpace(os.Getenv("DEFAULT_CURRENCY")))
+		if code == "" {
+			code = "USD"
+		}
+
+		currency := models.Currency{Code: code, Name: code, Decimals: 2, RoundingMode: money.HalfUp, IsDefault: true}
+		if known, ok := knownCurrencies[code]; ok {
+			currency.Name, currency.Symbol, currency.Decimals = known.Name, known.Symbol, known.Decimals
+		}
+		if err := config.DB.Create(&currency).Error; err != nil {
+			log.Println("Failed to seed currencies:", err)
+			return
+		}
+	}
+
+	currency, err := billCurrency("")
+	if err != nil {
+		return
+	}
+	if err := config.DB.Model(&models.Bill{}).Where("currency IS NULL OR currency = ''").Update("currency", currency.Code).Error; err != nil {
+		log.Println("Failed to assign currency to bills:", err)
+	}
+}
+
+// knownCurrencies fills in the details of common currencies when seeding.
+var knownCurrencies = map[string]models.Currency{
+	"USD": {Name: "US Dollar", Symbol: "$", Decimals: 2},
+	"EUR": {Name: "Euro", Symbol: "€", Decimals: 2},
+	"GBP": {Name: "Pound Sterling", Symbol: "£", Decimals: 2},
+	"INR": {Name: "Indian Rupee", Symbol: "₹", Decimals: 2},
+	"KES": {Name: "Kenyan Shilling", Symbol: "KSh", Decimals: 2},
+	"NGN": {Name: "Naira", Symbol: "₦", Decimals: 2},
+	"JPY": {Name: "Yen", Symbol: "¥", Decimals: 0},
+	"UGX": {Name: "Uganda Shilling", Symbol: "USh", Decimals: 0},
+}
+
+func validateCurrency(currency *models.Currency) error {
+	currency.Code = strings.ToUpper(strings.TrimSpace(currency.Code))
+	if len(currency.Code) != 3 {
+		return errors.New("Currency code must be a three letter ISO 4217 code")
+	}
+	if currency.Name = strings.TrimSpace(currency.Name); currency.Name == "" {
+		currency.Name = currency.Code
+	}
+	if currency.RoundingMode == "" {
+		currency.RoundingMode = money.HalfUp
+	}
+	if err := currency.Rule().Validate(); err != nil {
+		return fmt.Errorf("Invalid rounding rule: %v", err)
+	}
+	return nil
+}
+
+// saveCurrency stores the currency, making it the only default when flagged
+// as default.
+func saveCurrency(currency *models.Currency) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(currency).Error; err != nil {
+			return err
+		}
+		if !currency.IsDefault {
+			return nil
+		}
+		return tx.Model(&models.Currency{}).Where("id <> ?", currency.ID).Update("is_default", false).Error
+	})
+}
+
+// billCurrency looks up a currency by code, or the default currency when the
+// code is empty.
+func billCurrency(code string) (models.Currency, error) {
+	var currency models.Currency
+
+	query := config.DB.Where("is_default = ?", true)
+	if code = strings.ToUpper(strings.TrimSpace(code)); code != "" {
+		query = config.DB.Where("code = ?", code)
+	}
+	if err := query.First(&currency).Error; err != nil {
+		if code == "" {
+			return currency, errors.New("No default currency is configured")
+		}
+		return currency, errors.New("Unknown currency " + code)
+	}
+	return currency, nil
+}
+
+// sumAmounts adds up an amount column per currency, e.g. the outstanding
+// total of unpaid bills. The sum runs in the database on exact numerics.
+func sumAmounts(query *gorm.DB, column string) ([]currencyTotal, error) {
+	totals := []currencyTotal{}
+	err := query.Select("currency, COALESCE(SUM(" + column + "), 0) AS amount").
+		Group("currency").
+		Order("currency ASC").
+		Scan(&totals).Error
+	return totals, err
+}
+
+type currencyTotal struct {
+	Currency string          `json:"currency"`
+	Amount   decimal.Decimal `json:"amount"`
+}
@@ -12,12 +12,13 @@ import (
 
 func GetAdminDashboard(c *gin.Context) {
 	var stats struct {
-		TotalPatients     int64 `json:"totalPatients"`
-		TotalDoctors      int64 `json:"totalDoctors"`
-		TotalAppointments int64 `json:"totalAppointments"`
-		TodayAppointments int64 `json:"todayAppointments"`
-		PendingBills      int64 `json:"pendingBills"`
-		AvailableRooms    int64 `json:"availableRooms"`
+		TotalPatients     int64           `json:"totalPatients"`
+		TotalDoctors      int64           `json:"totalDoctors"`
+		TotalAppointments int64           `json:"totalAppointments"`
+		TodayAppointments int64           `json:"todayAppointments"`
+		PendingBills      int64           `json:"pendingBills"`
+		PendingAmounts    []currencyTotal `json:"pendingAmounts"` // per currency
+		AvailableRooms    int64           `json:"availableRooms"`
 	}
 
 	// Get counts
@@ -34,7 +35,9 @@ func GetAdminDashboard(c *gin.Context) {
 		Count(&stats.TodayAppointments)
 
 	// Pending bills
-	config.DB.Model(&models.Bill{}).Where("status = ?", "Unpaid").Count(&stats.PendingBills)
+	outstanding := []string{"Unpaid", "PartiallyPaid", "Overdue"}
+	config.DB.Model(&models.Bill{}).Where("status IN ?", outstanding).Count(&stats.PendingBills)
+	stats.PendingAmounts, _ = sumAmounts(config.DB.Model(&models.Bill{}).Where("status IN ?", outstanding), "balance")
 
 	// Available rooms
 	config.DB.Model(&models.Room{}).Where("status = ?", "Available").Count(&stats.AvailableRooms)
@@ -0,0 +1,428 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"clinic-backend/internal/config"
+	"clinic-backend/internal/icd10"
+	"clinic-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// codeQuery matches search input that looks like the start of an ICD-10 code.
+var codeQuery = regexp.MustCompile(`^[A-Za-z](\d[0-9A-Za-z.]*)?$`)
+
+var validDiagnosisStatuses = map[string]bool{
+	"Provisional": true,
+	"Confirmed":   true,
+	"RuledOut":    true,
+}
+
+// ImportICD10Codes loads the code table from an uploaded file, or from the
+// file at ICD10_FILE when nothing is uploaded. Existing codes are updated.
+func ImportICD10Codes(c *gin.Context) {
+	reader, ok := openImportFile(c, "ICD10_FILE")
+	if !ok {
+		return
+	}
+	defer reader.Close()
+
+	entries, err := icd10.Parse(reader)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Later lines win when a file lists a code twice
+	index := map[string]int{}
+	var codes []models.ICD10Code
+	for _, entry := range entries {
+		if i, seen := index[entry.Code]; seen {
+			codes[i].Description = entry.Description
+			continue
+		}
+		index[entry.Code] = len(codes)
+		codes = append(codes, models.ICD10Code{Code: entry.Code, Description: entry.Description})
+	}
+
+	if len(codes) > 0 {
+		if err := config.DB.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "code"}},
+			DoUpdates: clause.AssignmentColumns([]string{"description", "updated_at"}),
+		}).CreateInBatches(&codes, 1000).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import ICD-10 codes"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ICD-10 codes imported successfully", "count": len(codes)})
+}
+
+// SearchICD10Codes autocompletes on code prefix first, then on description.
+func SearchICD10Codes(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Query parameter q is required"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	// Only code-shaped queries go through the code prefix search; normalizing
+	// free text would strip its spaces and match unrelated codes
+	var results []models.ICD10Code
+	if codeQuery.MatchString(q) {
+		if err := config.DB.Where("code LIKE ?", escapeLike(icd10.Normalize(q))+"%").
+			Order("code ASC").
+			Limit(limit).
+			Find(&results).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search ICD-10 codes"})
+			return
+		}
+	}
+
+	if len(results) < limit {
+		query := config.DB.Where("description ILIKE ?", "%"+escapeLike(q)+"%").
+			Order("code ASC").
+			Limit(limit - len(results))
+		if len(results) > 0 {
+			ids := make([]uint, 0, len(results))
+			for _, code := range results {
+				ids = append(ids, code.ID)
+			}
+			query = query.Where("id NOT IN ?", ids)
+		}
+
+		var byDescription []models.ICD10Code
+		if err := query.Find(&byDescription).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search ICD-10 codes"})
+			return
+		}
+		results = append(results, byDescription...)
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+func GetICD10Code(c *gin.Context) {
+	var code models.ICD10Code
+	if err := config.DB.Where("code = ?", icd10.Normalize(c.Param("code"))).First(&code).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "ICD-10 code not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, code)
+}
+
+func AddRecordDiagnosis(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid medical record ID"})
+		return
+	}
+
+	var record models.MedicalRecord
+	if err := config.DB.First(&record, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Medical record not found"})
+		return
+	}
+
+	if record.Status != "Draft" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Diagnoses cannot be added to a signed medical record"})
+		return
+	}
+
+	var diagnosis models.RecordDiagnosis
+	if err := c.ShouldBindJSON(&diagnosis); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	diagnosis.ID = 0
+	diagnosis.MedicalRecordID = record.ID
+
+	if err := prepareDiagnosis(&diagnosis); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The first coded diagnosis on a record is its primary one
+	var existing int64
+	config.DB.Model(&models.RecordDiagnosis{}).Where("medical_record_id = ?", record.ID).Count(&existing)
+	if existing == 0 {
+		diagnosis.Primary = true
+	}
+
+	if err := saveRecordDiagnosis(&diagnosis); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add diagnosis"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, diagnosis)
+}
+
+func UpdateRecordDiagnosis(c *gin.Context) {
+	diagnosis, ok := findRecordDiagnosis(c)
+	if !ok {
+		return
+	}
+
+	var record models.MedicalRecord
+	if err := config.DB.First(&record, diagnosis.MedicalRecordID).Error; err == nil && record.Status != "Draft" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Diagnoses cannot be changed on a signed medical record"})
+		return
+	}
+
+	diagnosisID, recordID, wasPrimary := diagnosis.ID, diagnosis.MedicalRecordID, diagnosis.Primary
+	previousCode, previousDescription := diagnosis.Code, diagnosis.Description
+	if err := c.ShouldBindJSON(&diagnosis); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	diagnosis.ID = diagnosisID
+	diagnosis.MedicalRecordID = recordID
+
+	// A new code without a description of its own takes the catalog's
+	if icd10.Normalize(diagnosis.Code) != previousCode && diagnosis.Description == previousDescription {
+		diagnosis.Description = ""
+	}
+
+	if wasPrimary && !diagnosis.Primary {
+		c.JSON(http.StatusConflict, gin.H{"error": "Mark another diagnosis as primary instead"})
+		return
+	}
+
+	if err := prepareDiagnosis(&diagnosis); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := saveRecordDiagnosis(&diagnosis); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update diagnosis"})
+		return
+	}
+
+	c.JSON(http.StatusOK, diagnosis)
+}
+
+func DeleteRecordDiagnosis(c *gin.Context) {
+	diagnosis, ok := findRecordDiagnosis(c)
+	if !ok {
+		return
+	}
+
+	var record models.MedicalRecord
+	if err := config.DB.First(&record, diagnosis.MedicalRecordID).Error; err == nil && record.Status != "Draft" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Diagnoses cannot be removed from a signed medical record"})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&diagnosis).Error; err != nil {
+			return err
+		}
+		if !diagnosis.Primary {
+			return nil
+		}
+
+		// The oldest remaining diagnosis takes over as primary
+		var next models.RecordDiagnosis
+		err := tx.Where("medical_record_id = ?", diagnosis.MedicalRecordID).Order("id ASC").First(&next).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return tx.Model(&next).Update("is_primary", true).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete diagnosis"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Diagnosis deleted successfully"})
+}
+
+// GetDiagnosisPrevalence counts patients per ICD-10 code over a date range,
+// counting confirmed diagnoses unless another status is requested.
+func GetDiagnosisPrevalence(c *gin.Context) {
+	from, to, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+
+	status := c.DefaultQuery("status", "Confirmed")
+
+	var rows []struct {
+		Code        string `json:"code"`
+		Description string `json:"description"`
+		Patients    int64  `json:"patients"`
+		Diagnoses   int64  `json:"diagnoses"`
+	}
+	if err := config.DB.Table("record_diagnoses").
+		Select("record_diagnoses.code, MAX(record_diagnoses.description) AS description, "+
+			"COUNT(DISTINCT medical_records.patient_id) AS patients, COUNT(*) AS diagnoses").
+		Joins("JOIN medical_records ON medical_records.id = record_diagnoses.medical_record_id").
+		Where("record_diagnoses.status = ?", status).
+		Where("medical_records.date >= ? AND medical_records.date < ?", from, to).
+		Group("record_diagnoses.code").
+		Order("patients DESC, record_diagnoses.code ASC").
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build diagnosis report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"from": from, "to": to, "status": status, "codes": rows})
+}
+
+// prepareDiagnoses validates coded diagnoses submitted with a new record and
+// makes sure exactly one of them is primary.
+func prepareDiagnoses(diagnoses []models.RecordDiagnosis) error {
+	primaries := 0
+	for i := range diagnoses {
+		diagnoses[i].ID = 0
+		if err := prepareDiagnosis(&diagnoses[i]); err != nil {
+			return err
+		}
+		if diagnoses[i].Primary {
+			primaries++
+		}
+	}
+	if primaries > 1 {
+		return errors.New("Only one diagnosis can be primary")
+	}
+	if primaries == 0 && len(diagnoses) > 0 {
+		diagnoses[0].Primary = true
+	}
+	return nil
+}
+
+// prepareDiagnosis checks the code against the catalog, fills in its
+// description and defaults the status to Provisional.
+func prepareDiagnosis(diagnosis *models.RecordDiagnosis) error {
+	diagnosis.Code = icd10.Normalize(diagnosis.Code)
+
+	var code models.ICD10Code
+	if err := config.DB.Where("code = ?", diagnosis.Code).First(&code).Error; err != nil {
+		return errors.New("Unknown ICD-10 code " + diagnosis.Code)
+	}
+	if diagnosis.Description == "" {
+		diagnosis.Description = code.Description
+	}
+
+	if diagnosis.Status == "" {
+		diagnosis.Status = "Provisional"
+	}
+	if !validDiagnosisStatuses[diagnosis.Status] {
+		return errors.New("Invalid diagnosis status. Must be: Provisional, Confirmed, or RuledOut")
+	}
+	return nil
+}
+
+// saveRecordDiagnosis saves the diagnosis, demoting the record's other
+// diagnoses when this one is primary.
+func saveRecordDiagnosis(diagnosis *models.RecordDiagnosis) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if diagnosis.Primary {
+			if err := tx.Model(&models.RecordDiagnosis{}).
+				Where("medical_record_id = ? AND id <> ?", diagnosis.MedicalRecordID, diagnosis.ID).
+				Update("is_primary", false).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Save(diagnosis).Error
+	})
+}
+
+func findRecordDiagnosis(c *gin.Context) (models.RecordDiagnosis, bool) {
+	var diagnosis models.RecordDiagnosis
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid medical record ID"})
+		return diagnosis, false
+	}
+	diagnosisID, err := strconv.ParseUint(c.Param("diagnosisId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid diagnosis ID"})
+		return diagnosis, false
+	}
+
+	if err := config.DB.Where("medical_record_id = ?", id).First(&diagnosis, diagnosisID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Diagnosis not found"})
+		return diagnosis, false
+	}
+
+	return diagnosis, true
+}
+
+// parseDateRange reads the from/to query parameters (YYYY-MM-DD). The range
+// covers the whole "to" day and defaults to the last 30 days.
+func parseDateRange(c *gin.Context) (time.Time, time.Time, bool) {
+	today := startOfToday()
+	from := today.AddDate(0, 0, -29)
+	to := today
+
+	if raw := c.Query("from"); raw != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", raw, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date. Use YYYY-MM-DD"})
+			return from, to, false
+		}
+		from = parsed
+	}
+	if raw := c.Query("to"); raw != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", raw, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date. Use YYYY-MM-DD"})
+			return from, to, false
+		}
+		to = parsed
+	}
+
+	return from, to.AddDate(0, 0, 1), true
+}
+
+// openImportFile opens the uploaded "file" form field, or the file named by
+// the environment variable when nothing is uploaded.
+func openImportFile(c *gin.Context, envVar string) (io.ReadCloser, bool) {
+	if header, err := c.FormFile("file"); err == nil {
+		file, err := header.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+			return nil, false
+		}
+		return file, true
+	}
+
+	path := os.Getenv(envVar)
+	if path == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload a file or set " + envVar})
+		return nil, false
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open " + envVar})
+		return nil, false
+	}
+	return file, true
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
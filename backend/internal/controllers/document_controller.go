@@ -0,0 +1,569 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"clinic-backend/internal/config"
+	"clinic-backend/internal/documents"
+	"clinic-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var signatureExtensions = map[string]bool{
+	".png":  true,
+	".jpg":  true,
+	".jpeg": true,
+}
+
+func GetLetterheads(c *gin.Context) {
+	var letterheads []models.Letterhead
+	if err := config.DB.Order("name ASC").Find(&letterheads).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch letterheads"})
+		return
+	}
+
+	c.JSON(http.StatusOK, letterheads)
+}
+
+func CreateLetterhead(c *gin.Context) {
+	var letterhead models.Letterhead
+	if err := c.ShouldBindJSON(&letterhead); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	letterhead.ID = 0
+
+	if strings.TrimSpace(letterhead.Hospital) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Hospital name is required"})
+		return
+	}
+
+	if err := saveLetterhead(&letterhead); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create letterhead"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, letterhead)
+}
+
+func UpdateLetterhead(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid letterhead ID"})
+		return
+	}
+
+	var letterhead models.Letterhead
+	if err := config.DB.First(&letterhead, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Letterhead not found"})
+		return
+	}
+
+	if err := c.ShouldBindJSON(&letterhead); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	letterhead.ID = uint(id)
+
+	if strings.TrimSpace(letterhead.Hospital) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Hospital name is required"})
+		return
+	}
+
+	if err := saveLetterhead(&letterhead); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update letterhead"})
+		return
+	}
+
+	c.JSON(http.StatusOK, letterhead)
+}
+
+func DeleteLetterhead(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid letterhead ID"})
+		return
+	}
+
+	if err := config.DB.Delete(&models.Letterhead{}, id).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete letterhead"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Letterhead deleted successfully"})
+}
+
+// UploadDoctorSignature stores the scanned signature printed on the doctor's
+// documents. Doctors may only upload their own.
+func UploadDoctorSignature(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid doctor ID"})
+		return
+	}
+
+	userRole, _ := c.Get("userRole")
+	if userRole == "doctor" && currentUserID(c) != uint(id) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
+	var doctor models.Doctor
+	if err := config.DB.First(&doctor, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Doctor not found"})
+		return
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload the signature as file"})
+		return
+	}
+	ext := strings.ToLower(filepath.Ext(header.Filename))
+	if !signatureExtensions[ext] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Signature must be a PNG or JPEG image"})
+		return
+	}
+
+	dir := os.Getenv("SIGNATURE_DIR")
+	if dir == "" {
+		dir = filepath.Join("uploads", "signatures")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store signature"})
+		return
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("doctor-%d%s", doctor.ID, ext))
+	if err := c.SaveUploadedFile(header, path); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store signature"})
+		return
+	}
+
+	if err := config.DB.Model(&doctor).Update("signature_path", path).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store signature"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Signature uploaded successfully"})
+}
+
+// VerifyDocument checks the QR code printed on a document. It is public, so
+// it only confirms authenticity and reveals no clinical content.
+func VerifyDocument(c *gin.Context) {
+	kind := c.Query("kind")
+	id, err := strconv.ParseUint(c.Query("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid document ID"})
+		return
+	}
+	revision, _ := strconv.Atoi(c.Query("rev"))
+
+	expected := documents.VerificationCode(documentSecret(), kind, uint(id), revision)
+	if !hmac.Equal([]byte(expected), []byte(c.Query("code"))) {
+		c.JSON(http.StatusOK, gin.H{"valid": false})
+		return
+	}
+
+	var (
+		date      time.Time
+		patientID uint
+		doctorID  uint
+		current   = true
+		status    string
+	)
+	switch kind {
+	case "prescription":
+		var prescription models.Prescription
+		if err := config.DB.Preload("Items.Drug").First(&prescription, id).Error; err != nil {
+			c.JSON(http.StatusOK, gin.H{"valid": false})
+			return
+		}
+		date, patientID, doctorID, status = prescription.Date, prescription.PatientID, prescription.DoctorID, prescription.Status
+		current = documents.ContentRevision(prescriptionDocument(prescription)) == revision
+	case "medical-record":
+		var record models.MedicalRecord
+		if err := config.DB.First(&record, id).Error; err != nil {
+			c.JSON(http.StatusOK, gin.H{"valid": false})
+			return
+		}
+		date, patientID, doctorID, status = record.Date, record.PatientID, record.DoctorID, record.Status
+		current = record.Version == revision
+	case "visit-summary":
+		var encounter models.Encounter
+		if err := loadEncounter(&encounter, uint(id)); err != nil {
+			c.JSON(http.StatusOK, gin.H{"valid": false})
+			return
+		}
+		date, patientID, doctorID, status = encounter.StartedAt, encounter.PatientID, encounter.DoctorID, encounter.Status
+		current = documents.ContentRevision(visitSummaryDocument(encounter)) == revision
+	case "invoice":
+		var bill models.Bill
+		if err := config.DB.First(&bill, id).Error; err != nil {
+			c.JSON(http.StatusOK, gin.H{"valid": false})
+			return
+		}
+		date, patientID, status = bill.CreatedAt, bill.PatientID, bill.Status
+	case "receipt":
+		var payment models.Payment
+		if err := config.DB.First(&payment, id).Error; err != nil {
+			c.JSON(http.StatusOK, gin.H{"valid": false})
+			return
+		}
+		date, patientID, status = payment.ReceivedAt, payment.PatientID, payment.Kind
+	default:
+		c.JSON(http.StatusOK, gin.H{"valid": false})
+		return
+	}
+
+	var patient models.Patient
+	config.DB.First(&patient, patientID)
+	var doctor models.Doctor
+	config.DB.First(&doctor, doctorID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"valid":    true,
+		"kind":     kind,
+		"id":       id,
+		"date":     date.Format("2006-01-02"),
+		"status":   status,
+		"current":  current, // false when the document has changed since it was printed
+		"doctor":   doctor.Name,
+		"patient":  initials(patient.Name),
+		"issuedBy": letterheadFor(c).Name,
+	})
+}
+
+// wantsPDF reports whether the client asked for a PDF, with ?format=pdf or
+// an Accept header.
+func wantsPDF(c *gin.Context) bool {
+	return c.Query("format") == "pdf" || strings.Contains(c.GetHeader("Accept"), "application/pdf")
+}
+
+func renderPrescriptionPDF(c *gin.Context, prescription models.Prescription) {
+	doc := prescriptionDocument(prescription)
+	doc.VerifyURL = verifyURL("prescription", prescription.ID, documents.ContentRevision(doc))
+	sendPDF(c, fmt.Sprintf("prescription-%d.pdf", prescription.ID), doc)
+}
+
+// prescriptionDocument lays out a prescription, whose items and their drugs
+// must be loaded.
+func prescriptionDocument(prescription models.Prescription) documents.Document {
+	table := &documents.Table{
+		Columns: []string{"Medicine", "Dose", "Frequency", "Route", "Duration", "Qty", "Instructions"},
+		Widths:  []float64{3.2, 1.2, 1.2, 1, 1.1, 0.8, 2.5},
+	}
+	for _, item := range prescription.Items {
+		medicine := strings.Join(strings.Fields(item.Drug.GenericName+" "+item.Drug.Strength+" "+item.Drug.Form), " ")
+		if item.Drug.BrandName != "" {
+			medicine += " (" + item.Drug.BrandName + ")"
+		}
+		duration := ""
+		if item.DurationDays > 0 {
+			duration = fmt.Sprintf("%d days", item.DurationDays)
+		}
+		table.Rows = append(table.Rows, []string{
+			medicine,
+			formatQuantity(item.Dose) + " " + item.DoseUnit,
+			item.Frequency,
+			item.Route,
+			duration,
+			formatQuantity(item.Quantity),
+			item.Instructions,
+		})
+	}
+
+	sections := []documents.Section{{Heading: "Rx", Table: table}}
+	if len(prescription.Items) == 0 {
+		sections[0] = documents.Section{Heading: "Rx", Body: prescription.MedicineName + "\n" + prescription.Dosage}
+	}
+	if prescription.Instructions != "" {
+		sections = append(sections, documents.Section{Heading: "Instructions", Body: prescription.Instructions})
+	}
+
+	return documents.Document{
+		Title:     "Prescription",
+		Reference: fmt.Sprintf("RX-%06d", prescription.ID),
+		Date:      prescription.Date,
+		Fields:    patientFields(prescription.Patient, prescription.Doctor),
+		Sections:  sections,
+		Signature: doctorSignature(prescription.Doctor, &prescription.CreatedAt),
+	}
+}
+
+func renderMedicalRecordPDF(c *gin.Context, record models.MedicalRecord) {
+	var sections []documents.Section
+
+	if len(record.Diagnoses) > 0 {
+		table := &documents.Table{Columns: []string{"Code", "Diagnosis", "Status"}, Widths: []float64{1, 4, 1.2}}
+		for _, diagnosis := range record.Diagnoses {
+			description := diagnosis.Description
+			if diagnosis.Primary {
+				description += " (primary)"
+			}
+			table.Rows = append(table.Rows, []string{diagnosis.Code, description, diagnosis.Status})
+		}
+		sections = append(sections, documents.Section{Heading: "Diagnoses", Table: table})
+	} else if record.Diagnosis != "" {
+		sections = append(sections, documents.Section{Heading: "Diagnosis", Body: record.Diagnosis})
+	}
+	if record.Notes != "" {
+		sections = append(sections, documents.Section{Heading: "Clinical notes", Body: record.Notes})
+	}
+	if record.Prescription != "" {
+		sections = append(sections, documents.Section{Heading: "Treatment", Body: record.Prescription})
+	}
+	for _, addendum := range record.Addenda {
+		heading := "Addendum, " + addendum.CreatedAt.Format("02 Jan 2006 15:04")
+		body := addendum.Content
+		if addendum.Reason != "" {
+			body = "Reason: " + addendum.Reason + "\n" + body
+		}
+		sections = append(sections, documents.Section{Heading: heading, Body: body})
+	}
+
+	doc := documents.Document{
+		Title:     "Medical Record",
+		Reference: fmt.Sprintf("MR-%06d v%d", record.ID, record.Version),
+		Date:      record.Date,
+		Fields:    patientFields(record.Patient, record.Doctor),
+		Sections:  sections,
+		Signature: doctorSignature(record.Doctor, record.SignedAt),
+		VerifyURL: verifyURL("medical-record", record.ID, record.Version),
+	}
+	if record.Status == "Draft" {
+		doc.Watermark = "DRAFT"
+	}
+	sendPDF(c, fmt.Sprintf("medical-record-%d.pdf", record.ID), doc)
+}
+
+func renderVisitSummaryPDF(c *gin.Context, encounter models.Encounter) {
+	doc := visitSummaryDocument(encounter)
+	doc.VerifyURL = verifyURL("visit-summary", encounter.ID, documents.ContentRevision(doc))
+	sendPDF(c, fmt.Sprintf("visit-summary-%d.pdf", encounter.ID), doc)
+}
+
+// visitSummaryDocument lays out an encounter loaded by loadEncounter.
+func visitSummaryDocument(encounter models.Encounter) documents.Document {
+	fields := patientFields(encounter.Patient, encounter.Doctor)
+	fields = append(fields, documents.Field{Label: "Visit type", Value: encounter.Type})
+	if encounter.EndedAt != nil {
+		fields = append(fields, documents.Field{Label: "Discharged", Value: encounter.EndedAt.Format("02 Jan 2006 15:04")})
+	}
+
+	var sections []documents.Section
+	if encounter.ChiefComplaint != "" {
+		sections = append(sections, documents.Section{Heading: "Chief complaint", Body: encounter.ChiefComplaint})
+	}
+
+	if len(encounter.VitalSigns) > 0 {
+		table := &documents.Table{Columns: []string{"Time", "Measurement", "Value", "Flag"}, Widths: []float64{1.5, 2, 1.5, 1}}
+		for _, vital := range encounter.VitalSigns {
+			table.Rows = append(table.Rows, []string{
+				vital.RecordedAt.Format("15:04"),
+				vitalLabel(vital.Parameter),
+				formatQuantity(vital.Value) + " " + vital.Unit,
+				vital.Flag,
+			})
+		}
+		sections = append(sections, documents.Section{Heading: "Vital signs", Table: table})
+	} else if encounter.Vitals != "" {
+		sections = append(sections, documents.Section{Heading: "Vital signs", Body: encounter.Vitals})
+	}
+
+	for _, record := range encounter.MedicalRecords {
+		var parts []string
+		if record.Diagnosis != "" {
+			parts = append(parts, "Diagnosis: "+record.Diagnosis)
+		}
+		if record.Notes != "" {
+			parts = append(parts, record.Notes)
+		}
+		sections = append(sections, documents.Section{Heading: "Assessment", Body: strings.Join(parts, "\n\n")})
+	}
+
+	var medicines []string
+	for _, prescription := range encounter.Prescriptions {
+		for _, item := range prescription.Items {
+			line := fmt.Sprintf("%s %s - %s %s %s", item.Drug.GenericName, item.Drug.Strength, formatQuantity(item.Dose), item.DoseUnit, item.Frequency)
+			if item.DurationDays > 0 {
+				line += fmt.Sprintf(" for %d days", item.DurationDays)
+			}
+			medicines = append(medicines, "- "+line)
+		}
+	}
+	if len(medicines) > 0 {
+		sections = append(sections, documents.Section{Heading: "Medication", Body: strings.Join(medicines, "\n")})
+	}
+
+	if encounter.Notes != "" {
+		sections = append(sections, documents.Section{Heading: "Notes", Body: encounter.Notes})
+	}
+
+	return documents.Document{
+		Title:     "Visit Summary",
+		Reference: fmt.Sprintf("VS-%06d", encounter.ID),
+		Date:      encounter.StartedAt,
+		Fields:    fields,
+		Sections:  sections,
+		Signature: doctorSignature(encounter.Doctor, nil),
+	}
+}
+
+func sendPDF(c *gin.Context, filename string, doc documents.Document) {
+	doc.Letterhead = letterheadFor(c)
+
+	var buf bytes.Buffer
+	if err := documents.Render(&buf, doc); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render PDF"})
+		return
+	}
+
+	c.Header("Content-Disposition", `inline; filename="`+filename+`"`)
+	c.Data(http.StatusOK, "application/pdf", buf.Bytes())
+}
+
+// letterheadFor picks the letterhead from ?letterheadId=, else the default
+// template, else the HOSPITAL_* environment variables.
+func letterheadFor(c *gin.Context) documents.Letterhead {
+	var letterhead models.Letterhead
+	found := false
+	if id := c.Query("letterheadId"); id != "" {
+		found = config.DB.First(&letterhead, id).Error == nil
+	}
+	if !found {
+		found = config.DB.Where("is_default = ?", true).First(&letterhead).Error == nil
+	}
+
+	if !found {
+		name := os.Getenv("HOSPITAL_NAME")
+		if name == "" {
+			name = "Clinic"
+		}
+		return documents.Letterhead{
+			Name:        name,
+			Address:     os.Getenv("HOSPITAL_ADDRESS"),
+			Phone:       os.Getenv("HOSPITAL_PHONE"),
+			Email:       os.Getenv("HOSPITAL_EMAIL"),
+			Website:     os.Getenv("HOSPITAL_WEBSITE"),
+			TaxNumber:   os.Getenv("HOSPITAL_TAX_NUMBER"),
+			LogoPath:    os.Getenv("HOSPITAL_LOGO"),
+			AccentColor: os.Getenv("HOSPITAL_COLOR"),
+		}
+	}
+
+	return documents.Letterhead{
+		Name:        letterhead.Hospital,
+		Tagline:     letterhead.Tagline,
+		Address:     letterhead.Address,
+		Phone:       letterhead.Phone,
+		Email:       letterhead.Email,
+		Website:     letterhead.Website,
+		TaxNumber:   letterhead.TaxNumber,
+		LogoPath:    letterhead.LogoPath,
+		Footer:      letterhead.Footer,
+		AccentColor: letterhead.AccentColor,
+	}
+}
+
+// saveLetterhead stores the letterhead, making it the only default when
+// flagged as default.
+func saveLetterhead(letterhead *models.Letterhead) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(letterhead).Error; err != nil {
+			return err
+		}
+		if !letterhead.IsDefault {
+			return nil
+		}
+		return tx.Model(&models.Letterhead{}).Where("id <> ?", letterhead.ID).Update("is_default", false).Error
+	})
+}
+
+func patientFields(patient models.Patient, doctor models.Doctor) []documents.Field {
+	fields := []documents.Field{
+		{Label: "Patient", Value: patient.Name},
+		{Label: "Patient ID", Value: fmt.Sprintf("P-%06d", patient.ID)},
+	}
+	if patient.Age > 0 || patient.Gender != "" {
+		fields = append(fields, documents.Field{Label: "Age / Sex", Value: strings.Trim(fmt.Sprintf("%d / %s", patient.Age, patient.Gender), " /")})
+	}
+	if patient.Phone != "" {
+		fields = append(fields, documents.Field{Label: "Phone", Value: patient.Phone})
+	}
+	fields = append(fields, documents.Field{Label: "Doctor", Value: "Dr. " + doctor.Name})
+	if doctor.Specialization != "" {
+		fields = append(fields, documents.Field{Label: "Department", Value: doctor.Specialization})
+	}
+	return fields
+}
+
+func doctorSignature(doctor models.Doctor, signedAt *time.Time) *documents.Signature {
+	title := doctor.Qualifications
+	if doctor.Specialization != "" {
+		title = strings.Trim(title+", "+doctor.Specialization, ", ")
+	}
+	return &documents.Signature{
+		Name:         "Dr. " + doctor.Name,
+		Title:        title,
+		Registration: doctor.RegistrationNumber,
+		ImagePath:    doctor.SignaturePath,
+		SignedAt:     signedAt,
+	}
+}
+
+// verifyURL builds the public verification link encoded in the QR code,
+// based on PUBLIC_BASE_URL.
+func verifyURL(kind string, id uint, revision int) string {
+	query := url.Values{}
+	query.Set("kind", kind)
+	query.Set("id", strconv.FormatUint(uint64(id), 10))
+	query.Set("rev", strconv.Itoa(revision))
+	query.Set("code", documents.VerificationCode(documentSecret(), kind, id, revision))
+	return publicBaseURL() + "/documents/verify?" + query.Encode()
+}
+
+// publicBaseURL is where the API is reachable from outside, PUBLIC_BASE_URL
+// or http://localhost:8080 by default.
+func publicBaseURL() string {
+	if base := strings.TrimRight(os.Getenv("PUBLIC_BASE_URL"), "/"); base != "" {
+		return base
+	}
+	return "http://localhost:8080"
+}
+
+// documentSecret signs verification codes. DOCUMENT_SECRET falls back to
+// the JWT secret.
+func documentSecret() string {
+	if secret := os.Getenv("DOCUMENT_SECRET"); secret != "" {
+		return secret
+	}
+	return os.Getenv("JWT_SECRET")
+}
+
+func vitalLabel(parameter string) string {
+	if p, ok := vitalParameters[parameter]; ok {
+		return p.Label
+	}
+	return parameter
+}
+
+// initials shortens a name to its initials, e.g. "Jane Doe" to "J. D.".
+func initials(name string) string {
+	var parts []string
+	for _, word := range strings.Fields(name) {
+		parts = append(parts, strings.ToUpper(string([]rune(word)[:1]))+".")
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,224 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"clinic-backend/internal/config"
+	"clinic-backend/internal/formulary"
+	"clinic-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+// ImportDrugs loads the formulary from an uploaded CSV file, or from the file
+// at FORMULARY_FILE when nothing is uploaded. Existing products are updated.
+func ImportDrugs(c *gin.Context) {
+	reader, ok := openImportFile(c, "FORMULARY_FILE")
+	if !ok {
+		return
+	}
+	defer reader.Close()
+
+	entries, err := formulary.Parse(reader)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Products already in the formulary keep their stored spelling, so a line
+	// that differs only in case updates them instead of adding a duplicate
+	var stored []models.Drug
+	if err := config.DB.Select("generic_name", "strength", "form", "route").Find(&stored).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import drugs"})
+		return
+	}
+	known := make(map[string]models.Drug, len(stored))
+	for _, drug := range stored {
+		known[drugKey(drug)] = drug
+	}
+
+	// Later lines win when a file lists a product twice
+	index := map[string]int{}
+	var drugs []models.Drug
+	for _, entry := range entries {
+		drug := models.Drug{
+			Code:        entry.Code,
+			GenericName: entry.GenericName,
+			BrandName:   entry.BrandName,
+			Strength:    entry.Strength,
+			Form:        entry.Form,
+			Route:       entry.Route,
+		}
+		key := drugKey(drug)
+		if existing, ok := known[key]; ok {
+			drug.GenericName, drug.Strength, drug.Form, drug.Route = existing.GenericName, existing.Strength, existing.Form, existing.Route
+		}
+		if i, seen := index[key]; seen {
+			drugs[i] = drug
+			continue
+		}
+		index[key] = len(drugs)
+		drugs = append(drugs, drug)
+	}
+
+	if len(drugs) > 0 {
+		if err := config.DB.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "generic_name"}, {Name: "strength"}, {Name: "form"}, {Name: "route"}},
+			DoUpdates: clause.AssignmentColumns([]string{"code", "brand_name", "updated_at"}),
+		}).CreateInBatches(&drugs, 1000).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import drugs"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Drugs imported successfully", "count": len(drugs)})
+}
+
+// drugKey identifies a product regardless of case.
+func drugKey(drug models.Drug) string {
+	return strings.ToLower(strings.Join([]string{drug.GenericName, drug.Strength, drug.Form, drug.Route}, "|"))
+}
+
+// GetDrugs lists the formulary, searching generic and brand names with ?q=.
+// Discontinued drugs are left out unless ?all=true.
+func GetDrugs(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 500 {
+		limit = 50
+	}
+
+	var drugs []models.Drug
+	query := config.DB.Order("generic_name ASC, strength ASC").Limit(limit)
+
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		pattern := "%" + escapeLike(q) + "%"
+		query = query.Where("generic_name ILIKE ? OR brand_name ILIKE ? OR code = ?", pattern, pattern, q)
+	}
+
+	if form := c.Query("form"); form != "" {
+		query = query.Where("form = ?", form)
+	}
+
+	if c.Query("all") != "true" {
+		query = query.Where("discontinued = ?", false)
+	}
+
+	if err := query.Find(&drugs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch drugs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, drugs)
+}
+
+func GetDrugByID(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid drug ID"})
+		return
+	}
+
+	var drug models.Drug
+	if err := config.DB.First(&drug, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Drug not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, drug)
+}
+
+func CreateDrug(c *gin.Context) {
+	var drug models.Drug
+	if err := c.ShouldBindJSON(&drug); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	drug.ID = 0
+
+	if err := validateDrug(&drug); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := config.DB.Create(&drug).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create drug"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, drug)
+}
+
+func UpdateDrug(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid drug ID"})
+		return
+	}
+
+	var drug models.Drug
+	if err := config.DB.First(&drug, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Drug not found"})
+		return
+	}
+
+	if err := c.ShouldBindJSON(&drug); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	drug.ID = uint(id)
+
+	if err := validateDrug(&drug); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := config.DB.Save(&drug).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update drug"})
+		return
+	}
+
+	c.JSON(http.StatusOK, drug)
+}
+
+// DeleteDrug removes an unused drug; drugs already prescribed are
+// discontinued instead so past prescriptions keep their product.
+func DeleteDrug(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid drug ID"})
+		return
+	}
+
+	var used int64
+	config.DB.Model(&models.PrescriptionItem{}).Where("drug_id = ?", id).Count(&used)
+	if used > 0 {
+		if err := config.DB.Model(&models.Drug{}).Where("id = ?", id).Update("discontinued", true).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to discontinue drug"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Drug is on existing prescriptions and was discontinued"})
+		return
+	}
+
+	if err := config.DB.Delete(&models.Drug{}, id).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete drug"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Drug deleted successfully"})
+}
+
+func validateDrug(drug *models.Drug) error {
+	drug.GenericName = strings.TrimSpace(drug.GenericName)
+	if drug.GenericName == "" {
+		return errors.New("Generic name is required")
+	}
+	drug.Strength = strings.TrimSpace(drug.Strength)
+	drug.Form = strings.TrimSpace(drug.Form)
+	drug.Route = strings.TrimSpace(drug.Route)
+	return nil
+}
@@ -0,0 +1,308 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"clinic-backend/internal/config"
+	"clinic-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var validEncounterTypes = map[string]bool{
+	"Outpatient": true,
+	"Admission":  true,
+	"Emergency":  true,
+}
+
+func CreateEncounter(c *gin.Context) {
+	var encounter models.Encounter
+	if err := c.ShouldBindJSON(&encounter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !validEncounterTypes[encounter.Type] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid encounter type. Must be: Outpatient, Admission, or Emergency"})
+		return
+	}
+
+	// Verify patient exists
+	var patient models.Patient
+	if err := config.DB.First(&patient, encounter.PatientID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Patient not found"})
+		return
+	}
+
+	// Verify doctor exists
+	var doctor models.Doctor
+	if err := config.DB.First(&doctor, encounter.DoctorID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Doctor not found"})
+		return
+	}
+
+	// Verify appointment belongs to the patient and has no encounter yet
+	if encounter.AppointmentID != nil {
+		var appointment models.Appointment
+		if err := config.DB.First(&appointment, *encounter.AppointmentID).Error; err != nil || appointment.PatientID != encounter.PatientID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Appointment not found for patient"})
+			return
+		}
+		var existing int64
+		config.DB.Model(&models.Encounter{}).Where("appointment_id = ?", appointment.ID).Count(&existing)
+		if existing > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Appointment already has an encounter"})
+			return
+		}
+	}
+
+	encounter.Status = "Open"
+	encounter.EndedAt = nil
+	encounter.Vitals = ""
+	if encounter.StartedAt.IsZero() {
+		encounter.StartedAt = time.Now()
+	}
+
+	if err := config.DB.Omit(clause.Associations).Create(&encounter).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create encounter"})
+		return
+	}
+
+	loadEncounter(&encounter, encounter.ID)
+	c.JSON(http.StatusCreated, encounter)
+}
+
+func GetEncounters(c *gin.Context) {
+	var encounters []models.Encounter
+	query := config.DB.Preload("Patient").Preload("Doctor").Order("started_at DESC")
+
+	// Filter by patient if provided
+	if patientID := c.Query("patientId"); patientID != "" {
+		query = query.Where("patient_id = ?", patientID)
+	}
+
+	// Filter by doctor if provided
+	if doctorID := c.Query("doctorId"); doctorID != "" {
+		query = query.Where("doctor_id = ?", doctorID)
+	}
+
+	// Filter by appointment if provided
+	if appointmentID := c.Query("appointmentId"); appointmentID != "" {
+		query = query.Where("appointment_id = ?", appointmentID)
+	}
+
+	// Filter by type if provided
+	if encounterType := c.Query("type"); encounterType != "" {
+		query = query.Where("type = ?", encounterType)
+	}
+
+	// Filter by status if provided
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Find(&encounters).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch encounters"})
+		return
+	}
+
+	c.JSON(http.StatusOK, encounters)
+}
+
+// GetEncounterByID returns the encounter with everything documented during it.
+func GetEncounterByID(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid encounter ID"})
+		return
+	}
+
+	var encounter models.Encounter
+	if err := loadEncounter(&encounter, uint(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Encounter not found"})
+		return
+	}
+
+	if wantsPDF(c) {
+		renderVisitSummaryPDF(c, encounter)
+		return
+	}
+
+	c.JSON(http.StatusOK, encounter)
+}
+
+func UpdateEncounter(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid encounter ID"})
+		return
+	}
+
+	var encounter models.Encounter
+	if err := config.DB.First(&encounter, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Encounter not found"})
+		return
+	}
+
+	previous := encounter
+
+	if err := c.ShouldBindJSON(&encounter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !validEncounterTypes[encounter.Type] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid encounter type. Must be: Outpatient, Admission, or Emergency"})
+		return
+	}
+
+	// Status, patient and appointment links only change through their own
+	// workflows, and vitals are recorded as vital signs
+	encounter.ID = previous.ID
+	encounter.Status = previous.Status
+	encounter.EndedAt = previous.EndedAt
+	encounter.PatientID = previous.PatientID
+	encounter.AppointmentID = previous.AppointmentID
+	encounter.Vitals = previous.Vitals
+
+	if err := config.DB.Omit(clause.Associations).Save(&encounter).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update encounter"})
+		return
+	}
+
+	loadEncounter(&encounter, encounter.ID)
+	c.JSON(http.StatusOK, encounter)
+}
+
+// CloseEncounter ends the encounter and completes its appointment.
+func CloseEncounter(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid encounter ID"})
+		return
+	}
+
+	var encounter models.Encounter
+	if err := config.DB.First(&encounter, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Encounter not found"})
+		return
+	}
+
+	if encounter.Status == "Closed" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Encounter is already closed"})
+		return
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := closeEncounter(tx, &encounter); err != nil {
+			return err
+		}
+		if encounter.AppointmentID == nil {
+			return nil
+		}
+		return tx.Model(&models.Appointment{}).
+			Where("id = ? AND status = ?", *encounter.AppointmentID, "Scheduled").
+			Updates(map[string]interface{}{
+				"status":   "Completed",
+				"sequence": gorm.Expr("sequence + 1"),
+			}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to close encounter"})
+		return
+	}
+
+	if encounter.AppointmentID != nil {
+		var appointment models.Appointment
+		if config.DB.First(&appointment, *encounter.AppointmentID).Error == nil && appointment.Status == "Completed" {
+			chargeAppointment(appointment)
+		}
+	}
+
+	loadEncounter(&encounter, encounter.ID)
+	c.JSON(http.StatusOK, encounter)
+}
+
+// StartAppointmentEncounter opens the outpatient encounter for an
+// appointment, or returns the one already open.
+func StartAppointmentEncounter(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid appointment ID"})
+		return
+	}
+
+	var appointment models.Appointment
+	if err := config.DB.First(&appointment, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Appointment not found"})
+		return
+	}
+
+	if appointment.Status == "Cancelled" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Appointment is cancelled"})
+		return
+	}
+
+	encounter, err := openAppointmentEncounter(config.DB, appointment)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open encounter"})
+		return
+	}
+
+	loadEncounter(&encounter, encounter.ID)
+	c.JSON(http.StatusOK, encounter)
+}
+
+// openAppointmentEncounter returns the appointment's encounter, creating an
+// open outpatient encounter if there is none yet.
+func openAppointmentEncounter(tx *gorm.DB, appointment models.Appointment) (models.Encounter, error) {
+	var encounter models.Encounter
+	err := tx.Where("appointment_id = ?", appointment.ID).First(&encounter).Error
+	if err == nil {
+		return encounter, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return encounter, err
+	}
+
+	encounter = models.Encounter{
+		Type:           "Outpatient",
+		Status:         "Open",
+		PatientID:      appointment.PatientID,
+		DoctorID:       appointment.DoctorID,
+		AppointmentID:  &appointment.ID,
+		ChiefComplaint: appointment.Notes,
+		StartedAt:      time.Now(),
+	}
+	err = tx.Create(&encounter).Error
+	return encounter, err
+}
+
+func closeEncounter(tx *gorm.DB, encounter *models.Encounter) error {
+	if encounter.Status == "Closed" {
+		return nil
+	}
+	now := time.Now()
+	encounter.Status = "Closed"
+	encounter.EndedAt = &now
+	return tx.Model(encounter).Updates(map[string]interface{}{
+		"status":   encounter.Status,
+		"ended_at": encounter.EndedAt,
+	}).Error
+}
+
+func loadEncounter(encounter *models.Encounter, id uint) error {
+	err := config.DB.Preload("Patient").Preload("Doctor").Preload("Appointment").
+		Preload("MedicalRecords.Diagnoses").Preload("Prescriptions.Items.Drug").Preload("Bills").Preload("VitalSigns").
+		First(encounter, id).Error
+
+	encounter.Diagnoses = nil
+	for _, record := range encounter.MedicalRecords {
+		encounter.Diagnoses = append(encounter.Diagnoses, record.Diagnoses...)
+	}
+	return err
+}
@@ -0,0 +1,482 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"clinic-backend/internal/config"
+	"clinic-backend/internal/gateway"
+	"clinic-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// checkoutLifetime is how long a checkout link can be paid.
+const checkoutLifetime = 24 * time.Hour
+
+var paymentProvider gateway.Provider
+
+// SetPaymentProvider configures the provider online payments go through.
+func SetPaymentProvider(provider gateway.Provider) {
+	paymentProvider = provider
+}
+
+// FakeCheckoutEnabled reports whether payments go through the fake provider,
+// whose stand-in checkout pages are then served.
+func FakeCheckoutEnabled() bool {
+	_, ok := paymentProvider.(*gateway.FakeProvider)
+	return ok
+}
+
+// CreateCheckout opens an online payment page for what the patient owes on a
+// bill, or for part of it.
+func CreateCheckout(c *gin.Context) {
+	if paymentProvider == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Online payments are not configured"})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bill ID"})
+		return
+	}
+
+	var input struct {
+		Amount    *decimal.Decimal `json:"amount"`
+		Email     string           `json:"email"`
+		ReturnURL string           `json:"returnUrl"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var bill models.Bill
+	if err := config.DB.Preload("Patient").First(&bill, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bill not found"})
+		return
+	}
+	if bill.Status == "Paid" || bill.Status == "WrittenOff" || bill.Status == "Void" || !bill.PatientBalance.IsPositive() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Bill has nothing for the patient to pay"})
+		return
+	}
+
+	amount := bill.PatientBalance
+	if input.Amount != nil {
+		amount = *input.Amount
+	}
+	currency, err := billCurrency(bill.Currency)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	switch {
+	case !amount.IsPositive():
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Amount must be positive"})
+		return
+	case amount.GreaterThan(bill.PatientBalance):
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Amount exceeds the outstanding balance of %s %s", bill.PatientBalance.String(), bill.Currency)})
+		return
+	case !amount.Equal(amount.Round(currency.Decimals)):
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s amounts have at most %d decimals", currency.Code, currency.Decimals)})
+		return
+	}
+
+	session := models.CheckoutSession{
+		BillID:    bill.ID,
+		PatientID: bill.PatientID,
+		Provider:  paymentProvider.Name(),
+		Amount:    amount,
+		Currency:  bill.Currency,
+		Status:    "Open",
+		ExpiresAt: time.Now().Add(checkoutLifetime),
+		CreatedBy: currentUserID(c),
+	}
+	if err := config.DB.Create(&session).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create checkout"})
+		return
+	}
+	session.Reference = fmt.Sprintf("CS-%06d", session.ID)
+
+	email := strings.TrimSpace(input.Email)
+	if email == "" {
+		email = bill.Patient.Email
+	}
+	checkout, err := paymentProvider.CreateCheckout(c.Request.Context(), gateway.CheckoutRequest{
+		Reference:   session.Reference,
+		Amount:      amount,
+		Currency:    bill.Currency,
+		Description: "Invoice " + bill.InvoiceNumber,
+		Email:       email,
+		ReturnURL:   input.ReturnURL,
+		ExpiresAt:   session.ExpiresAt,
+	})
+	if err != nil {
+		log.Printf("Failed to create checkout for bill %d: %v", bill.ID, err)
+		config.DB.Model(&session).Updates(map[string]interface{}{"reference": session.Reference, "status": "Failed"})
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Payment provider is unavailable"})
+		return
+	}
+
+	session.ProviderSessionID = checkout.SessionID
+	session.URL = checkout.URL
+	if err := config.DB.Model(&session).Updates(map[string]interface{}{
+		"reference":           session.Reference,
+		"provider_session_id": session.ProviderSessionID,
+		"url":                 session.URL,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create checkout"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, session)
+}
+
+func GetBillCheckouts(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bill ID"})
+		return
+	}
+
+	var sessions []models.CheckoutSession
+	if err := config.DB.Where("bill_id = ?", id).Order("created_at DESC").Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch checkouts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// PaymentWebhook receives payment notifications from the provider. Only
+// correctly signed events are accepted; events seen before are acknowledged
+// without being applied again.
+func PaymentWebhook(c *gin.Context) {
+	if paymentProvider == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Online payments are not configured"})
+		return
+	}
+
+	payload, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read webhook"})
+		return
+	}
+
+	event, err := paymentProvider.ParseWebhook(payload, c.Request.Header)
+	if errors.Is(err, gateway.ErrInvalidSignature) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook payload"})
+		return
+	}
+
+	if err := applyGatewayEvent(event, payload); err != nil {
+		log.Printf("Failed to apply payment event %s: %v", event.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process webhook"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"received": true})
+}
+
+// GetFakeCheckout shows what a checkout session of the fake provider is for.
+// It stands in for the provider's hosted payment page.
+func GetFakeCheckout(c *gin.Context) {
+	fake, ok := paymentProvider.(*gateway.FakeProvider)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Checkout not found"})
+		return
+	}
+
+	req, found := fake.Session(c.Param("session"))
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Checkout not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"session":     c.Param("session"),
+		"reference":   req.Reference,
+		"description": req.Description,
+		"amount":      req.Amount,
+		"currency":    req.Currency,
+		"expiresAt":   req.ExpiresAt,
+	})
+}
+
+// CompleteFakeCheckout pays or declines a fake checkout, {"succeed": false}
+// to decline, and delivers the signed webhook through the same verification
+// as real ones.
+func CompleteFakeCheckout(c *gin.Context) {
+	fake, ok := paymentProvider.(*gateway.FakeProvider)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Checkout not found"})
+		return
+	}
+
+	var input struct {
+		Succeed *bool `json:"succeed"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	succeed := input.Succeed == nil || *input.Succeed
+
+	payload, header, err := fake.Complete(c.Param("session"), succeed)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	event, err := fake.ParseWebhook(payload, header)
+	if err == nil {
+		err = applyGatewayEvent(event, payload)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process payment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, event)
+}
+
+func GetReconciliations(c *gin.Context) {
+	var runs []models.GatewayReconciliation
+	if err := config.DB.Order("created_at DESC").Limit(100).Find(&runs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reconciliations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, runs)
+}
+
+// RunReconciliation reconciles online payments now rather than waiting for
+// the daily run.
+func RunReconciliation(c *gin.Context) {
+	if paymentProvider == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Online payments are not configured"})
+		return
+	}
+
+	run, err := reconcilePayments(time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to reconcile payments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, run)
+}
+
+// ReconcilePayments compares the provider's settled transactions of the last
+// two days with the payments recorded from webhooks, records payments whose
+// webhook never arrived, and expires unpaid checkouts.
+func ReconcilePayments() {
+	if paymentProvider == nil {
+		return
+	}
+	if _, err := reconcilePayments(time.Now()); err != nil {
+		log.Println("Failed to reconcile payments:", err)
+	}
+}
+
+func reconcilePayments(now time.Time) (models.GatewayReconciliation, error) {
+	run := models.GatewayReconciliation{
+		Provider: paymentProvider.Name(),
+		From:     startOfToday().AddDate(0, 0, -1),
+		To:       now,
+	}
+
+	transactions, err := paymentProvider.Transactions(context.Background(), run.From, run.To)
+	if err != nil {
+		return run, err
+	}
+
+	var notes []string
+	seen := map[string]bool{}
+	for _, t := range transactions {
+		if !t.Succeeded {
+			continue
+		}
+		run.Transactions++
+		seen[t.ID] = true
+
+		var session models.CheckoutSession
+		if err := config.DB.Where("provider = ? AND provider_session_id = ?", run.Provider, t.SessionID).First(&session).Error; err != nil {
+			run.Mismatched++
+			notes = append(notes, fmt.Sprintf("Transaction %s has no checkout session", t.ID))
+			continue
+		}
+
+		if session.Status != "Paid" {
+			err := config.DB.Transaction(func(tx *gorm.DB) error {
+				return settleCheckout(tx, session.ID, t.ID, t.Amount, t.Currency, t.At)
+			})
+			if err != nil {
+				run.Mismatched++
+				notes = append(notes, fmt.Sprintf("Transaction %s could not be recorded: %v", t.ID, err))
+				continue
+			}
+			run.Recovered++
+			continue
+		}
+
+		// Sessions paid before the settled amount was kept have it on their receipt
+		paidAmount, receipt := session.PaidAmount, "checkout "+session.Reference
+		if session.PaymentID != nil {
+			var payment models.Payment
+			config.DB.First(&payment, *session.PaymentID)
+			paidAmount, receipt = payment.Amount, "receipt "+payment.ReceiptNumber
+		}
+		if session.TransactionID != t.ID || !paidAmount.Equal(t.Amount) || session.Currency != t.Currency {
+			run.Mismatched++
+			notes = append(notes, fmt.Sprintf("Transaction %s of %s %s does not match %s", t.ID, t.Amount.String(), t.Currency, receipt))
+			continue
+		}
+		run.Matched++
+	}
+
+	// Payments recorded for transactions the provider does not report
+	var paid []models.CheckoutSession
+	config.DB.Where("provider = ? AND status = ? AND paid_at >= ? AND paid_at < ?", run.Provider, "Paid", run.From, run.To).Find(&paid)
+	for _, session := range paid {
+		if !seen[session.TransactionID] {
+			run.Mismatched++
+			notes = append(notes, fmt.Sprintf("Checkout %s was recorded as paid by transaction %s, which the provider does not report", session.Reference, session.TransactionID))
+		}
+	}
+
+	expired := config.DB.Model(&models.CheckoutSession{}).
+		Where("status = ? AND expires_at < ?", "Open", now).
+		Update("status", "Expired")
+	if expired.Error != nil {
+		return run, expired.Error
+	}
+	run.Expired = int(expired.RowsAffected)
+
+	run.Notes = strings.Join(notes, "\n")
+	return run, config.DB.Create(&run).Error
+}
+
+// applyGatewayEvent stores a verified event and applies it to its checkout
+// session. Events already stored are skipped.
+func applyGatewayEvent(event gateway.Event, payload []byte) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		stored := models.GatewayEvent{
+			Provider:   paymentProvider.Name(),
+			EventID:    event.ID,
+			Type:       event.Type,
+			SessionID:  event.SessionID,
+			Payload:    string(payload),
+			ReceivedAt: time.Now(),
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&stored)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		var session models.CheckoutSession
+		if err := tx.Where("provider = ? AND provider_session_id = ?", stored.Provider, event.SessionID).First(&session).Error; err != nil {
+			return tx.Model(&stored).Update("error", "checkout session not found").Error
+		}
+
+		var err error
+		switch event.Type {
+		case gateway.PaymentSucceeded:
+			err = settleCheckout(tx, session.ID, event.TransactionID, event.Amount, event.Currency, event.OccurredAt)
+		case gateway.PaymentFailed:
+			err = tx.Model(&models.CheckoutSession{}).
+				Where("id = ? AND status = ?", session.ID, "Open").
+				Update("status", "Failed").Error
+		}
+
+		// Payments the ledger refuses are kept on the event for follow-up
+		var rejected paymentError
+		if errors.As(err, &rejected) {
+			return tx.Model(&stored).Update("error", rejected.Error()).Error
+		}
+		return err
+	})
+}
+
+// settleCheckout records the payment of a checkout session on its bill's
+// ledger, once however often the provider reports it. Money taken online is
+// recorded even when the bill has meanwhile been paid otherwise, or for
+// another amount than asked; the excess shows as a credit balance to refund.
+// A void or written-off bill takes no more payments, so the session is
+// marked for a refund instead.
+func settleCheckout(tx *gorm.DB, sessionID uint, transactionID string, amount decimal.Decimal, currency string, at time.Time) error {
+	var session models.CheckoutSession
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&session, sessionID).Error; err != nil {
+		return err
+	}
+	if session.Status == "Paid" {
+		return nil
+	}
+	if currency != session.Currency {
+		return paymentError(fmt.Sprintf("Payment in %s does not match the checkout currency %s", currency, session.Currency))
+	}
+	if at.IsZero() {
+		at = time.Now()
+	}
+	// Late news of a payment on a day already closed is recorded today
+	closed, err := dayClosed(tx, at)
+	if err != nil {
+		return err
+	}
+	if closed {
+		at = time.Now()
+	}
+
+	var bill models.Bill
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&bill, session.BillID).Error; err != nil {
+		return err
+	}
+	if bill.Status == "Void" || bill.Status == "WrittenOff" {
+		session.Exception = fmt.Sprintf("Bill %s is %s; %s %s is to be refunded", bill.InvoiceNumber, bill.Status, amount.String(), currency)
+	} else {
+		entry := models.Payment{
+			Kind:       "Payment",
+			Method:     "Online",
+			Amount:     amount,
+			Reference:  transactionID,
+			ReceivedAt: at,
+		}
+		if err := addLedgerEntry(tx, bill.ID, &entry, func(*gorm.DB, models.Bill) error { return nil }); err != nil {
+			return err
+		}
+		session.PaymentID = &entry.ID
+		if !amount.Equal(session.Amount) {
+			session.Exception = fmt.Sprintf("Paid %s %s where %s was asked", amount.String(), currency, session.Amount.String())
+		}
+	}
+
+	session.Status = "Paid"
+	session.TransactionID = transactionID
+	session.PaidAmount = amount
+	session.PaidAt = &at
+	return tx.Model(&session).Updates(map[string]interface{}{
+		"status":         session.Status,
+		"transaction_id": session.TransactionID,
+		"payment_id":     session.PaymentID,
+		"paid_amount":    session.PaidAmount,
+		"exception":      session.Exception,
+		"paid_at":        session.PaidAt,
+	}).Error
+}
@@ -0,0 +1,434 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"clinic-backend/internal/config"
+	"clinic-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var validPolicyStatuses = map[string]bool{
+	"Active":    true,
+	"Suspended": true,
+	"Cancelled": true,
+}
+
+var validPolicyRelationships = map[string]bool{
+	"Self":   true,
+	"Spouse": true,
+	"Child":  true,
+	"Other":  true,
+}
+
+func GetInsurers(c *gin.Context) {
+	var insurers []models.Insurer
+	if err := config.DB.Preload("Plans", "discontinued = ?", false).Order("name ASC").Find(&insurers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch insurers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, insurers)
+}
+
+func CreateInsurer(c *gin.Context) {
+	var insurer models.Insurer
+	if err := c.ShouldBindJSON(&insurer); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	insurer.ID = 0
+	insurer.Plans = nil
+
+	if err := validateInsurer(&insurer); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := config.DB.Create(&insurer).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create insurer"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, insurer)
+}
+
+func UpdateInsurer(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid insurer ID"})
+		return
+	}
+
+	var insurer models.Insurer
+	if err := config.DB.First(&insurer, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Insurer not found"})
+		return
+	}
+
+	if err := c.ShouldBindJSON(&insurer); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	insurer.ID = uint(id)
+	insurer.Plans = nil
+
+	if err := validateInsurer(&insurer); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := config.DB.Save(&insurer).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update insurer"})
+		return
+	}
+
+	c.JSON(http.StatusOK, insurer)
+}
+
+func DeleteInsurer(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid insurer ID"})
+		return
+	}
+
+	var plans int64
+	config.DB.Model(&models.InsurancePlan{}).Where("insurer_id = ?", id).Count(&plans)
+	if plans > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Insurer has plans; discontinue them instead"})
+		return
+	}
+
+	if err := config.DB.Delete(&models.Insurer{}, id).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete insurer"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Insurer deleted successfully"})
+}
+
+func CreateInsurancePlan(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid insurer ID"})
+		return
+	}
+
+	var insurer models.Insurer
+	if err := config.DB.First(&insurer, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Insurer not found"})
+		return
+	}
+
+	var plan models.InsurancePlan
+	if err := c.ShouldBindJSON(&plan); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	plan.ID = 0
+	plan.InsurerID = insurer.ID
+	plan.Insurer = nil
+
+	if err := validateInsurancePlan(&plan); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := config.DB.Create(&plan).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create plan"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, plan)
+}
+
+// UpdateInsurancePlan changes a plan's coverage rules. Bills already split
+// keep their portions.
+func UpdateInsurancePlan(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid plan ID"})
+		return
+	}
+
+	var plan models.InsurancePlan
+	if err := config.DB.First(&plan, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Plan not found"})
+		return
+	}
+	insurerID := plan.InsurerID
+
+	if err := c.ShouldBindJSON(&plan); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	plan.ID = uint(id)
+	plan.InsurerID = insurerID
+	plan.Insurer = nil
+
+	if err := validateInsurancePlan(&plan); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := config.DB.Save(&plan).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update plan"})
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// DeleteInsurancePlan removes a plan, or discontinues it when patients hold
+// policies on it.
+func DeleteInsurancePlan(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid plan ID"})
+		return
+	}
+
+	var used int64
+	config.DB.Model(&models.PatientPolicy{}).Where("plan_id = ?", id).Count(&used)
+	if used > 0 {
+		if err := config.DB.Model(&models.InsurancePlan{}).Where("id = ?", id).Update("discontinued", true).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to discontinue plan"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Plan has policies and was discontinued"})
+		return
+	}
+
+	if err := config.DB.Delete(&models.InsurancePlan{}, id).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete plan"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Plan deleted successfully"})
+}
+
+// GetPatientPolicies lists the patient's insurance policies, newest first.
+func GetPatientPolicies(c *gin.Context) {
+	patient, ok := findPatient(c)
+	if !ok {
+		return
+	}
+
+	var policies []models.PatientPolicy
+	if err := config.DB.Preload("Plan.Insurer").
+		Where("patient_id = ?", patient.ID).
+		Order("valid_from DESC").
+		Find(&policies).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch policies"})
+		return
+	}
+
+	c.JSON(http.StatusOK, policies)
+}
+
+func CreatePatientPolicy(c *gin.Context) {
+	patient, ok := findPatient(c)
+	if !ok {
+		return
+	}
+
+	var policy models.PatientPolicy
+	if err := c.ShouldBindJSON(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	policy.ID = 0
+	policy.PatientID = patient.ID
+
+	if err := preparePatientPolicy(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := config.DB.Create(&policy).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add policy"})
+		return
+	}
+
+	config.DB.Preload("Plan.Insurer").First(&policy, policy.ID)
+	c.JSON(http.StatusCreated, policy)
+}
+
+func UpdatePatientPolicy(c *gin.Context) {
+	policy, ok := findPatientPolicy(c)
+	if !ok {
+		return
+	}
+
+	id, patientID, planID := policy.ID, policy.PatientID, policy.PlanID
+	if err := c.ShouldBindJSON(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	policy.ID, policy.PatientID = id, patientID
+
+	// Bills were split, and claimed, under the plan they were issued with
+	if policy.PlanID != planID {
+		var used int64
+		config.DB.Model(&models.Bill{}).Where("policy_id = ?", policy.ID).Count(&used)
+		if used > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "The plan of a policy with bills cannot be changed. Add a new policy instead"})
+			return
+		}
+	}
+
+	if err := preparePatientPolicy(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := config.DB.Omit("Plan").Save(&policy).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update policy"})
+		return
+	}
+
+	config.DB.Preload("Plan.Insurer").First(&policy, policy.ID)
+	c.JSON(http.StatusOK, policy)
+}
+
+// DeletePatientPolicy removes a policy entered by mistake. Policies bills
+// were claimed under are cancelled instead.
+func DeletePatientPolicy(c *gin.Context) {
+	policy, ok := findPatientPolicy(c)
+	if !ok {
+		return
+	}
+
+	var used int64
+	config.DB.Model(&models.Bill{}).Where("policy_id = ?", policy.ID).Count(&used)
+	if used > 0 {
+		if err := config.DB.Model(&policy).Update("status", "Cancelled").Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel policy"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Policy has bills and was cancelled"})
+		return
+	}
+
+	if err := config.DB.Delete(&policy).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete policy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Policy deleted successfully"})
+}
+
+func validateInsurer(insurer *models.Insurer) error {
+	insurer.Code = strings.ToUpper(strings.TrimSpace(insurer.Code))
+	insurer.Name = strings.TrimSpace(insurer.Name)
+	if insurer.Code == "" || insurer.Name == "" {
+		return errors.New("Insurer code and name are required")
+	}
+
+	var existing int64
+	config.DB.Model(&models.Insurer{}).Where("code = ? AND id <> ?", insurer.Code, insurer.ID).Count(&existing)
+	if existing > 0 {
+		return errors.New("Insurer code " + insurer.Code + " is already in use")
+	}
+	return nil
+}
+
+func validateInsurancePlan(plan *models.InsurancePlan) error {
+	plan.Name = strings.TrimSpace(plan.Name)
+	if plan.Name == "" {
+		return errors.New("Plan name is required")
+	}
+
+	currency, err := billCurrency(plan.Currency)
+	if err != nil {
+		return err
+	}
+	plan.Currency = currency.Code
+
+	switch {
+	case plan.Copay.IsNegative(), plan.PerBillLimit.IsNegative(), plan.AnnualLimit.IsNegative():
+		return errors.New("Copay and limits cannot be negative")
+	case plan.Coinsurance.IsNegative() || plan.Coinsurance.GreaterThan(hundred):
+		return errors.New("Coinsurance must be between 0 and 100")
+	}
+	return nil
+}
+
+func preparePatientPolicy(policy *models.PatientPolicy) error {
+	policy.PolicyNumber = strings.TrimSpace(policy.PolicyNumber)
+	if policy.PolicyNumber == "" {
+		return errors.New("Policy number is required")
+	}
+
+	var plan models.InsurancePlan
+	if err := config.DB.First(&plan, policy.PlanID).Error; err != nil {
+		return errors.New("Plan not found")
+	}
+	policy.Plan = nil
+
+	if policy.Relationship == "" {
+		policy.Relationship = "Self"
+	}
+	if !validPolicyRelationships[policy.Relationship] {
+		return errors.New("Invalid relationship " + policy.Relationship)
+	}
+	if policy.Status == "" {
+		policy.Status = "Active"
+	}
+	if !validPolicyStatuses[policy.Status] {
+		return errors.New("Invalid policy status " + policy.Status)
+	}
+	if policy.Status == "Active" && plan.Discontinued {
+		return errors.New("Plan is discontinued")
+	}
+
+	if policy.ValidFrom.IsZero() {
+		policy.ValidFrom = startOfToday()
+	}
+	if policy.ValidTo != nil && policy.ValidTo.Before(policy.ValidFrom) {
+		return errors.New("Policy cannot end before it starts")
+	}
+	return nil
+}
+
+func findPatientPolicy(c *gin.Context) (models.PatientPolicy, bool) {
+	var policy models.PatientPolicy
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid patient ID"})
+		return policy, false
+	}
+	policyID, err := strconv.ParseUint(c.Param("policyId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid policy ID"})
+		return policy, false
+	}
+
+	if err := config.DB.Where("patient_id = ?", id).First(&policy, policyID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Policy not found"})
+		return policy, false
+	}
+
+	return policy, true
+}
+
+// activePolicy returns the patient's policy in force on the day, the most
+// recently started one when several are.
+func activePolicy(tx *gorm.DB, patientID uint, day time.Time) (models.PatientPolicy, bool) {
+	var policy models.PatientPolicy
+	err := tx.
+		Joins("JOIN insurance_plans ON insurance_plans.id = patient_policies.plan_id").
+		Where("patient_policies.patient_id = ? AND patient_policies.status = ? AND insurance_plans.discontinued = ?", patientID, "Active", false).
+		Where("patient_policies.valid_from <= ? AND (patient_policies.valid_to IS NULL OR patient_policies.valid_to >= ?)", day, day).
+		Order("patient_policies.valid_from DESC, patient_policies.id DESC").
+		First(&policy).Error
+	return policy, err == nil
+}
@@ -0,0 +1,350 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"clinic-backend/internal/config"
+	"clinic-backend/internal/interactions"
+	"clinic-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+var alertSeverityRank = map[string]int{
+	"Severe":   0,
+	"Moderate": 1,
+	"Minor":    2,
+}
+
+// ImportDrugInteractions loads the interaction table from an uploaded CSV
+// file, or from INTERACTIONS_FILE. Existing pairs are updated.
+func ImportDrugInteractions(c *gin.Context) {
+	reader, ok := openImportFile(c, "INTERACTIONS_FILE")
+	if !ok {
+		return
+	}
+	defer reader.Close()
+
+	entries, err := interactions.ParseInteractions(reader)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Later lines win when a file lists a pair twice
+	index := map[[2]string]int{}
+	var rows []models.DrugInteraction
+	for _, entry := range entries {
+		row := models.DrugInteraction{DrugA: entry.DrugA, DrugB: entry.DrugB, Severity: entry.Severity, Description: entry.Description}
+		key := [2]string{entry.DrugA, entry.DrugB}
+		if i, seen := index[key]; seen {
+			rows[i] = row
+			continue
+		}
+		index[key] = len(rows)
+		rows = append(rows, row)
+	}
+
+	if len(rows) > 0 {
+		if err := config.DB.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "drug_a"}, {Name: "drug_b"}},
+			DoUpdates: clause.AssignmentColumns([]string{"severity", "description", "updated_at"}),
+		}).CreateInBatches(&rows, 1000).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import drug interactions"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Drug interactions imported successfully", "count": len(rows)})
+}
+
+// ImportAllergenClasses loads allergen class membership from an uploaded CSV
+// file, or from ALLERGEN_CLASSES_FILE.
+func ImportAllergenClasses(c *gin.Context) {
+	reader, ok := openImportFile(c, "ALLERGEN_CLASSES_FILE")
+	if !ok {
+		return
+	}
+	defer reader.Close()
+
+	entries, err := interactions.ParseClasses(reader)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	seen := map[models.AllergenClass]bool{}
+	var rows []models.AllergenClass
+	for _, entry := range entries {
+		row := models.AllergenClass{Class: entry.Class, GenericName: entry.GenericName}
+		if seen[row] {
+			continue
+		}
+		seen[row] = true
+		rows = append(rows, row)
+	}
+
+	if len(rows) > 0 {
+		if err := config.DB.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&rows, 1000).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import allergen classes"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Allergen classes imported successfully", "count": len(rows)})
+}
+
+func GetDrugInteractions(c *gin.Context) {
+	var rows []models.DrugInteraction
+	query := config.DB.Order("drug_a ASC, drug_b ASC")
+
+	if drug := interactions.NormalizeName(c.Query("drug")); drug != "" {
+		query = query.Where("drug_a = ? OR drug_b = ?", drug, drug)
+	}
+
+	if severity := c.Query("severity"); severity != "" {
+		query = query.Where("severity = ?", severity)
+	}
+
+	if err := query.Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch drug interactions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rows)
+}
+
+// CheckPrescription runs the prescribing checks for draft items without
+// saving anything, so alerts can be shown before the doctor submits.
+func CheckPrescription(c *gin.Context) {
+	var input struct {
+		PatientID      uint                      `json:"patientId" binding:"required"`
+		PrescriptionID uint                      `json:"prescriptionId"` // when revising an existing prescription
+		Items          []models.PrescriptionItem `json:"items" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var patient models.Patient
+	if err := config.DB.First(&patient, input.PatientID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Patient not found"})
+		return
+	}
+
+	if err := preparePrescriptionItems(input.Items); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	alerts, err := checkPrescriptionItems(patient.ID, input.Items, input.PrescriptionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check prescription"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"alerts": alerts, "requiresOverride": hasSevereAlert(alerts)})
+}
+
+// checkPrescriptionItems compares the items, whose drugs must be loaded,
+// against the patient's allergies, each other and the patient's current
+// medications. Alerts come back most severe first.
+func checkPrescriptionItems(patientID uint, items []models.PrescriptionItem, excludePrescriptionID uint) ([]models.PrescriptionAlert, error) {
+	var allergies []models.Allergy
+	if err := config.DB.Where("patient_id = ? AND verification_status <> ?", patientID, "Refuted").Find(&allergies).Error; err != nil {
+		return nil, err
+	}
+
+	current, err := currentMedications(patientID, excludePrescriptionID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Allergen classes of every drug and allergy substance involved
+	var names []string
+	for _, item := range items {
+		names = append(names, interactions.NormalizeName(item.Drug.GenericName))
+	}
+	for _, allergy := range allergies {
+		names = append(names, interactions.NormalizeName(allergy.Substance))
+	}
+	var memberships []models.AllergenClass
+	if err := config.DB.Where("generic_name IN ?", names).Find(&memberships).Error; err != nil {
+		return nil, err
+	}
+	classes := map[string]map[string]bool{}
+	for _, m := range memberships {
+		if classes[m.GenericName] == nil {
+			classes[m.GenericName] = map[string]bool{}
+		}
+		classes[m.GenericName][m.Class] = true
+	}
+
+	var alerts []models.PrescriptionAlert
+	alert := func(kind, severity string, item models.PrescriptionItem, interacting, message string) {
+		alerts = append(alerts, models.PrescriptionAlert{
+			Type:        kind,
+			Severity:    severity,
+			DrugID:      item.DrugID,
+			Drug:        item.Drug.GenericName,
+			Interacting: interacting,
+			Message:     message,
+		})
+	}
+
+	// Drug-allergy
+	for _, item := range items {
+		name := interactions.NormalizeName(item.Drug.GenericName)
+		brand := interactions.NormalizeName(item.Drug.BrandName)
+		for _, allergy := range allergies {
+			substance := interactions.NormalizeName(allergy.Substance)
+			switch {
+			case substance == name || (brand != "" && substance == brand):
+				severity := "Severe"
+				if allergy.Severity == "Mild" {
+					severity = "Moderate"
+				}
+				alert("DrugAllergy", severity, item, allergy.Substance,
+					fmt.Sprintf("Patient is allergic to %s (%s reaction: %s)", allergy.Substance, allergy.Severity, allergy.Reaction))
+			case classes[name][substance] || sharesClass(classes[name], classes[substance]):
+				severity := "Moderate"
+				if allergy.Severity == "Severe" {
+					severity = "Severe"
+				}
+				alert("DrugAllergy", severity, item, allergy.Substance,
+					fmt.Sprintf("%s is in the same allergen class as %s, which the patient is allergic to", item.Drug.GenericName, allergy.Substance))
+			}
+		}
+	}
+
+	// Duplicates within the order and against current medications
+	seen := map[string]bool{}
+	for _, item := range items {
+		name := interactions.NormalizeName(item.Drug.GenericName)
+		if seen[name] {
+			alert("Duplicate", "Moderate", item, item.Drug.GenericName, item.Drug.GenericName+" is ordered more than once")
+		}
+		seen[name] = true
+	}
+	for _, item := range items {
+		for _, med := range current {
+			if interactions.NormalizeName(med.Drug.GenericName) == interactions.NormalizeName(item.Drug.GenericName) {
+				alert("Duplicate", "Moderate", item, med.Drug.GenericName, item.Drug.GenericName+" is already an active medication")
+				break
+			}
+		}
+	}
+
+	// Drug-drug, between new items and against current medications
+	type candidate struct {
+		item models.PrescriptionItem
+		name string
+		new  bool
+	}
+	var candidates []candidate
+	var candidateNames []string
+	for _, item := range items {
+		name := interactions.NormalizeName(item.Drug.GenericName)
+		candidates = append(candidates, candidate{item, name, true})
+		candidateNames = append(candidateNames, name)
+	}
+	for _, med := range current {
+		name := interactions.NormalizeName(med.Drug.GenericName)
+		candidates = append(candidates, candidate{med, name, false})
+		candidateNames = append(candidateNames, name)
+	}
+
+	var pairs []models.DrugInteraction
+	if err := config.DB.Where("drug_a IN ? AND drug_b IN ?", candidateNames, candidateNames).Find(&pairs).Error; err != nil {
+		return nil, err
+	}
+	known := map[[2]string]models.DrugInteraction{}
+	for _, pair := range pairs {
+		known[[2]string{pair.DrugA, pair.DrugB}] = pair
+	}
+
+	for i, a := range candidates {
+		if !a.new {
+			continue
+		}
+		for j, b := range candidates {
+			if j == i || (b.new && j < i) || a.name == b.name {
+				continue
+			}
+			key := [2]string{a.name, b.name}
+			if b.name < a.name {
+				key = [2]string{b.name, a.name}
+			}
+			pair, ok := known[key]
+			if !ok {
+				continue
+			}
+			message := fmt.Sprintf("%s interacts with %s", a.item.Drug.GenericName, b.item.Drug.GenericName)
+			if !b.new {
+				message += " (current medication)"
+			}
+			if pair.Description != "" {
+				message += ": " + pair.Description
+			}
+			alert("DrugDrug", pair.Severity, a.item, b.item.Drug.GenericName, message)
+		}
+	}
+
+	sort.SliceStable(alerts, func(i, j int) bool {
+		return alertSeverityRank[alerts[i].Severity] < alertSeverityRank[alerts[j].Severity]
+	})
+	return alerts, nil
+}
+
+// currentMedications returns the items of the patient's prescriptions whose
+// course has not ended yet.
+func currentMedications(patientID, excludePrescriptionID uint) ([]models.PrescriptionItem, error) {
+	var items []models.PrescriptionItem
+	err := config.DB.
+		Joins("JOIN prescriptions ON prescriptions.id = prescription_items.prescription_id").
+		Where("prescriptions.patient_id = ? AND prescriptions.id <> ?", patientID, excludePrescriptionID).
+		Where("prescriptions.date + GREATEST(prescription_items.duration_days, 1) * INTERVAL '1 day' > ?", time.Now()).
+		Preload("Drug").
+		Find(&items).Error
+	return items, err
+}
+
+func sharesClass(a, b map[string]bool) bool {
+	for class := range a {
+		if b[class] {
+			return true
+		}
+	}
+	return false
+}
+
+func hasSevereAlert(alerts []models.PrescriptionAlert) bool {
+	for _, alert := range alerts {
+		if alert.Severity == "Severe" {
+			return true
+		}
+	}
+	return false
+}
+
+// severeAlertsCovered reports whether every severe alert was already raised,
+// and so overridden, among the previous alerts.
+func severeAlertsCovered(alerts, previous []models.PrescriptionAlert) bool {
+	raised := map[string]bool{}
+	for _, alert := range previous {
+		if alert.Severity == "Severe" {
+			raised[fmt.Sprintf("%s|%d|%s", alert.Type, alert.DrugID, alert.Interacting)] = true
+		}
+	}
+	for _, alert := range alerts {
+		if alert.Severity == "Severe" && !raised[fmt.Sprintf("%s|%d|%s", alert.Type, alert.DrugID, alert.Interacting)] {
+			return false
+		}
+	}
+	return true
+}
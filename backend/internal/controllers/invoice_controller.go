@@ -0,0 +1,263 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"clinic-backend/internal/config"
+	"clinic-backend/internal/documents"
+	"clinic-backend/internal/models"
+	"clinic-backend/internal/money"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SeedInvoiceNumbers numbers bills from before invoice numbering in the
+// order they were created.
+func SeedInvoiceNumbers() {
+	var bills []models.Bill
+	config.DB.Where("invoice_number = '' OR invoice_number IS NULL").Order("created_at ASC, id ASC").Find(&bills)
+
+	for _, bill := range bills {
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			if err := assignInvoiceNumber(tx, &bill, bill.CreatedAt); err != nil {
+				return err
+			}
+			return tx.Model(&bill).Updates(map[string]interface{}{
+				"invoice_number": bill.InvoiceNumber,
+				"fiscal_year":    bill.FiscalYear,
+			}).Error
+		})
+		if err != nil {
+			log.Println("Failed to seed invoice numbers:", err)
+			return
+		}
+	}
+}
+
+// assignInvoiceNumber gives the bill the next invoice number of the fiscal
+// year it is issued in. The sequence row stays locked until the transaction
+// ends, so a bill that fails to save gives its number back.
+func assignInvoiceNumber(tx *gorm.DB, bill *models.Bill, issuedAt time.Time) error {
+	year := fiscalYear(issuedAt)
+
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.InvoiceSequence{FiscalYear: year}).Error; err != nil {
+		return err
+	}
+	var sequence models.InvoiceSequence
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("fiscal_year = ?", year).
+		First(&sequence).Error; err != nil {
+		return err
+	}
+
+	sequence.LastNumber++
+	if err := tx.Model(&sequence).Update("last_number", sequence.LastNumber).Error; err != nil {
+		return err
+	}
+
+	bill.FiscalYear = year
+	bill.InvoiceNumber = fmt.Sprintf("%s-%d-%06d", invoicePrefix(), year, sequence.LastNumber)
+	return nil
+}
+
+// fiscalYear returns the year the fiscal year containing t starts in. Fiscal
+// years start on the first of FISCAL_YEAR_START_MONTH (1-12, January by
+// default).
+func fiscalYear(t time.Time) int {
+	month, err := strconv.Atoi(os.Getenv("FISCAL_YEAR_START_MONTH"))
+	if err != nil || month < 1 || month > 12 {
+		month = 1
+	}
+	if int(t.Month()) < month {
+		return t.Year() - 1
+	}
+	return t.Year()
+}
+
+// invoicePrefix is INVOICE_PREFIX, "INV" by default.
+func invoicePrefix() string {
+	if prefix := os.Getenv("INVOICE_PREFIX"); prefix != "" {
+		return prefix
+	}
+	return "INV"
+}
+
+func renderInvoicePDF(c *gin.Context, bill models.Bill) {
+	rule := documentRule(bill.Currency)
+
+	fields := []documents.Field{
+		{Label: "Patient", Value: bill.Patient.Name},
+		{Label: "Patient ID", Value: fmt.Sprintf("P-%06d", bill.Patient.ID)},
+	}
+	if bill.Patient.Phone != "" {
+		fields = append(fields, documents.Field{Label: "Phone", Value: bill.Patient.Phone})
+	}
+	if bill.Patient.Address != "" {
+		fields = append(fields, documents.Field{Label: "Address", Value: bill.Patient.Address})
+	}
+	fields = append(fields, documents.Field{Label: "Currency", Value: bill.Currency})
+	if bill.DueDate != nil {
+		fields = append(fields, documents.Field{Label: "Due date", Value: bill.DueDate.Format("02 Jan 2006")})
+	}
+	if bill.PolicyID != nil {
+		var policy models.PatientPolicy
+		if config.DB.Preload("Plan.Insurer").First(&policy, *bill.PolicyID).Error == nil && policy.Plan != nil && policy.Plan.Insurer != nil {
+			fields = append(fields,
+				documents.Field{Label: "Insurer", Value: policy.Plan.Insurer.Name + ", " + policy.Plan.Name},
+				documents.Field{Label: "Policy no.", Value: policy.PolicyNumber},
+			)
+		}
+	}
+
+	items := &documents.Table{
+		Columns: []string{"Description", "Qty", "Unit price", "Discount", "Tax %", "Tax", "Total"},
+		Widths:  []float64{3.6, 0.8, 1.3, 1.2, 0.9, 1.1, 1.4},
+	}
+	taxes := map[string][2]decimal.Decimal{}
+	for _, item := range bill.Items {
+		items.Rows = append(items.Rows, []string{
+			item.Service,
+			item.Quantity.String(),
+			rule.Format(item.UnitPrice),
+			rule.Format(item.Discount),
+			item.TaxRate.String(),
+			rule.Format(item.TaxAmount),
+			rule.Format(item.Total),
+		})
+
+		rate := item.TaxRate.String()
+		sums := taxes[rate]
+		taxes[rate] = [2]decimal.Decimal{sums[0].Add(item.Subtotal.Sub(item.Discount)), sums[1].Add(item.TaxAmount)}
+	}
+
+	rates := make([]string, 0, len(taxes))
+	for rate := range taxes {
+		rates = append(rates, rate)
+	}
+	sort.Slice(rates, func(i, j int) bool {
+		return decimal.RequireFromString(rates[i]).LessThan(decimal.RequireFromString(rates[j]))
+	})
+	breakdown := &documents.Table{Columns: []string{"Tax rate", "Taxable amount", "Tax"}, Widths: []float64{1, 2, 2}}
+	for _, rate := range rates {
+		breakdown.Rows = append(breakdown.Rows, []string{rate + "%", rule.Format(taxes[rate][0]), rule.Format(taxes[rate][1])})
+	}
+
+	totals := &documents.Table{Columns: []string{"", bill.Currency}, Widths: []float64{4, 1.5}}
+	totals.Rows = [][]string{
+		{"Subtotal", rule.Format(bill.Subtotal)},
+		{"Discounts", rule.Format(bill.DiscountTotal.Neg())},
+		{"Tax", rule.Format(bill.TaxTotal)},
+		{"Total", rule.Format(bill.Amount)},
+	}
+	if bill.PolicyID != nil {
+		totals.Rows = append(totals.Rows,
+			[]string{"Insurer portion", rule.Format(bill.PayerAmount)},
+			[]string{"Patient portion", rule.Format(bill.PatientAmount)},
+		)
+	}
+	totals.Rows = append(totals.Rows,
+		[]string{"Paid", rule.Format(bill.AmountPaid)},
+		[]string{"Balance due", rule.Format(bill.Balance)},
+	)
+
+	sections := []documents.Section{
+		{Heading: "Items", Body: bill.Description, Table: items},
+		{Heading: "Tax breakdown", Table: breakdown},
+		{Heading: "Totals", Table: totals},
+	}
+	if bill.Status == "WrittenOff" && bill.WriteOffReason != "" {
+		sections = append(sections, documents.Section{Heading: "Written off", Body: bill.WriteOffReason})
+	}
+	if bill.Status == "Void" && bill.VoidReason != "" {
+		sections = append(sections, documents.Section{Heading: "Voided", Body: bill.VoidReason})
+	}
+
+	doc := documents.Document{
+		Title:     "Tax Invoice",
+		Reference: bill.InvoiceNumber,
+		Date:      bill.CreatedAt,
+		Fields:    fields,
+		Sections:  sections,
+		VerifyURL: verifyURL("invoice", bill.ID, 0),
+	}
+	switch bill.Status {
+	case "Paid":
+		doc.Watermark = "PAID"
+	case "Void":
+		doc.Watermark = "VOID"
+	}
+	sendPDF(c, "invoice-"+bill.InvoiceNumber+".pdf", doc)
+}
+
+func renderReceiptPDF(c *gin.Context, payment models.Payment, bill models.Bill, cashier models.User) {
+	rule := documentRule(payment.Currency)
+
+	title := "Payment Receipt"
+	switch payment.Kind {
+	case "Refund":
+		title = "Refund Receipt"
+	case "Reversal":
+		title = "Payment Reversal"
+	case "Adjustment":
+		title = "Insurance Adjustment"
+	}
+
+	fields := []documents.Field{
+		{Label: "Patient", Value: bill.Patient.Name},
+		{Label: "Patient ID", Value: fmt.Sprintf("P-%06d", bill.Patient.ID)},
+		{Label: "Invoice", Value: bill.InvoiceNumber},
+		{Label: "Invoice date", Value: bill.CreatedAt.Format("02 Jan 2006")},
+		{Label: "Method", Value: payment.Method},
+	}
+	if payment.Reference != "" {
+		fields = append(fields, documents.Field{Label: "Reference", Value: payment.Reference})
+	}
+	if cashier.Name != "" {
+		fields = append(fields, documents.Field{Label: "Received by", Value: cashier.Name})
+	}
+
+	label := "Amount received"
+	if payment.Amount.IsNegative() {
+		label = "Amount returned"
+	}
+	amounts := &documents.Table{Columns: []string{"", payment.Currency}, Widths: []float64{4, 1.5}}
+	amounts.Rows = [][]string{
+		{"Invoice total", rule.Format(bill.Amount)},
+		{label, rule.Format(payment.Amount.Abs())},
+		{"Balance after this " + strings.ToLower(payment.Kind), rule.Format(payment.BalanceAfter)},
+	}
+	sections := []documents.Section{{Heading: "Amount", Table: amounts}}
+	if payment.Reason != "" {
+		sections = append(sections, documents.Section{Heading: "Reason", Body: payment.Reason})
+	}
+
+	doc := documents.Document{
+		Title:     title,
+		Reference: payment.ReceiptNumber,
+		Date:      payment.ReceivedAt,
+		Fields:    fields,
+		Sections:  sections,
+		VerifyURL: verifyURL("receipt", payment.ID, 0),
+	}
+	sendPDF(c, "receipt-"+payment.ReceiptNumber+".pdf", doc)
+}
+
+// documentRule is the rounding rule amounts in the currency are printed
+// with, two decimals when the currency is no longer configured.
+func documentRule(code string) money.Rule {
+	currency, err := billCurrency(code)
+	if err != nil {
+		return money.Rule{Decimals: 2, Mode: money.HalfUp}
+	}
+	return currency.Rule()
+}
@@ -1,15 +1,35 @@
 package controllers
 
 import (
+	"errors"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"clinic-backend/internal/config"
 	"clinic-backend/internal/models"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// errRecordSigned aborts a draft edit of a record signed in the meantime.
+var errRecordSigned = errors.New("Medical record has been signed")
+
+// medicalRecordContent holds the clinical fields of a record that can be
+// edited while it is a draft or corrected after signing.
+type medicalRecordContent struct {
+	Diagnosis    *string            `json:"diagnosis"`
+	Prescription *string            `json:"prescription"`
+	Notes        *string            `json:"notes"`
+	Content      models.NoteContent `json:"content"` // structured note, replaces notes
+	Date         *time.Time         `json:"date"`
+	Reason       string             `json:"reason"`
+}
+
 func CreateMedicalRecord(c *gin.Context) {
 	var record models.MedicalRecord
 	if err := c.ShouldBindJSON(&record); err != nil {
@@ -31,20 +51,67 @@ func CreateMedicalRecord(c *gin.Context) {
 		return
 	}
 
-	if err := config.DB.Create(&record).Error; err != nil {
+	// Verify encounter belongs to the patient if provided
+	if record.EncounterID != nil {
+		var encounter models.Encounter
+		if err := config.DB.First(&encounter, *record.EncounterID).Error; err != nil || encounter.PatientID != record.PatientID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Encounter not found for patient"})
+			return
+		}
+	}
+
+	// Structured notes are validated against their template and rendered to text
+	if record.Content != nil {
+		if err := applyNoteContent(&record, record.Content); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else {
+		record.TemplateID = nil
+	}
+
+	if err := prepareDiagnoses(record.Diagnoses); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Keep the free-text diagnosis filled for existing consumers
+	if record.Diagnosis == "" {
+		for _, diagnosis := range record.Diagnoses {
+			if diagnosis.Primary {
+				record.Diagnosis = diagnosis.Description
+			}
+		}
+	}
+
+	// Records start as drafts; signing is a separate step
+	record.ID = 0
+	record.Status = "Draft"
+	record.Version = 1
+	record.SignedBy = nil
+	record.SignedAt = nil
+	record.Addenda = nil
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&record).Error; err != nil {
+			return err
+		}
+		return tx.Create(recordVersion(record, currentUserID(c), "Created")).Error
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create medical record"})
 		return
 	}
 
 	// Load relations
-	config.DB.Preload("Patient").Preload("Doctor").First(&record, record.ID)
+	config.DB.Preload("Patient").Preload("Doctor").Preload("Diagnoses").First(&record, record.ID)
 
 	c.JSON(http.StatusCreated, record)
 }
 
 func GetMedicalRecords(c *gin.Context) {
 	var records []models.MedicalRecord
-	query := config.DB.Preload("Patient").Preload("Doctor").Order("date DESC")
+	query := config.DB.Preload("Patient").Preload("Doctor").Preload("Diagnoses").Order("date DESC")
 
 	// Filter by patient if provided
 	if patientID := c.Query("patientId"); patientID != "" {
@@ -56,6 +123,11 @@ func GetMedicalRecords(c *gin.Context) {
 		query = query.Where("doctor_id = ?", doctorID)
 	}
 
+	// Filter by encounter if provided
+	if encounterID := c.Query("encounterId"); encounterID != "" {
+		query = query.Where("encounter_id = ?", encounterID)
+	}
+
 	if err := query.Find(&records).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch medical records"})
 		return
@@ -72,14 +144,21 @@ func GetMedicalRecordByID(c *gin.Context) {
 	}
 
 	var record models.MedicalRecord
-	if err := config.DB.Preload("Patient").Preload("Doctor").First(&record, id).Error; err != nil {
+	if err := config.DB.Preload("Patient").Preload("Doctor").Preload("Diagnoses").Preload("Addenda").First(&record, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Medical record not found"})
 		return
 	}
 
+	if wantsPDF(c) {
+		renderMedicalRecordPDF(c, record)
+		return
+	}
+
 	c.JSON(http.StatusOK, record)
 }
 
+// UpdateMedicalRecord edits a draft record. Every edit is kept as a new
+// version; signed records can only be amended through addenda or corrections.
 func UpdateMedicalRecord(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
@@ -93,20 +172,217 @@ func UpdateMedicalRecord(c *gin.Context) {
 		return
 	}
 
-	if err := c.ShouldBindJSON(&record); err != nil {
+	if record.Status != "Draft" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Signed medical records cannot be edited; add an addendum or a correction instead"})
+		return
+	}
+
+	var input medicalRecordContent
+	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := config.DB.Save(&record).Error; err != nil {
+	if !applyRecordContent(c, &record, &input) {
+		return
+	}
+
+	reason := input.Reason
+	if reason == "" {
+		reason = "Draft edit"
+	}
+	err = reviseMedicalRecord(&record, input, currentUserID(c), reason)
+	if errors.Is(err, errRecordSigned) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Signed medical records cannot be edited; add an addendum or a correction instead"})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update medical record"})
 		return
 	}
 
-	config.DB.Preload("Patient").Preload("Doctor").First(&record, record.ID)
+	config.DB.Preload("Patient").Preload("Doctor").Preload("Diagnoses").First(&record, record.ID)
+	c.JSON(http.StatusOK, record)
+}
+
+// SignMedicalRecord locks a draft record. Doctors may only sign their own records.
+func SignMedicalRecord(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid medical record ID"})
+		return
+	}
+
+	var record models.MedicalRecord
+	if err := config.DB.First(&record, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Medical record not found"})
+		return
+	}
+
+	userRole, _ := c.Get("userRole")
+	userID := currentUserID(c)
+	if userRole == "doctor" && userID != record.DoctorID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the record's doctor can sign it"})
+		return
+	}
+
+	if record.Status != "Draft" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Medical record is already signed"})
+		return
+	}
+
+	now := time.Now()
+	record.Status = "Signed"
+	record.SignedBy = &userID
+	record.SignedAt = &now
+
+	// Only a record still in draft is signed, so a record is signed once and
+	// an edit cannot land after it
+	result := config.DB.Model(&record).Where("status = ?", "Draft").
+		Select("status", "signed_by", "signed_at").Updates(&record)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign medical record"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Medical record is already signed"})
+		return
+	}
+
+	config.DB.Preload("Patient").Preload("Doctor").Preload("Diagnoses").First(&record, record.ID)
+	c.JSON(http.StatusOK, record)
+}
+
+// AddMedicalRecordAddendum appends a note to a signed record without
+// touching its original content.
+func AddMedicalRecordAddendum(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid medical record ID"})
+		return
+	}
+
+	var record models.MedicalRecord
+	if err := config.DB.First(&record, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Medical record not found"})
+		return
+	}
+
+	if record.Status == "Draft" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Draft records can be edited directly"})
+		return
+	}
+
+	var input struct {
+		Content string `json:"content" binding:"required"`
+		Reason  string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	addendum := models.MedicalRecordAddendum{
+		MedicalRecordID: record.ID,
+		AuthorID:        currentUserID(c),
+		Reason:          strings.TrimSpace(input.Reason),
+		Content:         input.Content,
+	}
+	if err := config.DB.Create(&addendum).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add addendum"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, addendum)
+}
+
+// CorrectMedicalRecord replaces the content of a signed record with a new
+// version. The previous versions stay available through the history. Doctors
+// may only correct their own records.
+func CorrectMedicalRecord(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid medical record ID"})
+		return
+	}
+
+	var record models.MedicalRecord
+	if err := config.DB.First(&record, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Medical record not found"})
+		return
+	}
+
+	userRole, _ := c.Get("userRole")
+	if userRole == "doctor" && currentUserID(c) != record.DoctorID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the record's doctor can correct it"})
+		return
+	}
+
+	if record.Status == "Draft" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Draft records can be edited directly"})
+		return
+	}
+
+	var input medicalRecordContent
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if strings.TrimSpace(input.Reason) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required for corrections"})
+		return
+	}
+
+	if !applyRecordContent(c, &record, &input) {
+		return
+	}
+
+	record.Status = "Amended"
+	if err := reviseMedicalRecord(&record, input, currentUserID(c), strings.TrimSpace(input.Reason)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to correct medical record"})
+		return
+	}
+
+	config.DB.Preload("Patient").Preload("Doctor").Preload("Diagnoses").Preload("Addenda").First(&record, record.ID)
 	c.JSON(http.StatusOK, record)
 }
 
+func GetMedicalRecordHistory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid medical record ID"})
+		return
+	}
+
+	var record models.MedicalRecord
+	if err := config.DB.First(&record, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Medical record not found"})
+		return
+	}
+
+	var versions []models.MedicalRecordVersion
+	if err := config.DB.Where("medical_record_id = ?", record.ID).Order("version ASC").Find(&versions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch medical record history"})
+		return
+	}
+
+	var addenda []models.MedicalRecordAddendum
+	if err := config.DB.Where("medical_record_id = ?", record.ID).Order("created_at ASC").Find(&addenda).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch medical record history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"recordId": record.ID,
+		"status":   record.Status,
+		"version":  record.Version,
+		"signedBy": record.SignedBy,
+		"signedAt": record.SignedAt,
+		"versions": versions,
+		"addenda":  addenda,
+	})
+}
+
 func DeleteMedicalRecord(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
@@ -114,10 +390,129 @@ func DeleteMedicalRecord(c *gin.Context) {
 		return
 	}
 
-	if err := config.DB.Delete(&models.MedicalRecord{}, id).Error; err != nil {
+	var record models.MedicalRecord
+	if err := config.DB.First(&record, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Medical record not found"})
+		return
+	}
+
+	if record.Status != "Draft" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Signed medical records cannot be deleted"})
+		return
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&record, record.ID).Error; err != nil {
+			return err
+		}
+		if record.Status != "Draft" {
+			return errRecordSigned
+		}
+		if err := tx.Where("medical_record_id = ?", record.ID).Delete(&models.RecordDiagnosis{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("medical_record_id = ?", record.ID).Delete(&models.MedicalRecordVersion{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&record).Error
+	})
+	if errors.Is(err, errRecordSigned) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Signed medical records cannot be deleted"})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete medical record"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Medical record deleted successfully"})
 }
+
+// SeedMedicalRecordVersions snapshots records from before versioning as
+// their first version, so a later correction does not lose the original.
+func SeedMedicalRecordVersions() {
+	if err := config.DB.Exec(`INSERT INTO medical_record_versions
+		(medical_record_id, version, diagnosis, prescription, notes, content, date, author_id, reason, created_at)
+		SELECT r.id, r.version, r.diagnosis, r.prescription, r.notes, r.content, r.date, 0, 'Created', r.created_at
+		FROM medical_records r
+		WHERE NOT EXISTS (SELECT 1 FROM medical_record_versions v WHERE v.medical_record_id = r.id)`).Error; err != nil {
+		log.Println("Failed to seed medical record versions:", err)
+	}
+}
+
+// reviseMedicalRecord applies the changed content to the record and stores
+// it as the next version. The record is locked while it is revised; a draft
+// edit fails with errRecordSigned once the record has been signed, while a
+// correction also stores the record's new status.
+func reviseMedicalRecord(record *models.MedicalRecord, input medicalRecordContent, authorID uint, reason string) error {
+	if input.Diagnosis != nil {
+		record.Diagnosis = *input.Diagnosis
+	}
+	if input.Prescription != nil {
+		record.Prescription = *input.Prescription
+	}
+	if input.Notes != nil {
+		record.Notes = *input.Notes
+	}
+	if input.Date != nil {
+		record.Date = *input.Date
+	}
+
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		var current models.MedicalRecord
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "status", "version").
+			First(&current, record.ID).Error; err != nil {
+			return err
+		}
+		columns := []string{"diagnosis", "prescription", "notes", "template_id", "content", "date", "version"}
+		if record.Status == "Draft" {
+			if current.Status != "Draft" {
+				return errRecordSigned
+			}
+		} else {
+			columns = append(columns, "status")
+		}
+		record.Version = current.Version + 1
+
+		if err := tx.Model(record).Select(columns).Updates(record).Error; err != nil {
+			return err
+		}
+		return tx.Create(recordVersion(*record, authorID, reason)).Error
+	})
+}
+
+// applyRecordContent renders new structured content into the record's notes,
+// writing the error response when the content does not fit its template.
+func applyRecordContent(c *gin.Context, record *models.MedicalRecord, input *medicalRecordContent) bool {
+	if input.Content == nil {
+		return true
+	}
+	if err := applyNoteContent(record, input.Content); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	input.Notes = nil
+	return true
+}
+
+func recordVersion(record models.MedicalRecord, authorID uint, reason string) *models.MedicalRecordVersion {
+	return &models.MedicalRecordVersion{
+		MedicalRecordID: record.ID,
+		Version:         record.Version,
+		Diagnosis:       record.Diagnosis,
+		Prescription:    record.Prescription,
+		Notes:           record.Notes,
+		Content:         record.Content,
+		Date:            record.Date,
+		AuthorID:        authorID,
+		Reason:          reason,
+	}
+}
+
+// currentUserID returns the authenticated user's ID, or 0 when unknown.
+func currentUserID(c *gin.Context) uint {
+	userID, _ := c.Get("userID")
+	id, _ := userID.(uint)
+	return id
+}
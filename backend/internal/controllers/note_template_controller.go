@@ -0,0 +1,419 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"clinic-backend/internal/config"
+	"clinic-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var validNoteKinds = map[string]bool{
+	"SOAP":             true,
+	"DischargeSummary": true,
+	"OperativeNote":    true,
+	"ReferralLetter":   true,
+}
+
+var validSectionTypes = map[string]bool{
+	"text":   true,
+	"list":   true,
+	"number": true,
+	"date":   true,
+}
+
+func GetNoteTemplates(c *gin.Context) {
+	var templates []models.NoteTemplate
+	query := config.DB.Order("kind ASC, specialization ASC, name ASC")
+
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+
+	// Templates for the specialization plus the generic ones
+	if specialization := c.Query("specialization"); specialization != "" {
+		query = query.Where("specialization = ? OR specialization = ''", specialization)
+	}
+
+	if err := query.Find(&templates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch note templates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, templates)
+}
+
+func GetNoteTemplateByID(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid note template ID"})
+		return
+	}
+
+	var template models.NoteTemplate
+	if err := config.DB.First(&template, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note template not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// GetDefaultNoteTemplate resolves the template a doctor gets for a note kind:
+// the default for their specialization, else the generic default.
+func GetDefaultNoteTemplate(c *gin.Context) {
+	kind := c.DefaultQuery("kind", "SOAP")
+	specialization := c.Query("specialization")
+
+	if doctorID := c.Query("doctorId"); doctorID != "" {
+		var doctor models.Doctor
+		if err := config.DB.First(&doctor, doctorID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Doctor not found"})
+			return
+		}
+		specialization = doctor.Specialization
+	}
+
+	template, ok := defaultNoteTemplate(kind, specialization)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No default template for " + kind})
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+func CreateNoteTemplate(c *gin.Context) {
+	var template models.NoteTemplate
+	if err := c.ShouldBindJSON(&template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	template.ID = 0
+
+	if err := validateNoteTemplate(&template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := saveNoteTemplate(&template); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create note template"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, template)
+}
+
+func UpdateNoteTemplate(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid note template ID"})
+		return
+	}
+
+	var template models.NoteTemplate
+	if err := config.DB.First(&template, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note template not found"})
+		return
+	}
+
+	sections := append(models.NoteSections(nil), template.Sections...)
+	if err := c.ShouldBindJSON(&template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	template.ID = uint(id)
+
+	if err := validateNoteTemplate(&template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Records written with the template are validated against its sections
+	// on correction, so those stay fixed once the template is in use.
+	if !reflect.DeepEqual(sections, template.Sections) {
+		var used int64
+		config.DB.Model(&models.MedicalRecord{}).Where("template_id = ?", id).Count(&used)
+		if used > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Sections of a note template used by medical records cannot be changed. Create a new template instead"})
+			return
+		}
+	}
+
+	if err := saveNoteTemplate(&template); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update note template"})
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+func DeleteNoteTemplate(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid note template ID"})
+		return
+	}
+
+	// Notes written with the template keep their rendered text, but the
+	// template must stay to validate future corrections.
+	var used int64
+	config.DB.Model(&models.MedicalRecord{}).Where("template_id = ?", id).Count(&used)
+	if used > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Note template is used by medical records"})
+		return
+	}
+
+	if err := config.DB.Delete(&models.NoteTemplate{}, id).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete note template"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Note template deleted successfully"})
+}
+
+// SeedNoteTemplates creates the generic default templates on an empty table.
+func SeedNoteTemplates() {
+	var count int64
+	config.DB.Model(&models.NoteTemplate{}).Count(&count)
+	if count > 0 {
+		return
+	}
+
+	s := func(key, label, typ string, required bool) models.NoteSection {
+		return models.NoteSection{Key: key, Label: label, Type: typ, Required: required}
+	}
+
+	defaults := []models.NoteTemplate{
+		{Name: "SOAP note", Kind: "SOAP", IsDefault: true, Sections: models.NoteSections{
+			s("subjective", "Subjective", "text", true),
+			s("objective", "Objective", "text", false),
+			s("assessment", "Assessment", "text", true),
+			s("plan", "Plan", "text", true),
+		}},
+		{Name: "Discharge summary", Kind: "DischargeSummary", IsDefault: true, Sections: models.NoteSections{
+			s("admission_date", "Admission date", "date", true),
+			s("discharge_date", "Discharge date", "date", true),
+			s("admitting_diagnosis", "Admitting diagnosis", "text", true),
+			s("hospital_course", "Hospital course", "text", true),
+			s("procedures", "Procedures", "list", false),
+			s("discharge_medications", "Discharge medications", "list", false),
+			s("condition_at_discharge", "Condition at discharge", "text", false),
+			s("follow_up", "Follow-up", "text", false),
+		}},
+		{Name: "Operative note", Kind: "OperativeNote", IsDefault: true, Sections: models.NoteSections{
+			s("preoperative_diagnosis", "Preoperative diagnosis", "text", true),
+			s("postoperative_diagnosis", "Postoperative diagnosis", "text", false),
+			s("procedure", "Procedure", "text", true),
+			s("assistants", "Assistants", "list", false),
+			s("anesthesia", "Anesthesia", "text", false),
+			s("findings", "Findings", "text", true),
+			s("estimated_blood_loss_ml", "Estimated blood loss (ml)", "number", false),
+			s("specimens", "Specimens", "list", false),
+			s("complications", "Complications", "text", false),
+		}},
+		{Name: "Referral letter", Kind: "ReferralLetter", IsDefault: true, Sections: models.NoteSections{
+			s("referred_to", "Referred to", "text", true),
+			s("reason", "Reason for referral", "text", true),
+			s("history", "Relevant history", "text", false),
+			s("current_medications", "Current medications", "list", false),
+			s("investigations", "Investigations", "list", false),
+			s("urgency", "Urgency", "text", false),
+		}},
+	}
+
+	if err := config.DB.Create(&defaults).Error; err != nil {
+		log.Println("Failed to seed note templates:", err)
+	}
+}
+
+func validateNoteTemplate(template *models.NoteTemplate) error {
+	template.Name = strings.TrimSpace(template.Name)
+	if template.Name == "" {
+		return errors.New("Template name is required")
+	}
+	if !validNoteKinds[template.Kind] {
+		return errors.New("Invalid note kind " + template.Kind)
+	}
+	if len(template.Sections) == 0 {
+		return errors.New("Template needs at least one section")
+	}
+
+	seen := map[string]bool{}
+	for i := range template.Sections {
+		section := &template.Sections[i]
+		section.Key = strings.TrimSpace(section.Key)
+		if section.Key == "" {
+			return errors.New("Section key is required")
+		}
+		if seen[section.Key] {
+			return errors.New("Duplicate section " + section.Key)
+		}
+		seen[section.Key] = true
+		if section.Type == "" {
+			section.Type = "text"
+		}
+		if !validSectionTypes[section.Type] {
+			return errors.New("Invalid section type " + section.Type)
+		}
+		if section.Label == "" {
+			section.Label = section.Key
+		}
+	}
+	return nil
+}
+
+// saveNoteTemplate stores the template, making it the only default for its
+// kind and specialization when flagged as default.
+func saveNoteTemplate(template *models.NoteTemplate) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(template).Error; err != nil {
+			return err
+		}
+		if !template.IsDefault {
+			return nil
+		}
+		return tx.Model(&models.NoteTemplate{}).
+			Where("kind = ? AND specialization = ? AND id <> ?", template.Kind, template.Specialization, template.ID).
+			Update("is_default", false).Error
+	})
+}
+
+func defaultNoteTemplate(kind, specialization string) (models.NoteTemplate, bool) {
+	var template models.NoteTemplate
+	if specialization != "" {
+		if err := config.DB.Where("kind = ? AND specialization = ? AND is_default = ?", kind, specialization, true).
+			First(&template).Error; err == nil {
+			return template, true
+		}
+	}
+	if err := config.DB.Where("kind = ? AND specialization = '' AND is_default = ?", kind, true).
+		First(&template).Error; err != nil {
+		return template, false
+	}
+	return template, true
+}
+
+// applyNoteContent validates structured content against the record's
+// template, picking the doctor's default SOAP template when none is set, and
+// renders it into the plain text notes.
+func applyNoteContent(record *models.MedicalRecord, content models.NoteContent) error {
+	var template models.NoteTemplate
+	if record.TemplateID != nil {
+		if err := config.DB.First(&template, *record.TemplateID).Error; err != nil {
+			return errors.New("Note template not found")
+		}
+	} else {
+		var doctor models.Doctor
+		config.DB.First(&doctor, record.DoctorID)
+		var ok bool
+		if template, ok = defaultNoteTemplate("SOAP", doctor.Specialization); !ok {
+			return errors.New("No default note template")
+		}
+		record.TemplateID = &template.ID
+	}
+
+	if err := validateNoteContent(template, content); err != nil {
+		return err
+	}
+
+	record.Content = content
+	record.Notes = renderNote(template, content)
+	return nil
+}
+
+func validateNoteContent(template models.NoteTemplate, content models.NoteContent) error {
+	sections := map[string]models.NoteSection{}
+	for _, section := range template.Sections {
+		sections[section.Key] = section
+	}
+	for key := range content {
+		if _, ok := sections[key]; !ok {
+			return fmt.Errorf("Unknown section %s for template %s", key, template.Name)
+		}
+	}
+
+	for _, section := range template.Sections {
+		value, present := content[section.Key]
+		if !present || value == nil {
+			if section.Required {
+				return fmt.Errorf("%s is required", section.Label)
+			}
+			continue
+		}
+
+		switch section.Type {
+		case "text":
+			text, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("%s must be text", section.Label)
+			}
+			if section.Required && strings.TrimSpace(text) == "" {
+				return fmt.Errorf("%s is required", section.Label)
+			}
+		case "list":
+			items, ok := value.([]interface{})
+			if !ok {
+				return fmt.Errorf("%s must be a list", section.Label)
+			}
+			for _, item := range items {
+				if _, ok := item.(string); !ok {
+					return fmt.Errorf("%s must be a list of text", section.Label)
+				}
+			}
+			if section.Required && len(items) == 0 {
+				return fmt.Errorf("%s is required", section.Label)
+			}
+		case "number":
+			if _, ok := value.(float64); !ok {
+				return fmt.Errorf("%s must be a number", section.Label)
+			}
+		case "date":
+			text, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("%s must be a date (YYYY-MM-DD)", section.Label)
+			}
+			if _, err := time.Parse("2006-01-02", text); err != nil {
+				return fmt.Errorf("%s must be a date (YYYY-MM-DD)", section.Label)
+			}
+		}
+	}
+	return nil
+}
+
+// renderNote turns structured content into plain text, one labelled
+// paragraph per filled section in template order.
+func renderNote(template models.NoteTemplate, content models.NoteContent) string {
+	var parts []string
+	for _, section := range template.Sections {
+		var body string
+		switch value := content[section.Key].(type) {
+		case string:
+			body = strings.TrimSpace(value)
+		case float64:
+			body = strconv.FormatFloat(value, 'f', -1, 64)
+		case []interface{}:
+			var lines []string
+			for _, item := range value {
+				if text, ok := item.(string); ok && strings.TrimSpace(text) != "" {
+					lines = append(lines, "- "+strings.TrimSpace(text))
+				}
+			}
+			body = strings.Join(lines, "\n")
+		}
+		if body == "" {
+			continue
+		}
+		parts = append(parts, section.Label+":\n"+body)
+	}
+	return strings.Join(parts, "\n\n")
+}
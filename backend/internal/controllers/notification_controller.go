@@ -0,0 +1,404 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"clinic-backend/internal/config"
+	"clinic-backend/internal/models"
+	"clinic-backend/internal/notify"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxNotificationAttempts is how often delivery is tried before giving up
+	maxNotificationAttempts = 5
+	// notificationBatchSize limits how many deliveries one dispatch run handles
+	notificationBatchSize = 100
+)
+
+var notificationChannels []notify.Channel
+
+// SetNotificationChannels configures the channels every notification is sent through.
+func SetNotificationChannels(channels []notify.Channel) {
+	notificationChannels = channels
+}
+
+func GetAppointmentNotifications(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid appointment ID"})
+		return
+	}
+
+	var notifications []models.Notification
+	if err := config.DB.Where("appointment_id = ?", id).Order("created_at DESC").Find(&notifications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, notifications)
+}
+
+func RetryNotification(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification ID"})
+		return
+	}
+
+	var notification models.Notification
+	if err := config.DB.First(&notification, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+
+	if notification.Status != "Failed" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only failed notifications can be retried"})
+		return
+	}
+
+	notification.Status = "Pending"
+	notification.Attempts = 0
+	notification.NextAttemptAt = time.Now()
+
+	if err := config.DB.Save(&notification).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retry notification"})
+		return
+	}
+
+	c.JSON(http.StatusOK, notification)
+}
+
+func GetNotificationPreferences(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid patient ID"})
+		return
+	}
+
+	var patient models.Patient
+	if err := config.DB.First(&patient, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Patient not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, notificationPreferences(patient.ID))
+}
+
+func UpdateNotificationPreferences(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid patient ID"})
+		return
+	}
+
+	var patient models.Patient
+	if err := config.DB.First(&patient, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Patient not found"})
+		return
+	}
+
+	preferences := notificationPreferences(patient.ID)
+	existingID := preferences.ID
+	if err := c.ShouldBindJSON(&preferences); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	preferences.ID = existingID
+	preferences.PatientID = patient.ID
+
+	if err := config.DB.Save(&preferences).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, preferences)
+}
+
+// QueueAppointmentReminders queues a reminder for each upcoming appointment
+// whose reminder time has come. When several reminder times have already
+// passed, as for late bookings, only the closest one is sent.
+func QueueAppointmentReminders() {
+	offsets := reminderOffsets()
+	if len(offsets) == 0 {
+		return
+	}
+
+	now := time.Now()
+	var appointments []models.Appointment
+	if err := config.DB.
+		Where("status = ? AND date >= ? AND date <= ?", "Scheduled", startOfToday(), now.Add(offsets[0])).
+		Find(&appointments).Error; err != nil {
+		log.Println("Failed to fetch appointments for reminders:", err)
+		return
+	}
+
+	for _, appointment := range appointments {
+		start, ok := appointment.ScheduledAt()
+		if !ok || !start.After(now) {
+			continue
+		}
+
+		var due time.Duration
+		for _, offset := range offsets {
+			if !now.Before(start.Add(-offset)) {
+				due = offset
+			}
+		}
+		if due == 0 {
+			continue
+		}
+
+		scheduledFor := start.Add(-due)
+		var existing int64
+		config.DB.Model(&models.Notification{}).
+			Where("appointment_id = ? AND kind = ? AND scheduled_for = ?", appointment.ID, "Reminder", scheduledFor).
+			Count(&existing)
+		if existing > 0 {
+			continue
+		}
+
+		queueAppointmentNotification(appointment, "Reminder", scheduledFor)
+	}
+}
+
+// DispatchNotifications delivers pending notifications, backing off
+// exponentially between failed attempts.
+func DispatchNotifications() {
+	var pending []models.Notification
+	if err := config.DB.
+		Where("status = ? AND next_attempt_at <= ?", "Pending", time.Now()).
+		Order("next_attempt_at ASC").
+		Limit(notificationBatchSize).
+		Find(&pending).Error; err != nil {
+		log.Println("Failed to fetch pending notifications:", err)
+		return
+	}
+
+	for i := range pending {
+		deliverNotification(&pending[i])
+	}
+}
+
+func deliverNotification(n *models.Notification) {
+	now := time.Now()
+
+	// Reminders for appointments that were cancelled or moved since queueing
+	// are dropped; the moved appointment gets a reminder of its own. Other
+	// changes, e.g. of doctor, are picked up by writing the message again.
+	if n.Kind == "Reminder" {
+		var appointment models.Appointment
+		if err := config.DB.First(&appointment, n.AppointmentID).Error; err != nil || appointment.Status != "Scheduled" {
+			n.Status = "Skipped"
+			n.LastError = "Appointment is no longer scheduled"
+			config.DB.Save(n)
+			return
+		}
+		start, _ := appointment.ScheduledAt()
+		if n.AppointmentAt != nil && !start.Equal(*n.AppointmentAt) {
+			n.Status = "Skipped"
+			n.LastError = "Appointment was rescheduled"
+			config.DB.Save(n)
+			return
+		}
+		var patient models.Patient
+		var doctor models.Doctor
+		config.DB.First(&patient, appointment.PatientID)
+		config.DB.First(&doctor, appointment.DoctorID)
+		n.Subject, n.Body = renderAppointmentMessage(appointment, patient, doctor, n.Kind)
+	}
+
+	var channel notify.Channel
+	for _, ch := range notificationChannels {
+		if ch.Name() == n.Channel {
+			channel = ch
+			break
+		}
+	}
+	if channel == nil {
+		n.Status = "Failed"
+		n.LastError = "Channel is not configured"
+		config.DB.Save(n)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	err := channel.Send(ctx, notify.Message{
+		Kind:          n.Kind,
+		AppointmentID: n.AppointmentID,
+		Email:         n.Email,
+		Phone:         n.Phone,
+		Subject:       n.Subject,
+		Body:          n.Body,
+	})
+
+	n.Attempts++
+	switch {
+	case err == nil:
+		n.Status = "Sent"
+		n.SentAt = &now
+		n.LastError = ""
+	case errors.Is(err, notify.ErrNoRecipient):
+		n.Status = "Skipped"
+		n.LastError = err.Error()
+	case n.Attempts >= maxNotificationAttempts:
+		n.Status = "Failed"
+		n.LastError = err.Error()
+	default:
+		n.LastError = err.Error()
+		n.NextAttemptAt = now.Add(time.Minute << (n.Attempts - 1))
+	}
+
+	if err := config.DB.Save(n).Error; err != nil {
+		log.Println("Failed to record notification delivery:", err)
+	}
+}
+
+// notifyAppointment queues an immediate notice, e.g. a confirmation or
+// cancellation, about the appointment.
+func notifyAppointment(appointment models.Appointment, kind string) {
+	queueAppointmentNotification(appointment, kind, time.Now())
+}
+
+// notifySeriesConfirmation confirms a new series with one message listing
+// every visit, queued against the first of them.
+func notifySeriesConfirmation(series models.AppointmentSeries) {
+	if len(series.Appointments) == 0 {
+		return
+	}
+
+	with := "your doctor"
+	if series.Doctor.Name != "" {
+		with = "Dr. " + series.Doctor.Name
+	}
+	var body strings.Builder
+	fmt.Fprintf(&body, "Dear %s, your %d appointments with %s are confirmed for:\n", series.Patient.Name, len(series.Appointments), with)
+	for _, appointment := range series.Appointments {
+		when := appointment.Date.Format("Monday, 02 January 2006")
+		if appointment.Time != "" {
+			when += " at " + appointment.Time
+		}
+		body.WriteString("- " + when + "\n")
+	}
+
+	queueNotification(series.Appointments[0], series.Patient, "Confirmation", "Appointments confirmed", body.String(), time.Now())
+}
+
+// queueAppointmentNotification renders the message once and queues it for
+// every configured channel the patient has not opted out of.
+func queueAppointmentNotification(appointment models.Appointment, kind string, scheduledFor time.Time) {
+	var patient models.Patient
+	if err := config.DB.First(&patient, appointment.PatientID).Error; err != nil {
+		return
+	}
+	var doctor models.Doctor
+	config.DB.First(&doctor, appointment.DoctorID)
+
+	subject, body := renderAppointmentMessage(appointment, patient, doctor, kind)
+	queueNotification(appointment, patient, kind, subject, body, scheduledFor)
+}
+
+// queueNotification queues the message about the appointment for every
+// configured channel the patient has not opted out of.
+func queueNotification(appointment models.Appointment, patient models.Patient, kind, subject, body string, scheduledFor time.Time) {
+	preferences := notificationPreferences(patient.ID)
+	if preferences.OptOutAll || (kind == "Reminder" && preferences.OptOutReminders) {
+		return
+	}
+	optedOut := map[string]bool{}
+	for _, name := range strings.Split(preferences.OptOutChannels, ",") {
+		optedOut[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+
+	var appointmentAt *time.Time
+	if start, ok := appointment.ScheduledAt(); ok {
+		appointmentAt = &start
+	}
+
+	for _, channel := range notificationChannels {
+		if optedOut[channel.Name()] {
+			continue
+		}
+		notification := models.Notification{
+			AppointmentID: appointment.ID,
+			PatientID:     patient.ID,
+			Kind:          kind,
+			Channel:       channel.Name(),
+			Email:         patient.Email,
+			Phone:         patient.Phone,
+			Subject:       subject,
+			Body:          body,
+			Status:        "Pending",
+			ScheduledFor:  scheduledFor,
+			AppointmentAt: appointmentAt,
+			NextAttemptAt: scheduledFor,
+		}
+		if err := config.DB.Create(&notification).Error; err != nil {
+			log.Println("Failed to queue notification:", err)
+		}
+	}
+}
+
+func renderAppointmentMessage(a models.Appointment, patient models.Patient, doctor models.Doctor, kind string) (string, string) {
+	when := a.Date.Format("Monday, 02 January 2006")
+	if a.Time != "" {
+		when += " at " + a.Time
+	}
+	with := "your doctor"
+	if doctor.Name != "" {
+		with = "Dr. " + doctor.Name
+	}
+
+	switch kind {
+	case "Cancellation":
+		return "Appointment cancelled",
+			fmt.Sprintf("Dear %s, your appointment with %s on %s has been cancelled.", patient.Name, with, when)
+	case "Reminder":
+		return "Appointment reminder",
+			fmt.Sprintf("Dear %s, this is a reminder of your appointment with %s on %s.", patient.Name, with, when)
+	default:
+		return "Appointment confirmed",
+			fmt.Sprintf("Dear %s, your appointment with %s is confirmed for %s.", patient.Name, with, when)
+	}
+}
+
+// notificationPreferences returns the stored preferences for the patient, or
+// the defaults (everything enabled) when none were saved.
+func notificationPreferences(patientID uint) models.NotificationPreference {
+	var preferences models.NotificationPreference
+	if err := config.DB.Where("patient_id = ?", patientID).First(&preferences).Error; err != nil {
+		return models.NotificationPreference{PatientID: patientID}
+	}
+	return preferences
+}
+
+// reminderOffsets reads REMINDER_OFFSETS (e.g. "24h,2h"), largest first.
+func reminderOffsets() []time.Duration {
+	raw := os.Getenv("REMINDER_OFFSETS")
+	if raw == "" {
+		raw = "24h,2h"
+	}
+
+	var offsets []time.Duration
+	for _, part := range strings.Split(raw, ",") {
+		offset, err := time.ParseDuration(strings.TrimSpace(part))
+		if err != nil || offset <= 0 {
+			continue
+		}
+		offsets = append(offsets, offset)
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] > offsets[j] })
+	return offsets
+}
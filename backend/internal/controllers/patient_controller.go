@@ -41,8 +41,12 @@ func GetPatientByID(c *gin.Context) {
 		return
 	}
 
+	// Allergies and active problems are part of the summary prescribers see
 	var patient models.Patient
-	if err := config.DB.First(&patient, id).Error; err != nil {
+	if err := config.DB.
+		Preload("Allergies", "verification_status <> ?", "Refuted").
+		Preload("Problems", "status = ?", "Active").
+		First(&patient, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Patient not found"})
 		return
 	}
@@ -68,6 +72,10 @@ func UpdatePatient(c *gin.Context) {
 		return
 	}
 
+	// Allergies and problems are managed through their own endpoints
+	patient.Allergies = nil
+	patient.Problems = nil
+
 	if err := config.DB.Save(&patient).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update patient"})
 		return
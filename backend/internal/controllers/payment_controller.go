@@ -0,0 +1,559 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"clinic-backend/internal/config"
+	"clinic-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var paymentMethods = map[string]bool{
+	"Cash":        true,
+	"Card":        true,
+	"MobileMoney": true,
+	"Insurance":   true,
+	"Online":      true,
+	"Other":       true,
+}
+
+// paymentError is a ledger entry the bill cannot take in its current state.
+type paymentError string
+
+func (e paymentError) Error() string { return string(e) }
+
+// RecordPayment takes a payment towards a bill in the caller's open cashier
+// session. A bill cannot be paid beyond the patient's outstanding balance;
+// the insurer's portion of an insured bill is paid through remittances. Only
+// admins can date a payment on an earlier day.
+func RecordPayment(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bill ID"})
+		return
+	}
+
+	var input struct {
+		Method     string          `json:"method" binding:"required"`
+		Amount     decimal.Decimal `json:"amount"`
+		Reference  string          `json:"reference"`
+		ReceivedAt *time.Time      `json:"receivedAt"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !paymentMethods[input.Method] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment method " + input.Method})
+		return
+	}
+	if !input.Amount.IsPositive() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Amount must be positive"})
+		return
+	}
+
+	entry := models.Payment{
+		Kind:       "Payment",
+		Method:     input.Method,
+		Amount:     input.Amount,
+		Reference:  strings.TrimSpace(input.Reference),
+		ReceivedBy: currentUserID(c),
+		ReceivedAt: time.Now(),
+	}
+	if input.ReceivedAt != nil {
+		if !checkReceivedAt(c, *input.ReceivedAt) {
+			return
+		}
+		entry.ReceivedAt = *input.ReceivedAt
+	}
+
+	err = postLedgerEntry(uint(id), &entry, func(tx *gorm.DB, bill models.Bill) error {
+		if bill.Status == "WrittenOff" {
+			return paymentError("Bill is written off")
+		}
+		if bill.Status == "Void" {
+			return paymentError("Bill is void")
+		}
+		if bill.PolicyID != nil && entry.Method == "Insurance" {
+			return paymentError("Insurer payments are recorded as remittances")
+		}
+		if entry.Amount.GreaterThan(bill.PatientBalance) {
+			return paymentError(fmt.Sprintf("Amount exceeds the outstanding balance of %s %s", bill.PatientBalance.String(), bill.Currency))
+		}
+		return attachCashierSession(tx, &entry, bill, true)
+	})
+	respondLedgerEntry(c, entry, err)
+}
+
+// RefundPayment pays money back against an earlier payment, by the same
+// method unless another is given, out of the caller's open cashier session.
+func RefundPayment(c *gin.Context) {
+	payment, ok := findPayment(c)
+	if !ok {
+		return
+	}
+	if payment.Kind != "Payment" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only payments can be refunded or reversed"})
+		return
+	}
+
+	var input struct {
+		Amount    decimal.Decimal `json:"amount"`
+		Method    string          `json:"method"`
+		Reference string          `json:"reference"`
+		Reason    string          `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Method == "" {
+		input.Method = payment.Method
+	}
+	if !paymentMethods[input.Method] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment method " + input.Method})
+		return
+	}
+	if !input.Amount.IsPositive() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Amount must be positive"})
+		return
+	}
+
+	entry := models.Payment{
+		Kind:       "Refund",
+		Method:     input.Method,
+		Amount:     input.Amount.Neg(),
+		Reference:  strings.TrimSpace(input.Reference),
+		RelatedID:  &payment.ID,
+		Reason:     strings.TrimSpace(input.Reason),
+		ReceivedBy: currentUserID(c),
+		ReceivedAt: time.Now(),
+	}
+
+	err := postLedgerEntry(payment.BillID, &entry, func(tx *gorm.DB, bill models.Bill) error {
+		if bill.PolicyID != nil && payment.Method == "Insurance" {
+			return paymentError("Insurer payments are settled with the insurer")
+		}
+		remaining, err := refundable(tx, payment)
+		if err != nil {
+			return err
+		}
+		if input.Amount.GreaterThan(remaining) {
+			return paymentError(fmt.Sprintf("Only %s %s of the payment can be refunded", remaining.String(), bill.Currency))
+		}
+		return attachCashierSession(tx, &entry, bill, true)
+	})
+	respondLedgerEntry(c, entry, err)
+}
+
+// ReversePayment cancels a payment that did not go through, e.g. a bounced
+// cheque or a card chargeback. Whatever was not refunded is reversed. Unlike
+// refunds, reversals need no cashier session as no money leaves the drawer.
+func ReversePayment(c *gin.Context) {
+	payment, ok := findPayment(c)
+	if !ok {
+		return
+	}
+	if payment.Kind != "Payment" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only payments can be refunded or reversed"})
+		return
+	}
+
+	var input struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry := models.Payment{
+		Kind:       "Reversal",
+		Method:     payment.Method,
+		Reference:  payment.Reference,
+		RelatedID:  &payment.ID,
+		Reason:     strings.TrimSpace(input.Reason),
+		ReceivedBy: currentUserID(c),
+		ReceivedAt: time.Now(),
+	}
+
+	err := postLedgerEntry(payment.BillID, &entry, func(tx *gorm.DB, bill models.Bill) error {
+		if bill.PolicyID != nil && payment.Method == "Insurance" {
+			return paymentError("Insurer payments are settled with the insurer")
+		}
+		remaining, err := refundable(tx, payment)
+		if err != nil {
+			return err
+		}
+		if !remaining.IsPositive() {
+			return paymentError("Payment is already fully refunded or reversed")
+		}
+		entry.Amount = remaining.Neg()
+		return attachCashierSession(tx, &entry, bill, false)
+	})
+	respondLedgerEntry(c, entry, err)
+}
+
+// WriteOffBill gives up on collecting a bill's outstanding balance.
+func WriteOffBill(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bill ID"})
+		return
+	}
+
+	var input struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var bill models.Bill
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&bill, id).Error; err != nil {
+			return err
+		}
+		if bill.Status == "WrittenOff" || !bill.Balance.IsPositive() {
+			return paymentError("Only bills with an outstanding balance can be written off")
+		}
+		bill.Status = "WrittenOff"
+		bill.WriteOffReason = strings.TrimSpace(input.Reason)
+		return tx.Model(&bill).Updates(map[string]interface{}{"status": bill.Status, "write_off_reason": bill.WriteOffReason}).Error
+	})
+	var rejected paymentError
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bill not found"})
+		return
+	case errors.As(err, &rejected):
+		c.JSON(http.StatusConflict, gin.H{"error": rejected.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write off bill"})
+		return
+	}
+
+	c.JSON(http.StatusOK, bill)
+}
+
+func GetBillPayments(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bill ID"})
+		return
+	}
+
+	var payments []models.Payment
+	if err := config.DB.Where("bill_id = ?", id).Order("received_at ASC, id ASC").Find(&payments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, payments)
+}
+
+// GetPayments lists ledger entries received in a date range (the last 30
+// days by default), optionally by method, kind or patient.
+func GetPayments(c *gin.Context) {
+	from, to, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+
+	query := config.DB.Where("received_at >= ? AND received_at < ?", from, to).Order("received_at DESC, id DESC")
+	if method := c.Query("method"); method != "" {
+		query = query.Where("method = ?", method)
+	}
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	if patientID := c.Query("patientId"); patientID != "" {
+		query = query.Where("patient_id = ?", patientID)
+	}
+
+	var payments []models.Payment
+	if err := query.Find(&payments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, payments)
+}
+
+// GetPaymentReceipt returns the receipt for a payment, refund or reversal.
+func GetPaymentReceipt(c *gin.Context) {
+	payment, ok := findPayment(c)
+	if !ok {
+		return
+	}
+
+	var bill models.Bill
+	if err := config.DB.Preload("Patient").First(&bill, payment.BillID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bill not found"})
+		return
+	}
+	var cashier models.User
+	config.DB.First(&cashier, payment.ReceivedBy)
+
+	if wantsPDF(c) {
+		renderReceiptPDF(c, payment, bill, cashier)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"receiptNumber": payment.ReceiptNumber,
+		"issuedAt":      payment.ReceivedAt,
+		"kind":          payment.Kind,
+		"method":        payment.Method,
+		"reference":     payment.Reference,
+		"amount":        payment.Amount.Abs(),
+		"currency":      payment.Currency,
+		"reason":        payment.Reason,
+		"billId":        bill.ID,
+		"invoiceNumber": bill.InvoiceNumber,
+		"billTotal":     bill.Amount,
+		"balanceAfter":  payment.BalanceAfter,
+		"patientId":     bill.PatientID,
+		"patientName":   bill.Patient.Name,
+		"receivedBy":    cashier.Name,
+	})
+}
+
+// MarkOverdueBills flags unsettled bills whose due date has passed.
+func MarkOverdueBills() {
+	if err := config.DB.Model(&models.Bill{}).
+		Where("status IN ? AND due_date < ?", []string{"Unpaid", "PartiallyPaid"}, startOfToday()).
+		Update("status", "Overdue").Error; err != nil {
+		log.Println("Failed to mark overdue bills:", err)
+	}
+}
+
+// SeedPaymentLedger brings bills from before the payments ledger into it:
+// bills marked paid get a ledger entry for their amount and every bill's
+// balance is derived. It does nothing once the ledger has entries.
+func SeedPaymentLedger() {
+	var count int64
+	config.DB.Model(&models.Payment{}).Count(&count)
+	if count > 0 {
+		return
+	}
+
+	var paid []models.Bill
+	config.DB.Where("status = ? AND amount > 0", "Paid").Find(&paid)
+	for _, bill := range paid {
+		receivedAt := bill.UpdatedAt
+		if bill.PaymentDate != nil {
+			receivedAt = *bill.PaymentDate
+		}
+		entry := models.Payment{
+			BillID:     bill.ID,
+			PatientID:  bill.PatientID,
+			Kind:       "Payment",
+			Method:     "Other",
+			Amount:     bill.Amount,
+			Currency:   bill.Currency,
+			Reason:     "Recorded before the payments ledger",
+			ReceivedAt: receivedAt,
+		}
+		if err := config.DB.Create(&entry).Error; err != nil {
+			log.Println("Failed to seed payment ledger:", err)
+			return
+		}
+		config.DB.Model(&entry).Update("receipt_number", receiptNumber(entry.ID))
+	}
+
+	if err := config.DB.Exec(`UPDATE bills SET
+		amount_paid = COALESCE((SELECT SUM(amount) FROM payments WHERE payments.bill_id = bills.id), 0),
+		balance = amount - COALESCE((SELECT SUM(amount) FROM payments WHERE payments.bill_id = bills.id), 0)`).Error; err != nil {
+		log.Println("Failed to derive bill balances:", err)
+	}
+}
+
+// checkReceivedAt accepts the time money was received as given by the caller,
+// writing the error response when it is in the future, or on an earlier day
+// and the caller is not an admin.
+func checkReceivedAt(c *gin.Context, receivedAt time.Time) bool {
+	if receivedAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Received time cannot be in the future"})
+		return false
+	}
+	if role, _ := c.Get("userRole"); role != "admin" && receivedAt.Before(startOfToday()) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can record money received on an earlier day"})
+		return false
+	}
+	return true
+}
+
+// postLedgerEntry adds the entry to the bill's ledger in a transaction of its
+// own.
+func postLedgerEntry(billID uint, entry *models.Payment, check func(tx *gorm.DB, bill models.Bill) error) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		return addLedgerEntry(tx, billID, entry, check)
+	})
+}
+
+// addLedgerEntry adds the entry to the bill's ledger once check accepts it,
+// then brings the bill's balance and status up to date. Entries cannot be
+// dated on a day that has been closed. The bill row stays locked until the
+// transaction ends so concurrent payments are applied one at a time.
+func addLedgerEntry(tx *gorm.DB, billID uint, entry *models.Payment, check func(tx *gorm.DB, bill models.Bill) error) error {
+	var bill models.Bill
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&bill, billID).Error; err != nil {
+		return err
+	}
+
+	currency, err := billCurrency(bill.Currency)
+	if err != nil {
+		return err
+	}
+	if !entry.Amount.Equal(entry.Amount.Round(currency.Decimals)) {
+		return paymentError(fmt.Sprintf("%s amounts have at most %d decimals", currency.Code, currency.Decimals))
+	}
+	closed, err := dayClosed(tx, entry.ReceivedAt)
+	if err != nil {
+		return err
+	}
+	if closed {
+		return paymentError("Payments of " + entry.ReceivedAt.Format("02 Jan 2006") + " are closed")
+	}
+	if err := check(tx, bill); err != nil {
+		return err
+	}
+
+	entry.BillID = bill.ID
+	entry.PatientID = bill.PatientID
+	entry.Currency = bill.Currency
+	if err := tx.Create(entry).Error; err != nil {
+		return err
+	}
+	if err := refreshBillBalance(tx, &bill); err != nil {
+		return err
+	}
+
+	entry.ReceiptNumber = receiptNumber(entry.ID)
+	entry.BalanceAfter = bill.Balance
+	return tx.Model(entry).Updates(map[string]interface{}{
+		"receipt_number": entry.ReceiptNumber,
+		"balance_after":  entry.BalanceAfter,
+	}).Error
+}
+
+func respondLedgerEntry(c *gin.Context, entry models.Payment, err error) {
+	var rejected paymentError
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bill not found"})
+	case errors.As(err, &rejected):
+		c.JSON(http.StatusConflict, gin.H{"error": rejected.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record " + strings.ToLower(entry.Kind)})
+	default:
+		c.JSON(http.StatusCreated, entry)
+	}
+}
+
+// refreshBillBalance derives what has been paid on the bill from its ledger
+// and updates the balances and status to match. On insured bills, entries
+// with the Insurance method count towards the insurer's portion and all
+// others towards the patient's.
+func refreshBillBalance(tx *gorm.DB, bill *models.Bill) error {
+	var paid, payerPaid decimal.Decimal
+	if err := tx.Model(&models.Payment{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("bill_id = ?", bill.ID).
+		Row().Scan(&paid); err != nil {
+		return err
+	}
+	if bill.PolicyID != nil {
+		if err := tx.Model(&models.Payment{}).
+			Select("COALESCE(SUM(amount), 0)").
+			Where("bill_id = ? AND method = ?", bill.ID, "Insurance").
+			Row().Scan(&payerPaid); err != nil {
+			return err
+		}
+	}
+
+	bill.AmountPaid = paid
+	bill.Balance = bill.Amount.Sub(paid)
+	bill.PayerBalance = bill.PayerAmount.Sub(payerPaid)
+	bill.PatientBalance = bill.PatientAmount.Sub(paid.Sub(payerPaid))
+	settleBillStatus(bill, time.Now())
+
+	return tx.Model(bill).Updates(map[string]interface{}{
+		"amount_paid":     bill.AmountPaid,
+		"balance":         bill.Balance,
+		"patient_balance": bill.PatientBalance,
+		"payer_balance":   bill.PayerBalance,
+		"status":          bill.Status,
+		"payment_date":    bill.PaymentDate,
+	}).Error
+}
+
+// settleBillStatus sets the status that follows from the bill's balance and
+// due date. Written-off and void bills stay so.
+func settleBillStatus(bill *models.Bill, now time.Time) {
+	if bill.Status == "WrittenOff" || bill.Status == "Void" {
+		return
+	}
+
+	if !bill.Balance.IsPositive() {
+		bill.Status = "Paid"
+		if bill.PaymentDate == nil {
+			bill.PaymentDate = &now
+		}
+		return
+	}
+
+	switch {
+	case bill.DueDate != nil && bill.DueDate.Before(startOfToday()):
+		bill.Status = "Overdue"
+	case bill.AmountPaid.IsPositive():
+		bill.Status = "PartiallyPaid"
+	default:
+		bill.Status = "Unpaid"
+	}
+	bill.PaymentDate = nil
+}
+
+// refundable is how much of a payment has not yet been refunded or reversed.
+func refundable(tx *gorm.DB, payment models.Payment) (decimal.Decimal, error) {
+	var returned decimal.Decimal
+	err := tx.Model(&models.Payment{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("related_id = ?", payment.ID).
+		Row().Scan(&returned)
+	return payment.Amount.Add(returned), err
+}
+
+func findPayment(c *gin.Context) (models.Payment, bool) {
+	var payment models.Payment
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment ID"})
+		return payment, false
+	}
+
+	if err := config.DB.First(&payment, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+		return payment, false
+	}
+
+	return payment, true
+}
+
+func receiptNumber(id uint) string {
+	return fmt.Sprintf("RC-%06d", id)
+}
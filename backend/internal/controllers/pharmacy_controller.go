@@ -0,0 +1,522 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"clinic-backend/internal/config"
+	"clinic-backend/internal/models"
+	"clinic-backend/internal/money"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// errInsufficientStock is returned when the usable batches of a drug cannot
+// cover a dispense.
+var errInsufficientStock = errors.New("Insufficient stock")
+
+// errPrescriptionChanged is returned when another dispense or an edit got to
+// the prescription between reading and locking it.
+var errPrescriptionChanged = errors.New("Prescription changed while dispensing. Reload and try again")
+
+// stockError rejects a delivery that does not match the stocked batch.
+type stockError string
+
+func (e stockError) Error() string { return string(e) }
+
+// GetPharmacyQueue lists prescriptions that still have items to dispense,
+// oldest first.
+func GetPharmacyQueue(c *gin.Context) {
+	var prescriptions []models.Prescription
+	query := config.DB.Preload("Patient").Preload("Doctor").Preload("Items.Drug").
+		Where("status IN ?", []string{"Pending", "PartiallyDispensed"}).
+		Order("date ASC, id ASC")
+
+	if patientID := c.Query("patientId"); patientID != "" {
+		query = query.Where("patient_id = ?", patientID)
+	}
+
+	if err := query.Find(&prescriptions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch pharmacy queue"})
+		return
+	}
+
+	c.JSON(http.StatusOK, prescriptions)
+}
+
+// DispensePrescription hands out medication for a prescription, taking stock
+// from the batches that expire first and billing the dispensed items. Items
+// not listed in the request are dispensed in full, so an empty body
+// dispenses whatever is left.
+func DispensePrescription(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid prescription ID"})
+		return
+	}
+
+	var input struct {
+		Items []struct {
+			PrescriptionItemID uint    `json:"prescriptionItemId"`
+			Quantity           float64 `json:"quantity"`
+		} `json:"items"`
+		Notes string `json:"notes"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var prescription models.Prescription
+	if err := config.DB.Preload("Items.Drug").First(&prescription, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Prescription not found"})
+		return
+	}
+
+	if prescription.Status == "Dispensed" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Prescription is already fully dispensed"})
+		return
+	}
+
+	// Work out how much of each item to hand out
+	requested := map[uint]float64{}
+	partial := len(input.Items) > 0
+	for _, item := range input.Items {
+		if item.Quantity <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Quantities must be positive"})
+			return
+		}
+		requested[item.PrescriptionItemID] += item.Quantity
+	}
+
+	quantities := map[uint]float64{}
+	for _, item := range prescription.Items {
+		remaining := item.Quantity - item.DispensedQuantity
+		quantity, listed := requested[item.ID]
+		delete(requested, item.ID)
+		if !partial {
+			quantity = remaining
+		} else if !listed {
+			continue
+		}
+		if quantity > remaining {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Only %s of %s left to dispense", formatQuantity(remaining), item.Drug.GenericName)})
+			return
+		}
+		if quantity > 0 {
+			quantities[item.ID] = quantity
+		}
+	}
+	if len(requested) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Item not found on prescription"})
+		return
+	}
+	if len(quantities) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing to dispense"})
+		return
+	}
+
+	// Drug prices are in the default currency
+	currency, err := billCurrency("")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	dispense := models.Dispense{
+		PrescriptionID: prescription.ID,
+		PatientID:      prescription.PatientID,
+		DispensedBy:    currentUserID(c),
+		DispensedAt:    time.Now(),
+		Notes:          strings.TrimSpace(input.Notes),
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		// Concurrent dispenses queue up on the prescription row and check
+		// the quantities against what was dispensed meanwhile
+		var locked models.Prescription
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, prescription.ID).Error; err != nil {
+			return err
+		}
+		var current []models.PrescriptionItem
+		if err := tx.Where("prescription_id = ?", prescription.ID).Find(&current).Error; err != nil {
+			return err
+		}
+		dispensed := map[uint]float64{}
+		for _, item := range current {
+			dispensed[item.ID] = item.DispensedQuantity
+		}
+
+		var charges []models.BillItem
+
+		for i := range prescription.Items {
+			item := &prescription.Items[i]
+			already, ok := dispensed[item.ID]
+			if !ok {
+				return errPrescriptionChanged
+			}
+			item.DispensedQuantity = already
+
+			quantity, ok := quantities[item.ID]
+			if !ok {
+				continue
+			}
+			if item.DispensedQuantity+quantity > item.Quantity {
+				return errPrescriptionChanged
+			}
+
+			taken, err := takeStock(tx, item.Drug, quantity, currency.Rule())
+			if err != nil {
+				return err
+			}
+			for _, part := range taken {
+				part.PrescriptionItemID = item.ID
+				dispense.Items = append(dispense.Items, part)
+			}
+
+			item.DispensedQuantity += quantity
+			if err := tx.Model(item).Update("dispensed_quantity", item.DispensedQuantity).Error; err != nil {
+				return err
+			}
+			if item.Drug.UnitPrice.IsPositive() {
+				charges = append(charges, models.BillItem{
+					SourceType: "Dispense",
+					Service:    strings.TrimSpace(item.Drug.GenericName + " " + item.Drug.Strength),
+					Quantity:   dispenseQuantity(quantity),
+					UnitPrice:  item.Drug.UnitPrice,
+				})
+			}
+		}
+
+		if err := tx.Create(&dispense).Error; err != nil {
+			return err
+		}
+
+		// Dispensed medication is billed to the patient straight away
+		if len(charges) > 0 {
+			bill := models.Bill{
+				PatientID:   prescription.PatientID,
+				EncounterID: prescription.EncounterID,
+				Description: fmt.Sprintf("Pharmacy dispense for prescription #%d", prescription.ID),
+				Items:       charges,
+			}
+			for i := range bill.Items {
+				bill.Items[i].SourceID = &dispense.ID
+			}
+			if err := issueBill(tx, &bill, currency); err != nil {
+				return err
+			}
+			dispense.BillID = &bill.ID
+			if err := tx.Model(&dispense).Update("bill_id", bill.ID).Error; err != nil {
+				return err
+			}
+		}
+
+		prescription.Status = "Dispensed"
+		for _, item := range prescription.Items {
+			if item.DispensedQuantity < item.Quantity {
+				prescription.Status = "PartiallyDispensed"
+			}
+		}
+		return tx.Model(&prescription).Update("status", prescription.Status).Error
+	})
+	if err != nil {
+		if errors.Is(err, errInsufficientStock) || errors.Is(err, errPrescriptionChanged) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to dispense prescription"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"dispense": dispense, "status": prescription.Status})
+}
+
+func GetPrescriptionDispenses(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid prescription ID"})
+		return
+	}
+
+	var dispenses []models.Dispense
+	if err := config.DB.Preload("Items").Where("prescription_id = ?", id).Order("dispensed_at ASC").Find(&dispenses).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch dispenses"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dispenses)
+}
+
+// ReceiveStock books a delivered batch. Receiving a batch number that is
+// already stocked adds to it.
+func ReceiveStock(c *gin.Context) {
+	var batch models.StockBatch
+	if err := c.ShouldBindJSON(&batch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var drug models.Drug
+	if err := config.DB.First(&drug, batch.DrugID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Drug not found"})
+		return
+	}
+
+	batch.BatchNumber = strings.TrimSpace(batch.BatchNumber)
+	if batch.BatchNumber == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Batch number is required"})
+		return
+	}
+	if batch.ExpiryDate.IsZero() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Expiry date is required"})
+		return
+	}
+	if batch.ReceivedQuantity <= 0 {
+		batch.ReceivedQuantity = batch.Quantity
+	}
+	if batch.ReceivedQuantity <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Received quantity must be positive"})
+		return
+	}
+	if batch.ReceivedAt.IsZero() {
+		batch.ReceivedAt = time.Now()
+	}
+
+	// A delivery of a batch that is already stocked adds to it. The insert
+	// skips on the unique batch index, so concurrent deliveries of the same
+	// batch end up adding to one row under its lock.
+	batch.ID = 0
+	batch.Quantity = batch.ReceivedQuantity
+	created := false
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&batch)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			created = true
+			return nil
+		}
+
+		var existing models.StockBatch
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("drug_id = ? AND batch_number = ?", batch.DrugID, batch.BatchNumber).
+			First(&existing).Error; err != nil {
+			return err
+		}
+		if !existing.ExpiryDate.Equal(batch.ExpiryDate) {
+			return stockError("Batch " + batch.BatchNumber + " is already stocked with a different expiry date")
+		}
+		existing.ReceivedQuantity += batch.ReceivedQuantity
+		existing.Quantity += batch.ReceivedQuantity
+		if err := tx.Model(&existing).Select("received_quantity", "quantity").Updates(&existing).Error; err != nil {
+			return err
+		}
+		batch = existing
+		return nil
+	})
+	var rejected stockError
+	if errors.As(err, &rejected) {
+		c.JSON(http.StatusConflict, gin.H{"error": rejected.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to receive stock"})
+		return
+	}
+
+	if created {
+		c.JSON(http.StatusCreated, batch)
+		return
+	}
+	c.JSON(http.StatusOK, batch)
+}
+
+func GetStockBatches(c *gin.Context) {
+	var batches []models.StockBatch
+	query := config.DB.Preload("Drug").Order("expiry_date ASC")
+
+	if drugID := c.Query("drugId"); drugID != "" {
+		query = query.Where("drug_id = ?", drugID)
+	}
+
+	// Empty batches are hidden unless asked for
+	if c.Query("all") != "true" {
+		query = query.Where("quantity > 0")
+	}
+
+	if err := query.Find(&batches).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock"})
+		return
+	}
+
+	c.JSON(http.StatusOK, batches)
+}
+
+// AdjustStockBatch corrects the remaining quantity of a batch after a stock
+// count, or writes it off.
+func AdjustStockBatch(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stock batch ID"})
+		return
+	}
+
+	var batch models.StockBatch
+	if err := config.DB.First(&batch, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Stock batch not found"})
+		return
+	}
+
+	var input struct {
+		Quantity *float64 `json:"quantity" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if *input.Quantity < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Quantity cannot be negative"})
+		return
+	}
+
+	if err := config.DB.Model(&batch).Update("quantity", *input.Quantity).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to adjust stock"})
+		return
+	}
+
+	c.JSON(http.StatusOK, batch)
+}
+
+// GetStockLevels totals the usable (unexpired) stock of every drug.
+func GetStockLevels(c *gin.Context) {
+	levels, err := stockLevels()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock levels"})
+		return
+	}
+
+	c.JSON(http.StatusOK, levels)
+}
+
+// GetLowStockReport lists drugs whose usable stock is at or below their
+// reorder level.
+func GetLowStockReport(c *gin.Context) {
+	levels, err := stockLevels()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock levels"})
+		return
+	}
+
+	low := []stockLevel{}
+	for _, level := range levels {
+		if level.Stock <= level.ReorderLevel {
+			low = append(low, level)
+		}
+	}
+
+	c.JSON(http.StatusOK, low)
+}
+
+// GetNearExpiryReport lists stocked batches expiring within ?days= (default
+// 90), including ones that have already expired.
+func GetNearExpiryReport(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "90"))
+	if err != nil || days < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid days"})
+		return
+	}
+
+	var batches []models.StockBatch
+	if err := config.DB.Preload("Drug").
+		Where("quantity > 0 AND expiry_date < ?", startOfToday().AddDate(0, 0, days+1)).
+		Order("expiry_date ASC").
+		Find(&batches).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch near-expiry stock"})
+		return
+	}
+
+	c.JSON(http.StatusOK, batches)
+}
+
+type stockLevel struct {
+	DrugID       uint    `json:"drugId"`
+	GenericName  string  `json:"genericName"`
+	Strength     string  `json:"strength"`
+	Form         string  `json:"form"`
+	Stock        float64 `json:"stock"`
+	ReorderLevel float64 `json:"reorderLevel"`
+}
+
+func stockLevels() ([]stockLevel, error) {
+	var levels []stockLevel
+	err := config.DB.Model(&models.Drug{}).
+		Select("drugs.id AS drug_id, drugs.generic_name, drugs.strength, drugs.form, drugs.reorder_level, "+
+			"COALESCE(SUM(stock_batches.quantity), 0) AS stock").
+		Joins("LEFT JOIN stock_batches ON stock_batches.drug_id = drugs.id AND stock_batches.expiry_date >= ?", startOfToday()).
+		Where("drugs.discontinued = ?", false).
+		Group("drugs.id").
+		Order("drugs.generic_name ASC").
+		Scan(&levels).Error
+	return levels, err
+}
+
+// takeStock removes the quantity from the drug's unexpired batches, those
+// expiring first going first, and returns what was taken from each, priced
+// by the rounding rule.
+func takeStock(tx *gorm.DB, drug models.Drug, quantity float64, rule money.Rule) ([]models.DispenseItem, error) {
+	var batches []models.StockBatch
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("drug_id = ? AND quantity > 0 AND expiry_date >= ?", drug.ID, startOfToday()).
+		Order("expiry_date ASC, id ASC").
+		Find(&batches).Error; err != nil {
+		return nil, err
+	}
+
+	var taken []models.DispenseItem
+	left := quantity
+	for _, batch := range batches {
+		if left <= 0 {
+			break
+		}
+		part := math.Min(left, batch.Quantity)
+		if err := tx.Model(&batch).Update("quantity", batch.Quantity-part).Error; err != nil {
+			return nil, err
+		}
+		taken = append(taken, models.DispenseItem{
+			DrugID:       drug.ID,
+			StockBatchID: batch.ID,
+			BatchNumber:  batch.BatchNumber,
+			Quantity:     part,
+			UnitPrice:    drug.UnitPrice,
+			Amount:       rule.Round(drug.UnitPrice.Mul(dispenseQuantity(part))),
+		})
+		left -= part
+	}
+
+	if left > 0 {
+		return nil, fmt.Errorf("%w of %s %s: %s more needed", errInsufficientStock, drug.GenericName, drug.Strength, formatQuantity(left))
+	}
+	return taken, nil
+}
+
+// dispenseQuantity converts a stock quantity for pricing. Stock is counted to
+// four decimals, the precision bill item quantities are stored with, which
+// also drops any float residue from splitting it across batches.
+func dispenseQuantity(q float64) decimal.Decimal {
+	return decimal.NewFromFloat(q).Round(4)
+}
+
+func formatQuantity(q float64) string {
+	return strconv.FormatFloat(q, 'f', -1, 64)
+}
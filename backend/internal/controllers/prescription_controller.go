@@ -1,16 +1,54 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"clinic-backend/internal/config"
 	"clinic-backend/internal/models"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// doseFrequencies maps the accepted frequency codes to doses per day. Zero
+// means the quantity cannot be derived and must be given.
+var doseFrequencies = map[string]float64{
+	"OD":   1,
+	"QD":   1,
+	"BID":  2,
+	"TID":  3,
+	"QID":  4,
+	"Q4H":  6,
+	"Q6H":  4,
+	"Q8H":  3,
+	"Q12H": 2,
+	"QHS":  1,
+	"QW":   1.0 / 7,
+	"PRN":  0,
+	"STAT": 0,
+}
+
+// countableUnits are dose units that are dispensed as-is, so the quantity
+// can be derived from dose, frequency and duration.
+var countableUnits = map[string]bool{
+	"tablet":  true,
+	"capsule": true,
+	"ml":      true,
+	"sachet":  true,
+	"puff":    true,
+	"drop":    true,
+	"unit":    true,
+	"patch":   true,
+}
+
 func CreatePrescription(c *gin.Context) {
 	var prescription models.Prescription
 	if err := c.ShouldBindJSON(&prescription); err != nil {
@@ -32,6 +70,41 @@ func CreatePrescription(c *gin.Context) {
 		return
 	}
 
+	// Verify encounter belongs to the patient if provided
+	if prescription.EncounterID != nil {
+		var encounter models.Encounter
+		if err := config.DB.First(&encounter, *prescription.EncounterID).Error; err != nil || encounter.PatientID != prescription.PatientID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Encounter not found for patient"})
+			return
+		}
+	}
+
+	if len(prescription.Items) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one item is required"})
+		return
+	}
+	if err := preparePrescriptionItems(prescription.Items); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Severe allergy or interaction alerts need an override reason
+	alerts, err := checkPrescriptionItems(prescription.PatientID, prescription.Items, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check prescription"})
+		return
+	}
+	if !applyAlertOverride(c, &prescription, alerts) {
+		return
+	}
+	prescription.Alerts = alerts
+
+	summarizePrescription(&prescription)
+	for i := range prescription.Items {
+		prescription.Items[i].Drug = models.Drug{}
+	}
+
+	prescription.Status = "Pending"
+
 	// Set date if not provided
 	if prescription.Date.IsZero() {
 		prescription.Date = time.Now()
@@ -43,14 +116,14 @@ func CreatePrescription(c *gin.Context) {
 	}
 
 	// Load relations
-	config.DB.Preload("Patient").Preload("Doctor").First(&prescription, prescription.ID)
+	config.DB.Preload("Patient").Preload("Doctor").Preload("Items.Drug").Preload("Alerts").First(&prescription, prescription.ID)
 
 	c.JSON(http.StatusCreated, prescription)
 }
 
 func GetPrescriptions(c *gin.Context) {
 	var prescriptions []models.Prescription
-	query := config.DB.Preload("Patient").Preload("Doctor").Order("date DESC")
+	query := config.DB.Preload("Patient").Preload("Doctor").Preload("Items.Drug").Preload("Alerts").Order("date DESC")
 
 	// Filter by patient if provided
 	if patientID := c.Query("patientId"); patientID != "" {
@@ -62,6 +135,11 @@ func GetPrescriptions(c *gin.Context) {
 		query = query.Where("doctor_id = ?", doctorID)
 	}
 
+	// Filter by encounter if provided
+	if encounterID := c.Query("encounterId"); encounterID != "" {
+		query = query.Where("encounter_id = ?", encounterID)
+	}
+
 	if err := query.Find(&prescriptions).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch prescriptions"})
 		return
@@ -78,11 +156,16 @@ func GetPrescriptionByID(c *gin.Context) {
 	}
 
 	var prescription models.Prescription
-	if err := config.DB.Preload("Patient").Preload("Doctor").First(&prescription, id).Error; err != nil {
+	if err := config.DB.Preload("Patient").Preload("Doctor").Preload("Items.Drug").Preload("Alerts").First(&prescription, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Prescription not found"})
 		return
 	}
 
+	if wantsPDF(c) {
+		renderPrescriptionPDF(c, prescription)
+		return
+	}
+
 	c.JSON(http.StatusOK, prescription)
 }
 
@@ -99,20 +182,144 @@ func UpdatePrescription(c *gin.Context) {
 		return
 	}
 
+	// A changed order needs a fresh override reason
+	previousReason, previousBy := prescription.OverrideReason, prescription.OverriddenBy
+	status := prescription.Status
+	prescription.OverrideReason = ""
+
 	if err := c.ShouldBindJSON(&prescription); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	prescription.ID = uint(id)
+	prescription.Status = status
+	prescription.Alerts = nil
+
+	// Items sent with the update replace the existing ones
+	var alerts []models.PrescriptionAlert
+	rechecked := false
+	items := prescription.Items
+	prescription.Items = nil
+	if items != nil {
+		if status != "Pending" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Items cannot be changed once dispensing has started"})
+			return
+		}
+		if len(items) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "At least one item is required"})
+			return
+		}
+		if err := preparePrescriptionItems(items); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		prescription.Items = items
+		summarizePrescription(&prescription)
+		prescription.Items = nil
 
-	if err := config.DB.Save(&prescription).Error; err != nil {
+		alerts, err = checkPrescriptionItems(prescription.PatientID, items, prescription.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check prescription"})
+			return
+		}
+		if !applyAlertOverride(c, &prescription, alerts) {
+			return
+		}
+	} else if status == "Pending" {
+		// The patient or their other medications may have changed, so the
+		// kept items are checked again. The previous override stands only
+		// while it still covers every severe alert.
+		var current []models.PrescriptionItem
+		if err := config.DB.Preload("Drug").Where("prescription_id = ?", prescription.ID).Find(&current).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check prescription"})
+			return
+		}
+		alerts, err = checkPrescriptionItems(prescription.PatientID, current, prescription.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check prescription"})
+			return
+		}
+		if strings.TrimSpace(prescription.OverrideReason) == "" && previousReason != "" {
+			var previous []models.PrescriptionAlert
+			config.DB.Where("prescription_id = ?", prescription.ID).Find(&previous)
+			if severeAlertsCovered(alerts, previous) {
+				prescription.OverrideReason = previousReason
+			}
+		}
+		if !applyAlertOverride(c, &prescription, alerts) {
+			return
+		}
+		if prescription.OverrideReason == previousReason {
+			prescription.OverriddenBy = previousBy
+		}
+		rechecked = true
+	} else {
+		prescription.OverrideReason, prescription.OverriddenBy = previousReason, previousBy
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		// A dispense may have started since the prescription was read
+		if items != nil {
+			var locked models.Prescription
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, prescription.ID).Error; err != nil {
+				return err
+			}
+			if locked.Status != "Pending" {
+				return errPrescriptionChanged
+			}
+		}
+		if err := tx.Omit("Items", "Alerts").Save(&prescription).Error; err != nil {
+			return err
+		}
+		if items == nil && !rechecked {
+			return nil
+		}
+		if err := tx.Where("prescription_id = ?", prescription.ID).Delete(&models.PrescriptionAlert{}).Error; err != nil {
+			return err
+		}
+		if items != nil {
+			if err := tx.Where("prescription_id = ?", prescription.ID).Delete(&models.PrescriptionItem{}).Error; err != nil {
+				return err
+			}
+			for i := range items {
+				items[i].PrescriptionID = prescription.ID
+				items[i].Drug = models.Drug{}
+			}
+			if err := tx.Create(&items).Error; err != nil {
+				return err
+			}
+		}
+		for i := range alerts {
+			alerts[i].PrescriptionID = prescription.ID
+		}
+		if len(alerts) == 0 {
+			return nil
+		}
+		return tx.Create(&alerts).Error
+	})
+	if errors.Is(err, errPrescriptionChanged) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Items cannot be changed once dispensing has started"})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update prescription"})
 		return
 	}
 
-	config.DB.Preload("Patient").Preload("Doctor").First(&prescription, prescription.ID)
+	config.DB.Preload("Patient").Preload("Doctor").Preload("Items.Drug").Preload("Alerts").First(&prescription, prescription.ID)
 	c.JSON(http.StatusOK, prescription)
 }
 
+// SeedPrescriptionStatuses takes free-text prescriptions from before coded
+// items out of the pharmacy queue. They have nothing to dispense.
+func SeedPrescriptionStatuses() {
+	if err := config.DB.Exec(`UPDATE prescriptions SET status = 'Legacy'
+		WHERE status = 'Pending'
+		AND NOT EXISTS (SELECT 1 FROM prescription_items i WHERE i.prescription_id = prescriptions.id)`).Error; err != nil {
+		log.Println("Failed to seed prescription statuses:", err)
+	}
+}
+
 func DeletePrescription(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
@@ -120,10 +327,121 @@ func DeletePrescription(c *gin.Context) {
 		return
 	}
 
-	if err := config.DB.Delete(&models.Prescription{}, id).Error; err != nil {
+	var dispensed int64
+	config.DB.Model(&models.Dispense{}).Where("prescription_id = ?", id).Count(&dispensed)
+	if dispensed > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Dispensed prescriptions cannot be deleted"})
+		return
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("prescription_id = ?", id).Delete(&models.PrescriptionItem{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("prescription_id = ?", id).Delete(&models.PrescriptionAlert{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Prescription{}, id).Error
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete prescription"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Prescription deleted successfully"})
 }
+
+// preparePrescriptionItems validates each item against the formulary,
+// filling the route from the drug and deriving the quantity where possible.
+func preparePrescriptionItems(items []models.PrescriptionItem) error {
+	for i := range items {
+		item := &items[i]
+		item.ID = 0
+		item.DispensedQuantity = 0
+		line := i + 1
+
+		var drug models.Drug
+		if err := config.DB.First(&drug, item.DrugID).Error; err != nil {
+			return fmt.Errorf("Item %d: drug not found in formulary", line)
+		}
+		if drug.Discontinued {
+			return fmt.Errorf("Item %d: %s is discontinued", line, drug.GenericName)
+		}
+		item.Drug = drug
+
+		if item.Dose <= 0 {
+			return fmt.Errorf("Item %d: dose must be positive", line)
+		}
+		item.DoseUnit = strings.ToLower(strings.TrimSpace(item.DoseUnit))
+		if item.DoseUnit == "" {
+			return fmt.Errorf("Item %d: dose unit is required", line)
+		}
+
+		item.Frequency = strings.ToUpper(strings.TrimSpace(item.Frequency))
+		perDay, ok := doseFrequencies[item.Frequency]
+		if !ok {
+			return fmt.Errorf("Item %d: unknown frequency %q", line, item.Frequency)
+		}
+
+		if item.Route == "" {
+			item.Route = drug.Route
+		}
+		if drug.Route != "" && !strings.EqualFold(item.Route, drug.Route) {
+			return fmt.Errorf("Item %d: %s %s is given %s, not %s", line, drug.GenericName, drug.Strength, drug.Route, item.Route)
+		}
+
+		if item.DurationDays < 0 || (item.DurationDays == 0 && item.Frequency != "STAT" && item.Frequency != "PRN") {
+			return fmt.Errorf("Item %d: duration in days is required", line)
+		}
+
+		if item.Quantity == 0 && perDay > 0 && countableUnits[item.DoseUnit] {
+			item.Quantity = math.Ceil(item.Dose * perDay * float64(item.DurationDays))
+		}
+		if item.Quantity <= 0 {
+			return fmt.Errorf("Item %d: quantity is required", line)
+		}
+	}
+	return nil
+}
+
+// applyAlertOverride rejects the prescription with the alerts when a severe
+// one has no override reason, and records who overrode it otherwise.
+func applyAlertOverride(c *gin.Context, prescription *models.Prescription, alerts []models.PrescriptionAlert) bool {
+	prescription.OverrideReason = strings.TrimSpace(prescription.OverrideReason)
+	if !hasSevereAlert(alerts) {
+		prescription.OverrideReason = ""
+		prescription.OverriddenBy = nil
+		return true
+	}
+	if prescription.OverrideReason == "" {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":  "Severe alerts require an override reason",
+			"alerts": alerts,
+		})
+		return false
+	}
+	userID := currentUserID(c)
+	prescription.OverriddenBy = &userID
+	return true
+}
+
+// summarizePrescription fills the free-text medicine and dosage fields from
+// the items so existing consumers keep working.
+func summarizePrescription(prescription *models.Prescription) {
+	if len(prescription.Items) == 0 {
+		return
+	}
+
+	var medicines, dosages []string
+	for _, item := range prescription.Items {
+		medicines = append(medicines, strings.Join(strings.Fields(item.Drug.GenericName+" "+item.Drug.Strength+" "+item.Drug.Form), " "))
+
+		dosage := fmt.Sprintf("%s %s %s", strconv.FormatFloat(item.Dose, 'f', -1, 64), item.DoseUnit, item.Frequency)
+		if item.DurationDays > 0 {
+			dosage += fmt.Sprintf(" for %d days", item.DurationDays)
+		}
+		dosages = append(dosages, dosage)
+	}
+	prescription.MedicineName = strings.Join(medicines, "; ")
+	prescription.Dosage = strings.Join(dosages, "; ")
+}
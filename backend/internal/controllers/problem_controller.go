@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"clinic-backend/internal/config"
+	"clinic-backend/internal/icd10"
+	"clinic-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+var validProblemStatuses = map[string]bool{
+	"Active":   true,
+	"Inactive": true,
+	"Resolved": true,
+}
+
+// GetPatientProblems lists the patient's problem list, active problems only
+// unless a status is given (?status=Resolved or ?status=all).
+func GetPatientProblems(c *gin.Context) {
+	patient, ok := findPatient(c)
+	if !ok {
+		return
+	}
+
+	var problems []models.Problem
+	query := config.DB.Where("patient_id = ?", patient.ID).Order("created_at DESC")
+	if status := c.DefaultQuery("status", "Active"); status != "all" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Find(&problems).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch problems"})
+		return
+	}
+
+	c.JSON(http.StatusOK, problems)
+}
+
+func CreatePatientProblem(c *gin.Context) {
+	patient, ok := findPatient(c)
+	if !ok {
+		return
+	}
+
+	var problem models.Problem
+	if err := c.ShouldBindJSON(&problem); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	problem.ID = 0
+	problem.PatientID = patient.ID
+	problem.RecordedBy = currentUserID(c)
+
+	if err := prepareProblem(&problem); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := config.DB.Create(&problem).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add problem"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, problem)
+}
+
+func UpdatePatientProblem(c *gin.Context) {
+	problem, ok := findPatientProblem(c)
+	if !ok {
+		return
+	}
+
+	problemID, patientID, recordedBy := problem.ID, problem.PatientID, problem.RecordedBy
+	previousCode, previousDescription := problem.Code, problem.Description
+	if err := c.ShouldBindJSON(&problem); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	problem.ID = problemID
+	problem.PatientID = patientID
+	problem.RecordedBy = recordedBy
+
+	// A new code without a description of its own takes the catalog's
+	if problem.Code != "" && icd10.Normalize(problem.Code) != previousCode && problem.Description == previousDescription {
+		problem.Description = ""
+	}
+
+	if err := prepareProblem(&problem); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := config.DB.Save(&problem).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update problem"})
+		return
+	}
+
+	c.JSON(http.StatusOK, problem)
+}
+
+func DeletePatientProblem(c *gin.Context) {
+	problem, ok := findPatientProblem(c)
+	if !ok {
+		return
+	}
+
+	if err := config.DB.Delete(&problem).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete problem"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Problem deleted successfully"})
+}
+
+// prepareProblem validates the problem, filling the description from its
+// ICD-10 code and stamping when it was resolved.
+func prepareProblem(problem *models.Problem) error {
+	if problem.Code != "" {
+		problem.Code = icd10.Normalize(problem.Code)
+		var code models.ICD10Code
+		if err := config.DB.Where("code = ?", problem.Code).First(&code).Error; err != nil {
+			return errors.New("Unknown ICD-10 code " + problem.Code)
+		}
+		if problem.Description == "" {
+			problem.Description = code.Description
+		}
+	}
+
+	problem.Description = strings.TrimSpace(problem.Description)
+	if problem.Description == "" {
+		return errors.New("Description or ICD-10 code is required")
+	}
+
+	if problem.Status == "" {
+		problem.Status = "Active"
+	}
+	if !validProblemStatuses[problem.Status] {
+		return errors.New("Invalid problem status. Must be: Active, Inactive, or Resolved")
+	}
+
+	switch {
+	case problem.Status == "Resolved" && problem.ResolvedAt == nil:
+		now := time.Now()
+		problem.ResolvedAt = &now
+	case problem.Status != "Resolved":
+		problem.ResolvedAt = nil
+	}
+	return nil
+}
+
+func findPatientProblem(c *gin.Context) (models.Problem, bool) {
+	var problem models.Problem
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid patient ID"})
+		return problem, false
+	}
+	problemID, err := strconv.ParseUint(c.Param("problemId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid problem ID"})
+		return problem, false
+	}
+
+	if err := config.DB.Where("patient_id = ?", id).First(&problem, problemID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Problem not found"})
+		return problem, false
+	}
+
+	return problem, true
+}
@@ -0,0 +1,453 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"clinic-backend/internal/config"
+	"clinic-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// defaultConsultationTime is assumed until a queue has served history
+	defaultConsultationTime = 10 * time.Minute
+	// consultationHistorySize is how many recent consultations feed the estimate
+	consultationHistorySize = 50
+	// queueStreamInterval is how often the lobby stream checks for changes
+	queueStreamInterval = 5 * time.Second
+)
+
+type queueEntry struct {
+	models.QueueToken
+	EstimatedWaitMinutes int `json:"estimatedWaitMinutes"`
+}
+
+type queueSnapshot struct {
+	DoctorID                   *uint        `json:"doctorId,omitempty"`
+	Department                 string       `json:"department,omitempty"`
+	NowServing                 []queueEntry `json:"nowServing"`
+	Waiting                    []queueEntry `json:"waiting"`
+	AverageConsultationMinutes float64      `json:"averageConsultationMinutes"`
+	UpdatedAt                  time.Time    `json:"updatedAt"`
+}
+
+func IssueQueueToken(c *gin.Context) {
+	var body struct {
+		DoctorID    *uint  `json:"doctorId"`
+		Department  string `json:"department"`
+		PatientID   *uint  `json:"patientId"`
+		PatientName string `json:"patientName"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token := models.QueueToken{
+		DoctorID:    body.DoctorID,
+		Department:  body.Department,
+		PatientID:   body.PatientID,
+		PatientName: body.PatientName,
+		Status:      "Waiting",
+		IssuedAt:    time.Now(),
+	}
+
+	// Verify doctor exists if the token is for a specific doctor
+	if body.DoctorID != nil {
+		var doctor models.Doctor
+		if err := config.DB.First(&doctor, *body.DoctorID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Doctor not found"})
+			return
+		}
+		token.Department = doctor.Specialization
+	} else if body.Department == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Either doctorId or department is required"})
+		return
+	}
+
+	// Verify patient exists if a registered patient was given
+	if body.PatientID != nil {
+		var patient models.Patient
+		if err := config.DB.First(&patient, *body.PatientID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Patient not found"})
+			return
+		}
+		if token.PatientName == "" {
+			token.PatientName = patient.Name
+		}
+	}
+
+	today := startOfToday()
+	token.QueueDate = today
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		number, err := nextQueueNumber(tx, token.DoctorID, token.Department, today)
+		if err != nil {
+			return err
+		}
+		token.Number = number
+		return tx.Create(&token).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue queue token"})
+		return
+	}
+
+	snapshot, err := buildQueueSnapshot(token.DoctorID, token.Department)
+	if err != nil {
+		c.JSON(http.StatusCreated, token)
+		return
+	}
+	for _, entry := range snapshot.Waiting {
+		if entry.ID == token.ID {
+			c.JSON(http.StatusCreated, entry)
+			return
+		}
+	}
+	c.JSON(http.StatusCreated, token)
+}
+
+// GetQueue returns the live queue with patient details for staff.
+func GetQueue(c *gin.Context) {
+	doctorID, department, ok := parseQueueScope(c)
+	if !ok {
+		return
+	}
+
+	snapshot, err := buildQueueSnapshot(doctorID, department)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch queue"})
+		return
+	}
+
+	c.JSON(http.StatusOK, snapshot)
+}
+
+// GetQueueDisplay returns the live queue for lobby screens, without any
+// patient details.
+func GetQueueDisplay(c *gin.Context) {
+	doctorID, department, ok := parseQueueScope(c)
+	if !ok {
+		return
+	}
+
+	snapshot, err := buildQueueSnapshot(doctorID, department)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch queue"})
+		return
+	}
+
+	c.JSON(http.StatusOK, anonymizeSnapshot(snapshot))
+}
+
+// StreamQueueDisplay pushes the lobby view as server-sent events whenever
+// the queue changes.
+func StreamQueueDisplay(c *gin.Context) {
+	doctorID, department, ok := parseQueueScope(c)
+	if !ok {
+		return
+	}
+
+	ticker := time.NewTicker(queueStreamInterval)
+	defer ticker.Stop()
+
+	var last []byte
+	c.Stream(func(w io.Writer) bool {
+		if snapshot, err := buildQueueSnapshot(doctorID, department); err == nil {
+			snapshot = anonymizeSnapshot(snapshot)
+			// UpdatedAt always changes, so compare the queue contents only
+			snapshot.UpdatedAt = time.Time{}
+			current, _ := json.Marshal(snapshot)
+			if string(current) != string(last) {
+				snapshot.UpdatedAt = time.Now()
+				c.SSEvent("queue", snapshot)
+				last = current
+			}
+		}
+
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-ticker.C:
+			return true
+		}
+	})
+}
+
+// CallNextQueueToken calls the next waiting token. A doctor's own queue is
+// served first, then walk-ins waiting on the doctor's department.
+func CallNextQueueToken(c *gin.Context) {
+	var body struct {
+		DoctorID   *uint  `json:"doctorId"`
+		Department string `json:"department"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if body.DoctorID == nil && body.Department == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Either doctorId or department is required"})
+		return
+	}
+
+	today := startOfToday()
+	var token models.QueueToken
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		next := func(doctorID *uint, department string) error {
+			// Tokens another desk is calling are skipped, not called twice
+			return queueScope(tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}), doctorID, department).
+				Where("queue_date = ? AND status = ?", today, "Waiting").
+				Order("number ASC").
+				First(&token).Error
+		}
+
+		err := next(body.DoctorID, body.Department)
+		if errors.Is(err, gorm.ErrRecordNotFound) && body.DoctorID != nil {
+			var doctor models.Doctor
+			if err := tx.First(&doctor, *body.DoctorID).Error; err != nil {
+				return err
+			}
+			if err = next(nil, doctor.Specialization); err != nil {
+				return err
+			}
+			token.DoctorID = body.DoctorID
+		} else if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		token.Status = "Called"
+		token.CalledAt = &now
+		return tx.Save(&token).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No patients waiting"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to call next token"})
+		return
+	}
+
+	config.DB.Preload("Patient").Preload("Doctor").First(&token, token.ID)
+	c.JSON(http.StatusOK, token)
+}
+
+func CallQueueToken(c *gin.Context) {
+	updateQueueTokenStatus(c, "Called")
+}
+
+func ServeQueueToken(c *gin.Context) {
+	updateQueueTokenStatus(c, "Served")
+}
+
+func SkipQueueToken(c *gin.Context) {
+	updateQueueTokenStatus(c, "Skipped")
+}
+
+func updateQueueTokenStatus(c *gin.Context, status string) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token ID"})
+		return
+	}
+
+	var token models.QueueToken
+	if err := config.DB.First(&token, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Queue token not found"})
+		return
+	}
+
+	if token.Status == "Served" || token.Status == "Skipped" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Queue token is already closed"})
+		return
+	}
+
+	now := time.Now()
+	switch status {
+	case "Called":
+		token.CalledAt = &now
+	case "Served":
+		if token.CalledAt == nil {
+			token.CalledAt = &now
+		}
+		token.ServedAt = &now
+	}
+	token.Status = status
+
+	if err := config.DB.Save(&token).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update queue token"})
+		return
+	}
+
+	config.DB.Preload("Patient").Preload("Doctor").First(&token, token.ID)
+	c.JSON(http.StatusOK, token)
+}
+
+func buildQueueSnapshot(doctorID *uint, department string) (queueSnapshot, error) {
+	snapshot := queueSnapshot{
+		DoctorID:   doctorID,
+		Department: department,
+		NowServing: []queueEntry{},
+		Waiting:    []queueEntry{},
+		UpdatedAt:  time.Now(),
+	}
+
+	var tokens []models.QueueToken
+	if err := queueScope(config.DB.Preload("Patient").Preload("Doctor"), doctorID, department).
+		Where("queue_date = ? AND status IN ?", startOfToday(), []string{"Waiting", "Called"}).
+		Order("number ASC").
+		Find(&tokens).Error; err != nil {
+		return snapshot, err
+	}
+
+	average := averageConsultationTime(doctorID, department)
+	snapshot.AverageConsultationMinutes = average.Minutes()
+
+	// Time left on consultations already under way
+	var inProgress time.Duration
+	for _, token := range tokens {
+		if token.Status != "Called" {
+			continue
+		}
+		snapshot.NowServing = append(snapshot.NowServing, queueEntry{QueueToken: token})
+		if token.CalledAt != nil {
+			if remaining := average - time.Since(*token.CalledAt); remaining > 0 {
+				inProgress += remaining
+			}
+		}
+	}
+
+	ahead := 0
+	for _, token := range tokens {
+		if token.Status != "Waiting" {
+			continue
+		}
+		wait := inProgress + time.Duration(ahead)*average
+		snapshot.Waiting = append(snapshot.Waiting, queueEntry{
+			QueueToken:           token,
+			EstimatedWaitMinutes: int(wait.Round(time.Minute).Minutes()),
+		})
+		ahead++
+	}
+
+	return snapshot, nil
+}
+
+// averageConsultationTime averages how long recent tokens in the queue took
+// from being called to being served.
+func averageConsultationTime(doctorID *uint, department string) time.Duration {
+	var served []models.QueueToken
+	queueScope(config.DB, doctorID, department).
+		Where("status = ? AND called_at IS NOT NULL AND served_at IS NOT NULL", "Served").
+		Order("served_at DESC").
+		Limit(consultationHistorySize).
+		Find(&served)
+
+	var total time.Duration
+	count := 0
+	for _, token := range served {
+		if d := token.ServedAt.Sub(*token.CalledAt); d > 0 {
+			total += d
+			count++
+		}
+	}
+	if count == 0 {
+		return defaultConsultationTime
+	}
+	return total / time.Duration(count)
+}
+
+// nextQueueNumber takes the next token number of the queue for the day. The
+// counter row stays locked until the transaction ends, so concurrent issues
+// are numbered one at a time. A counter created on a day tokens were already
+// issued starts after the highest of them.
+func nextQueueNumber(tx *gorm.DB, doctorID *uint, department string, day time.Time) (int, error) {
+	scope := "department:" + department
+	if doctorID != nil {
+		scope = fmt.Sprintf("doctor:%d", *doctorID)
+	}
+
+	var last int
+	if err := queueScope(tx.Model(&models.QueueToken{}), doctorID, department).
+		Where("queue_date = ?", day).
+		Select("COALESCE(MAX(number), 0)").
+		Scan(&last).Error; err != nil {
+		return 0, err
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.QueueCounter{Scope: scope, QueueDate: day, LastNumber: last}).Error; err != nil {
+		return 0, err
+	}
+
+	var counter models.QueueCounter
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("scope = ? AND queue_date = ?", scope, day).
+		First(&counter).Error; err != nil {
+		return 0, err
+	}
+	counter.LastNumber++
+	return counter.LastNumber, tx.Model(&counter).Update("last_number", counter.LastNumber).Error
+}
+
+// queueScope limits a query to one doctor's queue, or to the walk-ins
+// waiting on a department without a specific doctor.
+func queueScope(db *gorm.DB, doctorID *uint, department string) *gorm.DB {
+	if doctorID != nil {
+		return db.Where("doctor_id = ?", *doctorID)
+	}
+	return db.Where("doctor_id IS NULL AND department = ?", department)
+}
+
+func parseQueueScope(c *gin.Context) (*uint, string, bool) {
+	if raw := c.Query("doctorId"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid doctor ID"})
+			return nil, "", false
+		}
+		doctorID := uint(id)
+		return &doctorID, "", true
+	}
+
+	department := c.Query("department")
+	if department == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Either doctorId or department is required"})
+		return nil, "", false
+	}
+	return nil, department, true
+}
+
+func anonymizeSnapshot(snapshot queueSnapshot) queueSnapshot {
+	strip := func(entries []queueEntry) []queueEntry {
+		out := make([]queueEntry, len(entries))
+		for i, entry := range entries {
+			entry.PatientID = nil
+			entry.Patient = nil
+			entry.PatientName = ""
+			if entry.Doctor != nil {
+				entry.Doctor = &models.Doctor{ID: entry.Doctor.ID, Name: entry.Doctor.Name, Specialization: entry.Doctor.Specialization}
+			}
+			out[i] = entry
+		}
+		return out
+	}
+	snapshot.NowServing = strip(snapshot.NowServing)
+	snapshot.Waiting = strip(snapshot.Waiting)
+	return snapshot
+}
+
+func startOfToday() time.Time {
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+}
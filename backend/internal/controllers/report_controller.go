@@ -0,0 +1,302 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"clinic-backend/internal/config"
+	"clinic-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"github.com/xuri/excelize/v2"
+)
+
+// revenueGroups are the ways revenue can be broken down, as SQL over bill
+// items joined to their bill and the doctor they are attributed to.
+var revenueGroups = map[string]string{
+	"day":    "to_char(bills.created_at, 'YYYY-MM-DD')",
+	"month":  "to_char(bills.created_at, 'YYYY-MM')",
+	"doctor": "COALESCE(doctors.name, 'Unassigned')",
+	"department": "COALESCE(NULLIF(doctors.specialization, ''), CASE bill_items.source_type " +
+		"WHEN 'Dispense' THEN 'Pharmacy' WHEN 'RoomStay' THEN 'Inpatient rooms' ELSE 'Unassigned' END)",
+	"service": "bill_items.service",
+}
+
+// agingBuckets are the receivables age ranges in days since the invoice date.
+var agingBuckets = []struct {
+	Label string
+	Max   int // inclusive, -1 for no limit
+}{
+	{"0-30", 30},
+	{"31-60", 60},
+	{"61-90", 90},
+	{"90+", -1},
+}
+
+// GetRevenueReport totals what was billed over a date range, by day (the
+// default), month, doctor, department or service. Items count towards the
+// doctor of the appointment or procedure they charge for, else the doctor of
+// the bill's encounter; void bills are left out. Add ?format=csv or xlsx to
+// download it.
+func GetRevenueReport(c *gin.Context) {
+	from, to, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+
+	groupBy := c.DefaultQuery("groupBy", "day")
+	key, found := revenueGroups[groupBy]
+	if !found {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "groupBy must be day, month, doctor, department or service"})
+		return
+	}
+
+	var rows []struct {
+		Key      string          `json:"key"`
+		Currency string          `json:"currency"`
+		Bills    int64           `json:"bills"`
+		Quantity decimal.Decimal `json:"quantity"`
+		Net      decimal.Decimal `json:"net"` // after discounts, before tax
+		Tax      decimal.Decimal `json:"tax"`
+		Total    decimal.Decimal `json:"total"`
+	}
+	if err := config.DB.Table("bill_items").
+		Select(key+" AS key, bills.currency, COUNT(DISTINCT bills.id) AS bills, SUM(bill_items.quantity) AS quantity, "+
+			"SUM(bill_items.subtotal - bill_items.discount) AS net, SUM(bill_items.tax_amount) AS tax, SUM(bill_items.total) AS total").
+		Joins("JOIN bills ON bills.id = bill_items.bill_id").
+		Joins("LEFT JOIN appointments ON bill_items.source_type = 'Appointment' AND appointments.id = bill_items.source_id").
+		Joins("LEFT JOIN medical_records ON bill_items.source_type = 'Procedure' AND medical_records.id = bill_items.source_id").
+		Joins("LEFT JOIN encounters ON encounters.id = bills.encounter_id").
+		Joins("LEFT JOIN doctors ON doctors.id = COALESCE(appointments.doctor_id, medical_records.doctor_id, encounters.doctor_id)").
+		Where("bills.status <> ?", "Void").
+		Where("bills.created_at >= ? AND bills.created_at < ?", from, to).
+		Group("1, bills.currency").
+		Order("1 ASC, bills.currency ASC").
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build revenue report"})
+		return
+	}
+
+	if format := c.Query("format"); format != "" {
+		table := [][]interface{}{{groupBy, "currency", "bills", "quantity", "net", "tax", "total"}}
+		for _, row := range rows {
+			table = append(table, []interface{}{row.Key, row.Currency, row.Bills, row.Quantity, row.Net, row.Tax, row.Total})
+		}
+		sendReport(c, format, fmt.Sprintf("revenue-by-%s-%s", groupBy, from.Format("20060102")), table)
+		return
+	}
+
+	// Totals come from the same items, so they always add up to the rows
+	totals := []currencyTotal{}
+	index := map[string]int{}
+	for _, row := range rows {
+		i, seen := index[row.Currency]
+		if !seen {
+			i = len(totals)
+			index[row.Currency] = i
+			totals = append(totals, currencyTotal{Currency: row.Currency})
+		}
+		totals[i].Amount = totals[i].Amount.Add(row.Total)
+	}
+	sort.Slice(totals, func(i, j int) bool { return totals[i].Currency < totals[j].Currency })
+
+	c.JSON(http.StatusOK, gin.H{"from": from, "to": to, "groupBy": groupBy, "rows": rows, "totals": totals})
+}
+
+// GetReceivablesAging splits what is owed on outstanding bills into age
+// buckets by days since the invoice date, separately for what patients owe
+// and what each insurer owes.
+func GetReceivablesAging(c *gin.Context) {
+	var bills []models.Bill
+	if err := config.DB.
+		Where("status IN ?", []string{"Unpaid", "PartiallyPaid", "Overdue"}).
+		Where("patient_balance > 0 OR payer_balance > 0").
+		Find(&bills).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build aging report"})
+		return
+	}
+
+	// Insurers owing on the bills, through their claims
+	insurerOf := map[uint]string{}
+	var owing []struct {
+		BillID uint
+		Name   string
+	}
+	config.DB.Table("claims").
+		Select("claims.bill_id, insurers.name").
+		Joins("JOIN insurers ON insurers.id = claims.insurer_id").
+		Where("claims.bill_id IN (?)", config.DB.Model(&models.Bill{}).Select("id").
+			Where("status IN ? AND payer_balance > 0", []string{"Unpaid", "PartiallyPaid", "Overdue"})).
+		Scan(&owing)
+	for _, o := range owing {
+		insurerOf[o.BillID] = o.Name
+	}
+
+	type agingRow struct {
+		Owed     string            `json:"owed"` // "Patients" or the insurer's name
+		Currency string            `json:"currency"`
+		Bills    int               `json:"bills"`
+		Buckets  []decimal.Decimal `json:"buckets"`
+		Total    decimal.Decimal   `json:"total"`
+	}
+	var rows []*agingRow
+	index := map[string]*agingRow{}
+	add := func(owed, currency string, bucket int, amount decimal.Decimal) {
+		row, ok := index[owed+"|"+currency]
+		if !ok {
+			row = &agingRow{Owed: owed, Currency: currency, Buckets: make([]decimal.Decimal, len(agingBuckets))}
+			index[owed+"|"+currency] = row
+			rows = append(rows, row)
+		}
+		row.Bills++
+		row.Buckets[bucket] = row.Buckets[bucket].Add(amount)
+		row.Total = row.Total.Add(amount)
+	}
+
+	today := startOfToday()
+	for _, bill := range bills {
+		age := daysBetween(bill.CreatedAt, today)
+		bucket := len(agingBuckets) - 1
+		for i, b := range agingBuckets {
+			if b.Max >= 0 && age <= b.Max {
+				bucket = i
+				break
+			}
+		}
+
+		if bill.PatientBalance.IsPositive() {
+			add("Patients", bill.Currency, bucket, bill.PatientBalance)
+		}
+		if bill.PayerBalance.IsPositive() {
+			insurer := insurerOf[bill.ID]
+			if insurer == "" {
+				insurer = "Unclaimed insurance"
+			}
+			add(insurer, bill.Currency, bucket, bill.PayerBalance)
+		}
+	}
+
+	labels := make([]string, len(agingBuckets))
+	for i, b := range agingBuckets {
+		labels[i] = b.Label
+	}
+
+	if format := c.Query("format"); format != "" {
+		header := []interface{}{"owed", "currency", "bills"}
+		for _, label := range labels {
+			header = append(header, label)
+		}
+		table := [][]interface{}{append(header, "total")}
+		for _, row := range rows {
+			record := []interface{}{row.Owed, row.Currency, row.Bills}
+			for _, amount := range row.Buckets {
+				record = append(record, amount)
+			}
+			table = append(table, append(record, row.Total))
+		}
+		sendReport(c, format, "receivables-aging-"+today.Format("20060102"), table)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"asOf": today, "buckets": labels, "rows": rows})
+}
+
+// GetCollectionsReport totals money received over a date range by payment
+// method, net of refunds and reversals. Insurance adjustments are not money
+// received and are left out.
+func GetCollectionsReport(c *gin.Context) {
+	from, to, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+
+	var rows []struct {
+		Method   string          `json:"method"`
+		Currency string          `json:"currency"`
+		Payments int64           `json:"payments"`
+		Received decimal.Decimal `json:"received"`
+		Returned decimal.Decimal `json:"returned"` // refunds and reversals
+		Net      decimal.Decimal `json:"net"`
+	}
+	if err := config.DB.Model(&models.Payment{}).
+		Select("method, currency, "+
+			"COUNT(*) FILTER (WHERE kind = 'Payment') AS payments, "+
+			"COALESCE(SUM(amount) FILTER (WHERE kind = 'Payment'), 0) AS received, "+
+			"COALESCE(-SUM(amount) FILTER (WHERE kind IN ('Refund', 'Reversal')), 0) AS returned, "+
+			"SUM(amount) AS net").
+		Where("kind <> ?", "Adjustment").
+		Where("received_at >= ? AND received_at < ?", from, to).
+		Group("method, currency").
+		Order("method ASC, currency ASC").
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build collections report"})
+		return
+	}
+
+	if format := c.Query("format"); format != "" {
+		table := [][]interface{}{{"method", "currency", "payments", "received", "returned", "net"}}
+		for _, row := range rows {
+			table = append(table, []interface{}{row.Method, row.Currency, row.Payments, row.Received, row.Returned, row.Net})
+		}
+		sendReport(c, format, "collections-"+from.Format("20060102"), table)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"from": from, "to": to, "rows": rows})
+}
+
+// sendReport downloads a report table, its first row being the header, as
+// CSV or as an XLSX workbook with amounts stored as numbers. Amounts are
+// written with their exact decimal digits rather than through a float.
+func sendReport(c *gin.Context, format, filename string, table [][]interface{}) {
+	var buf bytes.Buffer
+	var contentType string
+
+	switch format {
+	case "csv":
+		w := csv.NewWriter(&buf)
+		for _, row := range table {
+			record := make([]string, len(row))
+			for i, value := range row {
+				record[i] = fmt.Sprint(value)
+			}
+			w.Write(record)
+		}
+		w.Flush()
+		contentType = "text/csv; charset=utf-8"
+	case "xlsx":
+		f := excelize.NewFile()
+		defer f.Close()
+		sheet := f.GetSheetName(0)
+		for r, row := range table {
+			for i, value := range row {
+				cell, _ := excelize.CoordinatesToCellName(i+1, r+1)
+				var err error
+				if amount, ok := value.(decimal.Decimal); ok {
+					err = f.SetCellDefault(sheet, cell, amount.String())
+				} else {
+					err = f.SetCellValue(sheet, cell, value)
+				}
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export report"})
+					return
+				}
+			}
+		}
+		if err := f.Write(&buf); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export report"})
+			return
+		}
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or xlsx"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, filename, format))
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
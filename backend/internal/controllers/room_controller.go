@@ -8,6 +8,8 @@ import (
 	"clinic-backend/internal/models"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 func CreateRoom(c *gin.Context) {
@@ -88,11 +90,16 @@ func UpdateRoom(c *gin.Context) {
 		return
 	}
 
+	occupant := room.PatientID
+
 	if err := c.ShouldBindJSON(&room); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	// Occupants change through assignment so their stays are charged
+	room.PatientID = occupant
+
 	if err := config.DB.Save(&room).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update room"})
 		return
@@ -146,25 +153,71 @@ func AssignRoomToPatient(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Patient not found"})
 			return
 		}
+	}
 
-		// Check if room is available
-		if room.Status == "Occupied" && room.PatientID != nil && *room.PatientID != *body.PatientID {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Room is already occupied"})
-			return
+	// Occupancy is tracked as stays, which room charges are billed from. The
+	// room and patient stay locked so admissions and transfers cannot move
+	// anyone in or out while the assignment is made.
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&room, room.ID).Error; err != nil {
+			return err
+		}
+		previous := room.PatientID
+
+		// Admitted patients move through transfers and discharge instead
+		var held int64
+		if err := tx.Model(&models.RoomStay{}).
+			Where("room_id = ? AND ended_at IS NULL AND admission_id IS NOT NULL", room.ID).
+			Count(&held).Error; err != nil {
+			return err
+		}
+		if held > 0 {
+			return admissionError("Room is held by an admission. Transfer or discharge the patient instead")
 		}
 
-		room.PatientID = body.PatientID
-		room.Status = "Occupied"
-	} else {
-		// Unassigning room
-		room.PatientID = nil
-		if room.Status == "Occupied" {
-			room.Status = "Available"
+		if body.PatientID != nil {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.Patient{}, *body.PatientID).Error; err != nil {
+				return err
+			}
+			var admitted int64
+			if err := tx.Model(&models.Admission{}).
+				Where("patient_id = ? AND status = ?", *body.PatientID, "Admitted").
+				Count(&admitted).Error; err != nil {
+				return err
+			}
+			if admitted > 0 {
+				return admissionError("Patient is admitted. Transfer them instead")
+			}
+
+			// Check if room is available
+			if room.Status == "Occupied" && room.PatientID != nil && *room.PatientID != *body.PatientID {
+				return admissionError("Room is already occupied")
+			}
+
+			room.PatientID = body.PatientID
+			room.Status = "Occupied"
+		} else {
+			// Unassigning room
+			room.PatientID = nil
+			if room.Status == "Occupied" {
+				room.Status = "Available"
+			}
 		}
-	}
 
-	if err := config.DB.Save(&room).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign room"})
+		if err := tx.Save(&room).Error; err != nil {
+			return err
+		}
+		if previous != nil && (room.PatientID == nil || *room.PatientID != *previous) {
+			if err := endRoomStay(tx, room.ID); err != nil {
+				return err
+			}
+		}
+		if room.PatientID != nil && (previous == nil || *previous != *room.PatientID) {
+			return startRoomStay(tx, room.ID, *room.PatientID, nil, "")
+		}
+		return nil
+	})
+	if !respondAdmissionError(c, err, "Failed to assign room") {
 		return
 	}
 
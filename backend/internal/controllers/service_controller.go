@@ -0,0 +1,376 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"clinic-backend/internal/config"
+	"clinic-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+var serviceCategories = map[string]bool{
+	"Consultation": true,
+	"Procedure":    true,
+	"LabTest":      true,
+	"RoomRate":     true,
+	"Other":        true,
+}
+
+func GetServices(c *gin.Context) {
+	var services []models.Service
+	query := config.DB.Preload("Prices", func(db *gorm.DB) *gorm.DB {
+		return db.Order("effective_from DESC")
+	}).Order("category ASC, name ASC")
+
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		pattern := "%" + escapeLike(q) + "%"
+		query = query.Where("name ILIKE ? OR code = ?", pattern, q)
+	}
+	if category := c.Query("category"); category != "" {
+		query = query.Where("category = ?", category)
+	}
+	if specialization := c.Query("specialization"); specialization != "" {
+		query = query.Where("specialization = ? OR specialization = ''", specialization)
+	}
+	if roomType := c.Query("roomType"); roomType != "" {
+		query = query.Where("room_type = ?", roomType)
+	}
+	if c.Query("all") != "true" {
+		query = query.Where("discontinued = ?", false)
+	}
+
+	if err := query.Find(&services).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch services"})
+		return
+	}
+
+	c.JSON(http.StatusOK, services)
+}
+
+func GetServiceByID(c *gin.Context) {
+	service, ok := findService(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, service)
+}
+
+// GetServicePrice returns the price of a service in force on a date, today
+// by default, in the given or the default currency.
+func GetServicePrice(c *gin.Context) {
+	service, ok := findService(c)
+	if !ok {
+		return
+	}
+
+	day := startOfToday()
+	if raw := c.Query("date"); raw != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", raw, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date. Use YYYY-MM-DD"})
+			return
+		}
+		day = parsed
+	}
+
+	currency, err := billCurrency(c.Query("currency"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	price, err := servicePrice(service.ID, currency.Code, day)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, price)
+}
+
+func CreateService(c *gin.Context) {
+	var service models.Service
+	if err := c.ShouldBindJSON(&service); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	service.ID = 0
+	service.Prices = nil
+
+	if err := validateService(&service); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := config.DB.Create(&service).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create service"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, service)
+}
+
+// UpdateService changes a catalog entry. Prices are managed separately so
+// their history is kept.
+func UpdateService(c *gin.Context) {
+	service, ok := findService(c)
+	if !ok {
+		return
+	}
+	id := service.ID
+
+	if err := c.ShouldBindJSON(&service); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	service.ID = id
+
+	if err := validateService(&service); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := config.DB.Omit("Prices").Save(&service).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update service"})
+		return
+	}
+
+	config.DB.Preload("Prices", func(db *gorm.DB) *gorm.DB {
+		return db.Order("effective_from DESC")
+	}).First(&service, service.ID)
+	c.JSON(http.StatusOK, service)
+}
+
+// DeleteService removes a catalog entry, or discontinues it when it has
+// already been billed.
+func DeleteService(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid service ID"})
+		return
+	}
+
+	var used int64
+	config.DB.Model(&models.BillItem{}).Where("service_id = ?", id).Count(&used)
+	if used > 0 {
+		if err := config.DB.Model(&models.Service{}).Where("id = ?", id).Update("discontinued", true).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to discontinue service"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Service has been billed and was discontinued"})
+		return
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("service_id = ?", id).Delete(&models.ServicePrice{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Service{}, id).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete service"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Service deleted successfully"})
+}
+
+// AddServicePrice sets a new price for a service from a date on, today by
+// default. Prices already in force cannot be backdated.
+func AddServicePrice(c *gin.Context) {
+	service, ok := findService(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Amount        decimal.Decimal `json:"amount"`
+		Currency      string          `json:"currency"`
+		EffectiveFrom string          `json:"effectiveFrom"` // YYYY-MM-DD
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	currency, err := billCurrency(input.Currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Amount.IsNegative() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Price cannot be negative"})
+		return
+	}
+
+	price := models.ServicePrice{
+		ServiceID:     service.ID,
+		Currency:      currency.Code,
+		EffectiveFrom: startOfToday(),
+		Amount:        input.Amount,
+		CreatedBy:     currentUserID(c),
+	}
+	if input.EffectiveFrom != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", input.EffectiveFrom, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid effective date. Use YYYY-MM-DD"})
+			return
+		}
+		if parsed.Before(startOfToday()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Prices cannot take effect in the past"})
+			return
+		}
+		price.EffectiveFrom = parsed
+	}
+
+	// A new price for the same day replaces the one set earlier
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("service_id = ? AND currency = ? AND effective_from = ?", service.ID, price.Currency, price.EffectiveFrom).
+			Delete(&models.ServicePrice{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&price).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add price"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, price)
+}
+
+// DeleteServicePrice withdraws a price that has not taken effect yet.
+func DeleteServicePrice(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid service ID"})
+		return
+	}
+	priceID, err := strconv.ParseUint(c.Param("priceId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid price ID"})
+		return
+	}
+
+	var price models.ServicePrice
+	if err := config.DB.Where("service_id = ?", id).First(&price, priceID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Price not found"})
+		return
+	}
+	if !price.EffectiveFrom.After(time.Now()) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Prices in force cannot be deleted; add a new price instead"})
+		return
+	}
+
+	if err := config.DB.Delete(&price).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete price"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Price deleted successfully"})
+}
+
+func validateService(service *models.Service) error {
+	service.Code = strings.TrimSpace(service.Code)
+	service.Name = strings.TrimSpace(service.Name)
+	if service.Code == "" || service.Name == "" {
+		return errors.New("Service code and name are required")
+	}
+	if !serviceCategories[service.Category] {
+		return errors.New("Invalid service category " + service.Category)
+	}
+	if service.Category == "RoomRate" && service.RoomType == "" {
+		return errors.New("Room rates need a room type")
+	}
+	if service.TaxRate.IsNegative() || service.TaxRate.GreaterThan(hundred) {
+		return errors.New("Tax rate must be between 0 and 100")
+	}
+
+	var existing int64
+	config.DB.Model(&models.Service{}).Where("code = ? AND id <> ?", service.Code, service.ID).Count(&existing)
+	if existing > 0 {
+		return errors.New("Service code " + service.Code + " is already in use")
+	}
+
+	if service.Unit == "" {
+		switch service.Category {
+		case "Consultation":
+			service.Unit = "visit"
+		case "RoomRate":
+			service.Unit = "day"
+		case "LabTest":
+			service.Unit = "test"
+		default:
+			service.Unit = "unit"
+		}
+	}
+	return nil
+}
+
+func findService(c *gin.Context) (models.Service, bool) {
+	var service models.Service
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid service ID"})
+		return service, false
+	}
+
+	if err := config.DB.Preload("Prices", func(db *gorm.DB) *gorm.DB {
+		return db.Order("effective_from DESC")
+	}).First(&service, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
+		return service, false
+	}
+
+	return service, true
+}
+
+// servicePrice returns the price of the service in force on the day.
+func servicePrice(serviceID uint, currency string, day time.Time) (models.ServicePrice, error) {
+	var price models.ServicePrice
+	err := config.DB.
+		Where("service_id = ? AND currency = ? AND effective_from <= ?", serviceID, currency, day).
+		Order("effective_from DESC").
+		First(&price).Error
+	if err != nil {
+		return price, fmt.Errorf("No %s price in force on %s", currency, day.Format("2006-01-02"))
+	}
+	return price, nil
+}
+
+// catalogService finds the active service of the category for a
+// specialization or room type, preferring an exact match over a generic
+// entry with the field left empty.
+func catalogService(category, column, value string) (models.Service, bool) {
+	var service models.Service
+	err := config.DB.
+		Where("category = ? AND discontinued = ? AND ("+column+" = ? OR "+column+" = '')", category, false, value).
+		Order(column + " DESC, id ASC").
+		First(&service).Error
+	return service, err == nil
+}
+
+// catalogItem prices a bill item from the catalog as of the day.
+func catalogItem(service models.Service, currency string, day time.Time) (models.BillItem, error) {
+	price, err := servicePrice(service.ID, currency, day)
+	if err != nil {
+		return models.BillItem{}, fmt.Errorf("%s: %v", service.Name, err)
+	}
+	return models.BillItem{
+		ServiceID: &service.ID,
+		Service:   service.Name,
+		Quantity:  decimal.NewFromInt(1),
+		UnitPrice: price.Amount,
+		TaxRate:   service.TaxRate,
+	}, nil
+}
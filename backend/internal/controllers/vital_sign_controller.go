@@ -0,0 +1,426 @@
+package controllers
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"clinic-backend/internal/config"
+	"clinic-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type vitalParameter struct {
+	Label string
+	Unit  string
+	// Conversions into Unit from other accepted units
+	Convert map[string]func(float64) float64
+}
+
+var vitalParameters = map[string]vitalParameter{
+	"systolic_bp":      {Label: "Systolic BP", Unit: "mmHg"},
+	"diastolic_bp":     {Label: "Diastolic BP", Unit: "mmHg"},
+	"pulse":            {Label: "Pulse", Unit: "bpm"},
+	"respiratory_rate": {Label: "Respiratory rate", Unit: "breaths/min"},
+	"spo2":             {Label: "SpO2", Unit: "%"},
+	"temperature": {Label: "Temperature", Unit: "C", Convert: map[string]func(float64) float64{
+		"F": func(v float64) float64 { return (v - 32) * 5 / 9 },
+	}},
+	"weight": {Label: "Weight", Unit: "kg", Convert: map[string]func(float64) float64{
+		"lb": func(v float64) float64 { return v * 0.45359237 },
+	}},
+	"height": {Label: "Height", Unit: "cm", Convert: map[string]func(float64) float64{
+		"in": func(v float64) float64 { return v * 2.54 },
+		"m":  func(v float64) float64 { return v * 100 },
+	}},
+	"bmi": {Label: "BMI", Unit: "kg/m2"},
+}
+
+type vitalReading struct {
+	Parameter string  `json:"parameter" binding:"required"`
+	Value     float64 `json:"value"`
+	Unit      string  `json:"unit"`
+}
+
+type vitalPoint struct {
+	RecordedAt time.Time `json:"recordedAt"`
+	Value      float64   `json:"value"`
+	Flag       string    `json:"flag"`
+}
+
+// RecordVitalSigns stores a set of readings taken together, converting
+// them to standard units, flagging abnormal values and deriving the BMI.
+func RecordVitalSigns(c *gin.Context) {
+	var body struct {
+		PatientID   uint           `json:"patientId" binding:"required"`
+		EncounterID *uint          `json:"encounterId"`
+		RecordedAt  time.Time      `json:"recordedAt"`
+		Readings    []vitalReading `json:"readings" binding:"required,min=1,dive"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Verify patient exists
+	var patient models.Patient
+	if err := config.DB.First(&patient, body.PatientID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Patient not found"})
+		return
+	}
+
+	// Verify encounter belongs to the patient if provided
+	if body.EncounterID != nil {
+		var encounter models.Encounter
+		if err := config.DB.First(&encounter, *body.EncounterID).Error; err != nil || encounter.PatientID != patient.ID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Encounter not found for patient"})
+			return
+		}
+	}
+
+	if body.RecordedAt.IsZero() {
+		body.RecordedAt = time.Now()
+	}
+	userID, _ := c.Get("userID")
+	recordedBy, _ := userID.(uint)
+
+	values := map[string]float64{}
+	for _, reading := range body.Readings {
+		name := strings.ToLower(reading.Parameter)
+		parameter, ok := vitalParameters[name]
+		if !ok || name == "bmi" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown vital sign parameter " + reading.Parameter})
+			return
+		}
+		value := reading.Value
+		if reading.Unit != "" && reading.Unit != parameter.Unit {
+			convert, ok := parameter.Convert[reading.Unit]
+			if !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported unit " + reading.Unit + " for " + name})
+				return
+			}
+			value = convert(value)
+		}
+		values[name] = math.Round(value*10) / 10
+	}
+
+	if bmi, ok := calculateBMI(patient.ID, values); ok {
+		values["bmi"] = bmi
+	}
+
+	ranges := vitalReferenceRanges()
+	var signs []models.VitalSign
+	for name, value := range values {
+		signs = append(signs, models.VitalSign{
+			PatientID:   patient.ID,
+			EncounterID: body.EncounterID,
+			Parameter:   name,
+			Value:       value,
+			Unit:        vitalParameters[name].Unit,
+			Flag:        flagVitalSign(ranges, patient, name, value),
+			RecordedAt:  body.RecordedAt,
+			RecordedBy:  recordedBy,
+		})
+	}
+
+	if err := config.DB.Create(&signs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record vital signs"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, signs)
+}
+
+func GetVitalSigns(c *gin.Context) {
+	var signs []models.VitalSign
+	query := config.DB.Order("recorded_at DESC")
+
+	// Filter by patient if provided
+	if patientID := c.Query("patientId"); patientID != "" {
+		query = query.Where("patient_id = ?", patientID)
+	}
+
+	// Filter by encounter if provided
+	if encounterID := c.Query("encounterId"); encounterID != "" {
+		query = query.Where("encounter_id = ?", encounterID)
+	}
+
+	// Filter by parameter if provided
+	if parameter := c.Query("parameter"); parameter != "" {
+		query = query.Where("parameter = ?", parameter)
+	}
+
+	// Only abnormal readings if requested
+	if c.Query("abnormal") == "true" {
+		query = query.Where("flag NOT IN ?", []string{"", "Normal"})
+	}
+
+	if err := query.Find(&signs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch vital signs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, signs)
+}
+
+// GetVitalSignTrends returns each parameter's readings as a time series for
+// charting, oldest first.
+func GetVitalSignTrends(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid patient ID"})
+		return
+	}
+
+	from, to, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+
+	query := config.DB.Where("patient_id = ? AND recorded_at >= ? AND recorded_at < ?", id, from, to).
+		Order("recorded_at ASC")
+	if parameter := c.Query("parameter"); parameter != "" {
+		query = query.Where("parameter = ?", parameter)
+	}
+
+	var signs []models.VitalSign
+	if err := query.Find(&signs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch vital signs"})
+		return
+	}
+
+	type series struct {
+		Unit   string       `json:"unit"`
+		Points []vitalPoint `json:"points"`
+	}
+	trends := map[string]*series{}
+	for _, sign := range signs {
+		s, ok := trends[sign.Parameter]
+		if !ok {
+			s = &series{Unit: sign.Unit, Points: []vitalPoint{}}
+			trends[sign.Parameter] = s
+		}
+		s.Points = append(s.Points, vitalPoint{RecordedAt: sign.RecordedAt, Value: sign.Value, Flag: sign.Flag})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"patientId": id, "from": from, "to": to, "parameters": trends})
+}
+
+func DeleteVitalSign(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid vital sign ID"})
+		return
+	}
+
+	if err := config.DB.Delete(&models.VitalSign{}, id).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete vital sign"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Vital sign deleted successfully"})
+}
+
+func GetVitalReferenceRanges(c *gin.Context) {
+	var ranges []models.VitalReferenceRange
+	query := config.DB.Order("parameter ASC, min_age ASC")
+
+	// Filter by parameter if provided
+	if parameter := c.Query("parameter"); parameter != "" {
+		query = query.Where("parameter = ?", parameter)
+	}
+
+	if err := query.Find(&ranges).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reference ranges"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ranges)
+}
+
+func CreateVitalReferenceRange(c *gin.Context) {
+	var r models.VitalReferenceRange
+	if err := c.ShouldBindJSON(&r); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, ok := vitalParameters[r.Parameter]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown vital sign parameter " + r.Parameter})
+		return
+	}
+
+	if err := config.DB.Create(&r).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create reference range"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, r)
+}
+
+func UpdateVitalReferenceRange(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reference range ID"})
+		return
+	}
+
+	var r models.VitalReferenceRange
+	if err := config.DB.First(&r, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reference range not found"})
+		return
+	}
+
+	if err := c.ShouldBindJSON(&r); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, ok := vitalParameters[r.Parameter]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown vital sign parameter " + r.Parameter})
+		return
+	}
+
+	if err := config.DB.Save(&r).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update reference range"})
+		return
+	}
+
+	c.JSON(http.StatusOK, r)
+}
+
+func DeleteVitalReferenceRange(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reference range ID"})
+		return
+	}
+
+	if err := config.DB.Delete(&models.VitalReferenceRange{}, id).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete reference range"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Reference range deleted successfully"})
+}
+
+// SeedVitalReferenceRanges installs default adult and paediatric ranges the
+// first time the server starts with an empty table.
+func SeedVitalReferenceRanges() {
+	var count int64
+	config.DB.Model(&models.VitalReferenceRange{}).Count(&count)
+	if count > 0 {
+		return
+	}
+
+	r := func(parameter string, minAge, maxAge int, low, high, criticalLow, criticalHigh *float64) models.VitalReferenceRange {
+		return models.VitalReferenceRange{
+			Parameter: parameter, MinAge: minAge, MaxAge: maxAge,
+			Low: low, High: high, CriticalLow: criticalLow, CriticalHigh: criticalHigh,
+		}
+	}
+	v := func(f float64) *float64 { return &f }
+
+	defaults := []models.VitalReferenceRange{
+		r("systolic_bp", 18, 150, v(90), v(139), v(70), v(180)),
+		r("diastolic_bp", 18, 150, v(60), v(89), v(40), v(120)),
+		r("pulse", 0, 0, v(100), v(160), v(80), v(200)),
+		r("pulse", 1, 11, v(70), v(120), v(50), v(180)),
+		r("pulse", 12, 150, v(60), v(100), v(40), v(150)),
+		r("respiratory_rate", 0, 0, v(30), v(60), v(20), v(70)),
+		r("respiratory_rate", 1, 11, v(18), v(30), v(12), v(40)),
+		r("respiratory_rate", 12, 150, v(12), v(20), v(8), v(30)),
+		r("temperature", 0, 150, v(36.1), v(37.5), v(35), v(40)),
+		r("spo2", 0, 150, v(95), nil, v(90), nil),
+		r("bmi", 18, 150, v(18.5), v(24.9), v(16), v(40)),
+	}
+
+	if err := config.DB.Create(&defaults).Error; err != nil {
+		log.Println("Failed to seed vital sign reference ranges:", err)
+	}
+}
+
+// flagVitalSign grades a value against the most specific reference range
+// for the patient's age and sex.
+func flagVitalSign(ranges []models.VitalReferenceRange, patient models.Patient, parameter string, value float64) string {
+	var best *models.VitalReferenceRange
+	for i := range ranges {
+		r := &ranges[i]
+		if r.Parameter != parameter || patient.Age < r.MinAge || patient.Age > r.MaxAge {
+			continue
+		}
+		if r.Sex != "" && !strings.EqualFold(r.Sex, patient.Gender) {
+			continue
+		}
+		if best == nil || moreSpecificRange(r, best) {
+			best = r
+		}
+	}
+	if best == nil {
+		return ""
+	}
+
+	switch {
+	case best.CriticalLow != nil && value < *best.CriticalLow:
+		return "CriticalLow"
+	case best.CriticalHigh != nil && value > *best.CriticalHigh:
+		return "CriticalHigh"
+	case best.Low != nil && value < *best.Low:
+		return "Low"
+	case best.High != nil && value > *best.High:
+		return "High"
+	}
+	return "Normal"
+}
+
+func moreSpecificRange(a, b *models.VitalReferenceRange) bool {
+	if (a.Sex != "") != (b.Sex != "") {
+		return a.Sex != ""
+	}
+	return a.MaxAge-a.MinAge < b.MaxAge-b.MinAge
+}
+
+func vitalReferenceRanges() []models.VitalReferenceRange {
+	var ranges []models.VitalReferenceRange
+	config.DB.Find(&ranges)
+	return ranges
+}
+
+// calculateBMI derives the BMI from the weight and height in this set of
+// readings, falling back to the patient's latest recorded value for
+// whichever of the two was not measured now.
+func calculateBMI(patientID uint, values map[string]float64) (float64, bool) {
+	weight, hasWeight := values["weight"]
+	height, hasHeight := values["height"]
+	if !hasWeight && !hasHeight {
+		return 0, false
+	}
+
+	latest := func(parameter string) (float64, bool) {
+		var sign models.VitalSign
+		err := config.DB.Where("patient_id = ? AND parameter = ?", patientID, parameter).
+			Order("recorded_at DESC").
+			First(&sign).Error
+		if err != nil {
+			return 0, false
+		}
+		return sign.Value, true
+	}
+
+	if !hasWeight {
+		weight, hasWeight = latest("weight")
+	}
+	if !hasHeight {
+		height, hasHeight = latest("height")
+	}
+	if !hasWeight || !hasHeight || height <= 0 {
+		return 0, false
+	}
+
+	meters := height / 100
+	return math.Round(weight/(meters*meters)*10) / 10, true
+}
@@ -0,0 +1,424 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"clinic-backend/internal/config"
+	"clinic-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func CreateWaitlistEntry(c *gin.Context) {
+	var entry models.WaitlistEntry
+	if err := c.ShouldBindJSON(&entry); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Verify patient exists
+	var patient models.Patient
+	if err := config.DB.First(&patient, entry.PatientID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Patient not found"})
+		return
+	}
+
+	// Verify doctor exists if a specific doctor was requested
+	if entry.DoctorID != nil {
+		var doctor models.Doctor
+		if err := config.DB.First(&doctor, *entry.DoctorID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Doctor not found"})
+			return
+		}
+		if entry.Specialization == "" {
+			entry.Specialization = doctor.Specialization
+		}
+	} else if entry.Specialization == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Either doctorId or specialization is required"})
+		return
+	}
+
+	if _, err := parseByDay(entry.PreferredDays); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry.Status = "Waiting"
+
+	if err := config.DB.Create(&entry).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create waitlist entry"})
+		return
+	}
+
+	// Load relations
+	config.DB.Preload("Patient").Preload("Doctor").First(&entry, entry.ID)
+
+	c.JSON(http.StatusCreated, entry)
+}
+
+func GetWaitlist(c *gin.Context) {
+	var entries []models.WaitlistEntry
+	query := config.DB.Preload("Patient").Preload("Doctor").Order("priority DESC, created_at ASC")
+
+	// Filter by doctor if provided
+	if doctorID := c.Query("doctorId"); doctorID != "" {
+		query = query.Where("doctor_id = ?", doctorID)
+	}
+
+	// Filter by specialization if provided
+	if specialization := c.Query("specialization"); specialization != "" {
+		query = query.Where("specialization = ?", specialization)
+	}
+
+	// Filter by status if provided
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch waitlist"})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+func GetWaitlistEntryByID(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid waitlist entry ID"})
+		return
+	}
+
+	var entry models.WaitlistEntry
+	if err := config.DB.Preload("Patient").Preload("Doctor").First(&entry, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Waitlist entry not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
+
+func UpdateWaitlistEntry(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid waitlist entry ID"})
+		return
+	}
+
+	var entry models.WaitlistEntry
+	if err := config.DB.First(&entry, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Waitlist entry not found"})
+		return
+	}
+
+	previous := entry
+
+	if err := c.ShouldBindJSON(&entry); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Offers move the status along; the patient stays who they were
+	entry.ID = previous.ID
+	entry.PatientID = previous.PatientID
+	entry.Status = previous.Status
+	entry.CreatedAt = previous.CreatedAt
+
+	if _, err := parseByDay(entry.PreferredDays); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := config.DB.Save(&entry).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update waitlist entry"})
+		return
+	}
+
+	config.DB.Preload("Patient").Preload("Doctor").First(&entry, entry.ID)
+	c.JSON(http.StatusOK, entry)
+}
+
+func DeleteWaitlistEntry(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid waitlist entry ID"})
+		return
+	}
+
+	// Release any slot currently held for this entry
+	var offers []models.WaitlistOffer
+	config.DB.Where("waitlist_entry_id = ? AND status = ?", id, "Pending").Find(&offers)
+
+	if err := config.DB.Delete(&models.WaitlistEntry{}, id).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete waitlist entry"})
+		return
+	}
+
+	for _, offer := range offers {
+		config.DB.Model(&offer).Update("status", "Declined")
+		offerFreedSlot(offer.DoctorID, offer.Date, offer.Time)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Waitlist entry deleted successfully"})
+}
+
+func GetWaitlistOffers(c *gin.Context) {
+	var offers []models.WaitlistOffer
+	query := config.DB.Preload("Patient").Preload("Doctor").Order("created_at DESC")
+
+	// Filter by patient if provided
+	if patientID := c.Query("patientId"); patientID != "" {
+		query = query.Where("patient_id = ?", patientID)
+	}
+
+	// Filter by status if provided
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Find(&offers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch waitlist offers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, offers)
+}
+
+func AcceptWaitlistOffer(c *gin.Context) {
+	offer, ok := findPendingOffer(c)
+	if !ok {
+		return
+	}
+
+	if time.Now().After(offer.ExpiresAt) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Offer has expired"})
+		return
+	}
+
+	appointment := models.Appointment{
+		PatientID: offer.PatientID,
+		DoctorID:  offer.DoctorID,
+		Date:      offer.Date,
+		Time:      offer.Time,
+		Status:    "Scheduled",
+		Notes:     "Booked from waitlist",
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockDoctorSchedules(tx, offer.DoctorID); err != nil {
+			return err
+		}
+		if _, taken := findAppointmentConflict(tx, offer.DoctorID, offer.Date, offer.Time); taken {
+			return errScheduleConflict
+		}
+		if err := tx.Create(&appointment).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&offer).Updates(map[string]interface{}{
+			"status":         "Accepted",
+			"appointment_id": appointment.ID,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.WaitlistEntry{}).Where("id = ?", offer.WaitlistEntryID).Update("status", "Booked").Error
+	})
+	if errors.Is(err, errScheduleConflict) {
+		config.DB.Model(&offer).Update("status", "Expired")
+		config.DB.Model(&models.WaitlistEntry{}).Where("id = ?", offer.WaitlistEntryID).Update("status", "Waiting")
+		c.JSON(http.StatusConflict, gin.H{"error": "Slot is no longer available"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept offer"})
+		return
+	}
+
+	notifyAppointment(appointment, "Confirmation")
+
+	config.DB.Preload("Patient").Preload("Doctor").First(&appointment, appointment.ID)
+	c.JSON(http.StatusCreated, appointment)
+}
+
+func DeclineWaitlistOffer(c *gin.Context) {
+	offer, ok := findPendingOffer(c)
+	if !ok {
+		return
+	}
+
+	config.DB.Model(&offer).Update("status", "Declined")
+	config.DB.Model(&models.WaitlistEntry{}).Where("id = ?", offer.WaitlistEntryID).Update("status", "Waiting")
+
+	// Move on to the next patient in line
+	offerFreedSlot(offer.DoctorID, offer.Date, offer.Time)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Offer declined successfully"})
+}
+
+// ExpireWaitlistOffers releases holds that were not taken up in time and
+// passes each slot on to the next waitlisted patient.
+func ExpireWaitlistOffers() {
+	var offers []models.WaitlistOffer
+	if err := config.DB.Where("status = ? AND expires_at <= ?", "Pending", time.Now()).Find(&offers).Error; err != nil {
+		log.Println("Failed to fetch expired waitlist offers:", err)
+		return
+	}
+
+	for _, offer := range offers {
+		config.DB.Model(&offer).Update("status", "Expired")
+		config.DB.Model(&models.WaitlistEntry{}).
+			Where("id = ? AND status = ?", offer.WaitlistEntryID, "Offered").
+			Update("status", "Waiting")
+		offerFreedSlot(offer.DoctorID, offer.Date, offer.Time)
+	}
+}
+
+// offerFreedSlot holds a freed slot for the highest priority waitlisted
+// patient whose preferences match and who has not been offered it before.
+func offerFreedSlot(doctorID uint, date time.Time, slot string) {
+	if start, _ := (models.Appointment{Date: date, Time: slot}).ScheduledAt(); start.Before(time.Now()) {
+		return
+	}
+
+	// Slot may have been rebooked already
+	if _, taken := findAppointmentConflict(config.DB, doctorID, date, slot); taken {
+		return
+	}
+
+	// Slot is already held for someone
+	var pending int64
+	config.DB.Model(&models.WaitlistOffer{}).
+		Where("doctor_id = ? AND date = ? AND time = ? AND status = ?", doctorID, date, slot, "Pending").
+		Count(&pending)
+	if pending > 0 {
+		return
+	}
+
+	var doctor models.Doctor
+	if err := config.DB.First(&doctor, doctorID).Error; err != nil {
+		return
+	}
+
+	var entries []models.WaitlistEntry
+	if err := config.DB.
+		Where("status = ?", "Waiting").
+		Where("doctor_id = ? OR (doctor_id IS NULL AND specialization = ?)", doctorID, doctor.Specialization).
+		Order("priority DESC, created_at ASC").
+		Find(&entries).Error; err != nil {
+		log.Println("Failed to fetch waitlist:", err)
+		return
+	}
+
+	for _, entry := range entries {
+		if !entryAcceptsSlot(entry, date) {
+			continue
+		}
+
+		// Skip patients who already let this slot go
+		var previous int64
+		config.DB.Model(&models.WaitlistOffer{}).
+			Where("waitlist_entry_id = ? AND doctor_id = ? AND date = ? AND time = ?", entry.ID, doctorID, date, slot).
+			Count(&previous)
+		if previous > 0 {
+			continue
+		}
+
+		offer := models.WaitlistOffer{
+			WaitlistEntryID: entry.ID,
+			PatientID:       entry.PatientID,
+			DoctorID:        doctorID,
+			Date:            date,
+			Time:            slot,
+			Status:          "Pending",
+			ExpiresAt:       time.Now().Add(waitlistHoldDuration()),
+		}
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&offer).Error; err != nil {
+				return err
+			}
+			return tx.Model(&entry).Update("status", "Offered").Error
+		})
+		if err != nil {
+			log.Println("Failed to create waitlist offer:", err)
+		}
+		return
+	}
+}
+
+// slotHeld reports whether the doctor's slot is held by a pending waitlist
+// offer for someone other than the patient.
+func slotHeld(db *gorm.DB, doctorID uint, date time.Time, slot string, patientID uint) bool {
+	startOfDay := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+
+	var held int64
+	db.Model(&models.WaitlistOffer{}).
+		Where("doctor_id = ? AND date >= ? AND date < ? AND time = ?", doctorID, startOfDay, startOfDay.Add(24*time.Hour), slot).
+		Where("status = ? AND expires_at > ? AND patient_id <> ?", "Pending", time.Now(), patientID).
+		Count(&held)
+	return held > 0
+}
+
+func entryAcceptsSlot(entry models.WaitlistEntry, date time.Time) bool {
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+
+	if entry.EarliestDate != nil {
+		e := *entry.EarliestDate
+		if day.Before(time.Date(e.Year(), e.Month(), e.Day(), 0, 0, 0, 0, day.Location())) {
+			return false
+		}
+	}
+	if entry.LatestDate != nil {
+		l := *entry.LatestDate
+		if day.After(time.Date(l.Year(), l.Month(), l.Day(), 0, 0, 0, 0, day.Location())) {
+			return false
+		}
+	}
+
+	days, err := parseByDay(entry.PreferredDays)
+	if err != nil || len(days) == 0 {
+		return true
+	}
+	for _, preferred := range days {
+		if preferred == date.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// waitlistHoldDuration is how long an offered slot is held, configurable
+// through WAITLIST_HOLD_MINUTES.
+func waitlistHoldDuration() time.Duration {
+	if minutes, err := strconv.Atoi(os.Getenv("WAITLIST_HOLD_MINUTES")); err == nil && minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return 30 * time.Minute
+}
+
+func findPendingOffer(c *gin.Context) (models.WaitlistOffer, bool) {
+	var offer models.WaitlistOffer
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offer ID"})
+		return offer, false
+	}
+
+	if err := config.DB.First(&offer, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Offer not found"})
+		return offer, false
+	}
+
+	if offer.Status != "Pending" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Offer is no longer pending"})
+		return offer, false
+	}
+
+	return offer, true
+}
@@ -0,0 +1,396 @@
+// Package documents renders printable PDF documents such as prescriptions
+// and visit summaries on the hospital letterhead.
+package documents
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/skip2/go-qrcode"
+)
+
+const (
+	pageMargin  = 15.0
+	lineHeight  = 5.0
+	qrSize      = 26.0
+	dateLayout  = "02 Jan 2006"
+	stampLayout = "02 Jan 2006 15:04"
+)
+
+type Letterhead struct {
+	Name        string
+	Tagline     string
+	Address     string
+	Phone       string
+	Email       string
+	Website     string
+	TaxNumber   string // tax registration number, e.g. VAT or GST
+	LogoPath    string // PNG or JPEG on the server
+	Footer      string
+	AccentColor string // hex, e.g. "#1f6feb"
+}
+
+// Field is a labelled value shown in the document's header grid.
+type Field struct {
+	Label string
+	Value string
+}
+
+type Table struct {
+	Columns []string
+	Widths  []float64 // relative widths, one per column
+	Rows    [][]string
+}
+
+// Section is a titled block with free text, a table, or both.
+type Section struct {
+	Heading string
+	Body    string
+	Table   *Table
+}
+
+type Signature struct {
+	Name         string
+	Title        string // e.g. specialization or qualifications
+	Registration string
+	ImagePath    string // scanned signature, PNG or JPEG
+	SignedAt     *time.Time
+}
+
+type Document struct {
+	Letterhead Letterhead
+	Title      string
+	Reference  string
+	Date       time.Time
+	Watermark  string // e.g. "DRAFT"
+	Fields     []Field
+	Sections   []Section
+	Signature  *Signature
+	VerifyURL  string // encoded in a QR code next to the signature
+}
+
+// Render writes the document as a single A4 PDF.
+func Render(w io.Writer, doc Document) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(pageMargin, pageMargin, pageMargin)
+	pdf.SetAutoPageBreak(true, 20)
+	pdf.AliasNbPages("")
+	pdf.SetTitle(doc.Title, true)
+	pdf.SetCreator(doc.Letterhead.Name, true)
+
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	r, g, b := parseColor(doc.Letterhead.AccentColor)
+
+	pdf.SetHeaderFunc(func() {
+		if doc.Watermark != "" {
+			pdf.SetFont("Helvetica", "B", 60)
+			pdf.SetTextColor(230, 230, 230)
+			pdf.TransformBegin()
+			pdf.TransformRotate(45, 105, 150)
+			pdf.Text(55, 170, tr(doc.Watermark))
+			pdf.TransformEnd()
+		}
+		pdf.SetTextColor(0, 0, 0)
+	})
+
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-15)
+		pdf.SetDrawColor(r, g, b)
+		pdf.Line(pageMargin, pdf.GetY(), 210-pageMargin, pdf.GetY())
+		pdf.SetFont("Helvetica", "", 8)
+		pdf.SetTextColor(110, 110, 110)
+		pdf.CellFormat(150, 6, tr(doc.Letterhead.Footer), "", 0, "L", false, 0, "")
+		pdf.CellFormat(0, 6, fmt.Sprintf("Page %d/{nb}", pdf.PageNo()), "", 0, "R", false, 0, "")
+	})
+
+	pdf.AddPage()
+	writeLetterhead(pdf, tr, doc.Letterhead, r, g, b)
+
+	// Title and reference
+	pdf.Ln(4)
+	pdf.SetFont("Helvetica", "B", 14)
+	pdf.SetTextColor(r, g, b)
+	pdf.CellFormat(0, 8, tr(doc.Title), "", 1, "C", false, 0, "")
+	pdf.SetTextColor(0, 0, 0)
+	pdf.SetFont("Helvetica", "", 9)
+	var ref []string
+	if doc.Reference != "" {
+		ref = append(ref, "Ref: "+doc.Reference)
+	}
+	if !doc.Date.IsZero() {
+		ref = append(ref, "Date: "+doc.Date.Format(dateLayout))
+	}
+	pdf.CellFormat(0, lineHeight, tr(strings.Join(ref, "    ")), "", 1, "R", false, 0, "")
+	pdf.Ln(2)
+
+	writeFields(pdf, tr, doc.Fields)
+
+	for _, section := range doc.Sections {
+		writeSection(pdf, tr, section, r, g, b)
+	}
+
+	if err := writeSignature(pdf, tr, doc); err != nil {
+		return err
+	}
+
+	return pdf.Output(w)
+}
+
+func writeLetterhead(pdf *fpdf.Fpdf, tr func(string) string, lh Letterhead, r, g, b int) {
+	x := pageMargin
+	if lh.LogoPath != "" {
+		if _, err := os.Stat(lh.LogoPath); err == nil {
+			pdf.ImageOptions(lh.LogoPath, pageMargin, pageMargin, 0, 18, false, fpdf.ImageOptions{ReadDpi: true}, 0, "")
+			x += 24
+		}
+	}
+
+	pdf.SetXY(x, pageMargin)
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.SetTextColor(r, g, b)
+	pdf.CellFormat(0, 7, tr(lh.Name), "", 2, "L", false, 0, "")
+	pdf.SetTextColor(80, 80, 80)
+	if lh.Tagline != "" {
+		pdf.SetFont("Helvetica", "I", 9)
+		pdf.CellFormat(0, 4.5, tr(lh.Tagline), "", 2, "L", false, 0, "")
+	}
+	pdf.SetFont("Helvetica", "", 8.5)
+	if lh.Address != "" {
+		pdf.CellFormat(0, 4, tr(lh.Address), "", 2, "L", false, 0, "")
+	}
+	var contact []string
+	for _, part := range []string{lh.Phone, lh.Email, lh.Website} {
+		if part != "" {
+			contact = append(contact, part)
+		}
+	}
+	if len(contact) > 0 {
+		pdf.CellFormat(0, 4, tr(strings.Join(contact, "  |  ")), "", 2, "L", false, 0, "")
+	}
+	if lh.TaxNumber != "" {
+		pdf.CellFormat(0, 4, tr("Tax No. "+lh.TaxNumber), "", 2, "L", false, 0, "")
+	}
+
+	y := pdf.GetY() + 2
+	if y < pageMargin+20 {
+		y = pageMargin + 20
+	}
+	pdf.SetDrawColor(r, g, b)
+	pdf.SetLineWidth(0.6)
+	pdf.Line(pageMargin, y, 210-pageMargin, y)
+	pdf.SetLineWidth(0.2)
+	pdf.SetTextColor(0, 0, 0)
+	pdf.SetXY(pageMargin, y+1)
+}
+
+// writeFields lays the fields out in two label/value columns.
+func writeFields(pdf *fpdf.Fpdf, tr func(string) string, fields []Field) {
+	if len(fields) == 0 {
+		return
+	}
+	const labelWidth, valueWidth = 28.0, 62.0
+
+	pdf.SetFillColor(246, 246, 246)
+	for i := 0; i < len(fields); i += 2 {
+		for j := i; j < i+2; j++ {
+			if j >= len(fields) {
+				pdf.CellFormat(labelWidth+valueWidth, 6, "", "", 0, "L", true, 0, "")
+				continue
+			}
+			pdf.SetFont("Helvetica", "B", 9)
+			pdf.CellFormat(labelWidth, 6, tr(fields[j].Label), "", 0, "L", true, 0, "")
+			pdf.SetFont("Helvetica", "", 9)
+			pdf.CellFormat(valueWidth, 6, truncate(pdf, tr(fields[j].Value), valueWidth-2), "", 0, "L", true, 0, "")
+		}
+		pdf.Ln(6)
+	}
+	pdf.Ln(3)
+}
+
+func writeSection(pdf *fpdf.Fpdf, tr func(string) string, section Section, r, g, b int) {
+	if section.Heading != "" {
+		pdf.SetFont("Helvetica", "B", 11)
+		pdf.SetTextColor(r, g, b)
+		pdf.CellFormat(0, 7, tr(section.Heading), "B", 1, "L", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+		pdf.Ln(1)
+	}
+
+	if strings.TrimSpace(section.Body) != "" {
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.MultiCell(0, lineHeight, tr(section.Body), "", "L", false)
+		pdf.Ln(1)
+	}
+
+	if section.Table != nil && len(section.Table.Columns) > 0 {
+		writeTable(pdf, tr, *section.Table)
+	}
+	pdf.Ln(3)
+}
+
+func writeTable(pdf *fpdf.Fpdf, tr func(string) string, table Table) {
+	pageWidth := 210 - 2*pageMargin
+	widths := make([]float64, len(table.Columns))
+	total := 0.0
+	for i := range table.Columns {
+		widths[i] = 1
+		if i < len(table.Widths) && table.Widths[i] > 0 {
+			widths[i] = table.Widths[i]
+		}
+		total += widths[i]
+	}
+	for i := range widths {
+		widths[i] = widths[i] / total * pageWidth
+	}
+
+	pdf.SetFont("Helvetica", "B", 9)
+	pdf.SetFillColor(235, 235, 235)
+	for i, column := range table.Columns {
+		pdf.CellFormat(widths[i], 6, tr(column), "1", 0, "L", true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont("Helvetica", "", 9)
+	for _, row := range table.Rows {
+		// Rows grow to fit the cell with the most wrapped lines
+		lines := 1
+		wrapped := make([][]string, len(table.Columns))
+		for i := range table.Columns {
+			text := ""
+			if i < len(row) {
+				text = tr(row[i])
+			}
+			wrapped[i] = pdf.SplitText(text, widths[i]-2)
+			if len(wrapped[i]) > lines {
+				lines = len(wrapped[i])
+			}
+		}
+		height := float64(lines) * 4.5
+		if pdf.GetY()+height > 297-20 {
+			pdf.AddPage()
+		}
+
+		x, y := pdf.GetXY()
+		for i := range table.Columns {
+			pdf.Rect(x, y, widths[i], height, "D")
+			pdf.SetXY(x, y)
+			pdf.MultiCell(widths[i], 4.5, strings.Join(wrapped[i], "\n"), "", "L", false)
+			x += widths[i]
+		}
+		pdf.SetXY(pageMargin, y+height)
+	}
+}
+
+// writeSignature puts the signature block and verification QR code at the
+// bottom of the last page.
+func writeSignature(pdf *fpdf.Fpdf, tr func(string) string, doc Document) error {
+	if doc.Signature == nil && doc.VerifyURL == "" {
+		return nil
+	}
+
+	const blockHeight = 34.0
+	if pdf.GetY()+blockHeight > 297-20 {
+		pdf.AddPage()
+	}
+	top := 297 - 20 - blockHeight
+	if pdf.GetY()+4 > top {
+		top = pdf.GetY() + 4
+	}
+
+	if doc.VerifyURL != "" {
+		png, err := qrcode.Encode(doc.VerifyURL, qrcode.Medium, 256)
+		if err != nil {
+			return err
+		}
+		pdf.RegisterImageOptionsReader("verify-qr", fpdf.ImageOptions{ImageType: "PNG"}, bytes.NewReader(png))
+		pdf.ImageOptions("verify-qr", pageMargin, top, qrSize, qrSize, false, fpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+		pdf.SetXY(pageMargin, top+qrSize)
+		pdf.SetFont("Helvetica", "", 7)
+		pdf.SetTextColor(110, 110, 110)
+		pdf.CellFormat(qrSize+20, 4, "Scan to verify this document", "", 0, "L", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+	}
+
+	if doc.Signature == nil {
+		return nil
+	}
+	sig := doc.Signature
+	const width = 70.0
+	x := 210 - pageMargin - width
+
+	if sig.ImagePath != "" {
+		if _, err := os.Stat(sig.ImagePath); err == nil {
+			pdf.ImageOptions(sig.ImagePath, x+5, top, 0, 14, false, fpdf.ImageOptions{ReadDpi: true}, 0, "")
+		}
+	}
+	pdf.Line(x, top+16, x+width, top+16)
+
+	pdf.SetXY(x, top+17)
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.CellFormat(width, 5, tr(sig.Name), "", 2, "C", false, 0, "")
+	pdf.SetFont("Helvetica", "", 8.5)
+	if sig.Title != "" {
+		pdf.CellFormat(width, 4, tr(sig.Title), "", 2, "C", false, 0, "")
+	}
+	if sig.Registration != "" {
+		pdf.CellFormat(width, 4, tr("Reg. No. "+sig.Registration), "", 2, "C", false, 0, "")
+	}
+	if sig.SignedAt != nil {
+		pdf.CellFormat(width, 4, tr("Electronically signed "+sig.SignedAt.Format(stampLayout)), "", 2, "C", false, 0, "")
+	}
+	return nil
+}
+
+// VerificationCode signs a document reference so a printed copy can be
+// checked against the server. The revision changes when the content does.
+func VerificationCode(secret, kind string, id uint, revision int) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s:%d:%d", kind, id, revision)
+	return hex.EncodeToString(mac.Sum(nil))[:20]
+}
+
+// ContentRevision fingerprints the date and sections of a document, for
+// documents that have no version number of their own.
+func ContentRevision(doc Document) int {
+	b, _ := json.Marshal(struct {
+		Date     time.Time
+		Sections []Section
+	}{doc.Date, doc.Sections})
+	h := fnv.New32a()
+	h.Write(b)
+	return int(h.Sum32() & math.MaxInt32)
+}
+
+// truncate shortens text to fit the width, ending it with "...".
+func truncate(pdf *fpdf.Fpdf, text string, width float64) string {
+	if pdf.GetStringWidth(text) <= width {
+		return text
+	}
+	for len(text) > 0 && pdf.GetStringWidth(text+"...") > width {
+		text = text[:len(text)-1]
+	}
+	return text + "..."
+}
+
+// parseColor reads a "#rrggbb" color, falling back to a dark blue.
+func parseColor(color string) (int, int, int) {
+	color = strings.TrimPrefix(strings.TrimSpace(color), "#")
+	if len(color) == 6 {
+		if v, err := strconv.ParseUint(color, 16, 32); err == nil {
+			return int(v >> 16 & 0xff), int(v >> 8 & 0xff), int(v & 0xff)
+		}
+	}
+	return 31, 78, 121
+}
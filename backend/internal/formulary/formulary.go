@@ -0,0 +1,97 @@
+// Package formulary reads drug catalog files.
+package formulary
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type Entry struct {
+	Code        string
+	GenericName string
+	BrandName   string
+	Strength    string
+	Form        string
+	Route       string
+}
+
+// columns maps accepted header names to entry fields.
+var columns = map[string]string{
+	"code":         "code",
+	"generic":      "generic",
+	"generic_name": "generic",
+	"genericname":  "generic",
+	"brand":        "brand",
+	"brand_name":   "brand",
+	"brandname":    "brand",
+	"strength":     "strength",
+	"form":         "form",
+	"dosage_form":  "form",
+	"route":        "route",
+}
+
+// Parse reads a CSV file whose header row names the columns, e.g.
+// "generic_name,brand_name,strength,form,route". Only the generic name
+// column is required; column order does not matter.
+func Parse(r io.Reader) ([]Entry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	index := map[string]int{}
+	for i, name := range header {
+		key := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		key = strings.ReplaceAll(key, " ", "_")
+		if field, ok := columns[key]; ok {
+			index[field] = i
+		}
+	}
+	if _, ok := index["generic"]; !ok {
+		return nil, errors.New("header must include a generic_name column")
+	}
+
+	var entries []Entry
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		get := func(field string) string {
+			if i, ok := index[field]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		entry := Entry{
+			Code:        get("code"),
+			GenericName: get("generic"),
+			BrandName:   get("brand"),
+			Strength:    get("strength"),
+			Form:        get("form"),
+			Route:       get("route"),
+		}
+		if entry.GenericName == "" {
+			if strings.TrimSpace(strings.Join(record, "")) == "" {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: generic name is required", line)
+		}
+		entries = append(entries, entry)
+	}
+}
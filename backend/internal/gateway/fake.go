@@ -0,0 +1,140 @@
+package gateway
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SignatureHeader carries the signature of the fake provider's webhooks.
+const SignatureHeader = "X-Fake-Signature"
+
+// FakeProvider is a local stand-in for a payment service, meant for
+// development and testing. Its checkout pages are served by this server and
+// its sessions and transactions only live as long as the process.
+type FakeProvider struct {
+	secret  string
+	baseURL string
+
+	mu           sync.Mutex
+	sessions     map[string]CheckoutRequest
+	transactions []Transaction
+}
+
+// NewFakeProvider signs webhooks with the secret, a random one when empty.
+// Checkout links point at baseURL.
+func NewFakeProvider(secret, baseURL string) *FakeProvider {
+	if secret == "" {
+		secret = randomID("")
+	}
+	if baseURL == "" {
+		baseURL = "http://localhost:8080"
+	}
+	return &FakeProvider{
+		secret:   secret,
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		sessions: map[string]CheckoutRequest{},
+	}
+}
+
+func (f *FakeProvider) Name() string { return "fake" }
+
+func (f *FakeProvider) CreateCheckout(ctx context.Context, req CheckoutRequest) (Checkout, error) {
+	id := randomID("fake_cs_")
+
+	f.mu.Lock()
+	f.sessions[id] = req
+	f.mu.Unlock()
+
+	return Checkout{SessionID: id, URL: f.baseURL + "/payment-gateway/fake/checkout/" + id}, nil
+}
+
+func (f *FakeProvider) ParseWebhook(payload []byte, header http.Header) (Event, error) {
+	var event Event
+	if err := Verify(f.secret, payload, header.Get(SignatureHeader), time.Now()); err != nil {
+		return event, err
+	}
+	err := json.Unmarshal(payload, &event)
+	return event, err
+}
+
+func (f *FakeProvider) Transactions(ctx context.Context, from, to time.Time) ([]Transaction, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var settled []Transaction
+	for _, t := range f.transactions {
+		if !t.At.Before(from) && t.At.Before(to) {
+			settled = append(settled, t)
+		}
+	}
+	return settled, nil
+}
+
+// Session returns what a checkout session was created for.
+func (f *FakeProvider) Session(id string) (CheckoutRequest, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	req, ok := f.sessions[id]
+	return req, ok
+}
+
+// Complete pays or declines a checkout session, as a patient would on a real
+// payment page, and returns the signed webhook the provider sends about it.
+func (f *FakeProvider) Complete(id string, succeed bool) ([]byte, http.Header, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	req, ok := f.sessions[id]
+	if !ok {
+		return nil, nil, fmt.Errorf("checkout session %s not found", id)
+	}
+	if !req.ExpiresAt.IsZero() && time.Now().After(req.ExpiresAt) {
+		return nil, nil, fmt.Errorf("checkout session %s has expired", id)
+	}
+
+	now := time.Now()
+	event := Event{
+		ID:         randomID("fake_evt_"),
+		Type:       PaymentFailed,
+		SessionID:  id,
+		Reference:  req.Reference,
+		Amount:     req.Amount,
+		Currency:   req.Currency,
+		OccurredAt: now,
+	}
+	if succeed {
+		event.Type = PaymentSucceeded
+		event.TransactionID = randomID("fake_tx_")
+		f.transactions = append(f.transactions, Transaction{
+			ID:        event.TransactionID,
+			SessionID: id,
+			Reference: req.Reference,
+			Amount:    req.Amount,
+			Currency:  req.Currency,
+			Succeeded: true,
+			At:        now,
+		})
+		delete(f.sessions, id)
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, nil, err
+	}
+	header := http.Header{}
+	header.Set(SignatureHeader, Sign(f.secret, payload, now))
+	return payload, header, nil
+}
+
+func randomID(prefix string) string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return prefix + hex.EncodeToString(b)
+}
@@ -0,0 +1,127 @@
+// Package ical renders appointments as RFC 5545 iCalendar data.
+package ical
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	dateTimeUTC   = "20060102T150405Z"
+	dateTimeLocal = "20060102T150405"
+	dateOnly      = "20060102"
+)
+
+type Event struct {
+	UID          string
+	Sequence     int
+	Start        time.Time
+	End          time.Time
+	AllDay       bool // Start and End are dates only
+	Floating     bool // Start and End are local wall clock times without a zone
+	Summary      string
+	Description  string
+	Location     string
+	Status       string // TENTATIVE, CONFIRMED, CANCELLED
+	Created      time.Time
+	LastModified time.Time
+}
+
+type Calendar struct {
+	Name   string
+	Method string // e.g., PUBLISH for one-off downloads, empty for feeds
+	Events []Event
+}
+
+// String serializes the calendar with CRLF line endings and folded lines.
+func (c Calendar) String() string {
+	var b strings.Builder
+	stamp := time.Now().UTC().Format(dateTimeUTC)
+
+	writeLine(&b, "BEGIN:VCALENDAR")
+	writeLine(&b, "VERSION:2.0")
+	writeLine(&b, "PRODID:-//Clinic Backend//Appointments//EN")
+	writeLine(&b, "CALSCALE:GREGORIAN")
+	if c.Method != "" {
+		writeLine(&b, "METHOD:"+c.Method)
+	}
+	if c.Name != "" {
+		writeLine(&b, "X-WR-CALNAME:"+escapeText(c.Name))
+	}
+
+	for _, e := range c.Events {
+		writeLine(&b, "BEGIN:VEVENT")
+		writeLine(&b, "UID:"+e.UID)
+		writeLine(&b, "DTSTAMP:"+stamp)
+		writeLine(&b, fmt.Sprintf("SEQUENCE:%d", e.Sequence))
+		writeLine(&b, "DTSTART"+formatTime(e.Start, e.AllDay, e.Floating))
+		writeLine(&b, "DTEND"+formatTime(e.End, e.AllDay, e.Floating))
+		writeLine(&b, "SUMMARY:"+escapeText(e.Summary))
+		if e.Description != "" {
+			writeLine(&b, "DESCRIPTION:"+escapeText(e.Description))
+		}
+		if e.Location != "" {
+			writeLine(&b, "LOCATION:"+escapeText(e.Location))
+		}
+		if e.Status != "" {
+			writeLine(&b, "STATUS:"+e.Status)
+		}
+		if !e.Created.IsZero() {
+			writeLine(&b, "CREATED:"+e.Created.UTC().Format(dateTimeUTC))
+		}
+		if !e.LastModified.IsZero() {
+			writeLine(&b, "LAST-MODIFIED:"+e.LastModified.UTC().Format(dateTimeUTC))
+		}
+		writeLine(&b, "END:VEVENT")
+	}
+
+	writeLine(&b, "END:VCALENDAR")
+	return b.String()
+}
+
+func formatTime(t time.Time, allDay, floating bool) string {
+	switch {
+	case allDay:
+		return ";VALUE=DATE:" + t.Format(dateOnly)
+	case floating:
+		return ":" + t.Format(dateTimeLocal)
+	default:
+		return ":" + t.UTC().Format(dateTimeUTC)
+	}
+}
+
+var textEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+)
+
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+// writeLine folds content lines longer than 75 octets as RFC 5545 requires,
+// taking care not to split a multi-byte character.
+func writeLine(b *strings.Builder, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// Continuation lines lose one octet to the leading space
+		limit = 74
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+func isRuneStart(c byte) bool {
+	return c&0xC0 != 0x80
+}
@@ -0,0 +1,120 @@
+// Package icd10 reads ICD-10 code tables from local files.
+package icd10
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+type Entry struct {
+	Code        string
+	Description string
+}
+
+// Parse reads either a CSV file with code and description columns (a
+// header row is skipped) or the CMS order/codes text format, where each
+// line is a code followed by whitespace and the description.
+func Parse(r io.Reader) ([]Entry, error) {
+	br := bufio.NewReader(r)
+	head, err := br.Peek(4096)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	// CMS descriptions contain commas too, but never before the first space
+	firstLine, _, _ := strings.Cut(string(head), "\n")
+	if firstField, _, found := strings.Cut(firstLine, ","); found && !strings.ContainsAny(firstField, " \t") {
+		return parseCSV(br)
+	}
+	return parseText(br)
+}
+
+// Normalize upper-cases a code and puts the dot after the category, so
+// "a000", "A00.0" and "A00 0" all become "A00.0".
+func Normalize(code string) string {
+	code = strings.ToUpper(strings.Map(func(r rune) rune {
+		if r == '.' || unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, code))
+	if len(code) > 3 {
+		code = code[:3] + "." + code[3:]
+	}
+	return code
+}
+
+// Valid reports whether the normalized code looks like an ICD-10 code.
+func Valid(code string) bool {
+	if len(code) < 3 || len(code) > 8 {
+		return false
+	}
+	if code[0] < 'A' || code[0] > 'Z' || !unicode.IsDigit(rune(code[1])) {
+		return false
+	}
+	for i, r := range code {
+		if i == 3 {
+			if r != '.' {
+				return false
+			}
+			continue
+		}
+		if !unicode.IsDigit(r) && !unicode.IsUpper(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func parseCSV(r io.Reader) ([]Entry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	var entries []Entry
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) < 2 {
+			return nil, fmt.Errorf("line %d: expected code and description", line)
+		}
+		code := Normalize(record[0])
+		if !Valid(code) {
+			if line == 1 {
+				continue // header
+			}
+			return nil, fmt.Errorf("line %d: invalid code %q", line, record[0])
+		}
+		entries = append(entries, Entry{Code: code, Description: strings.TrimSpace(record[1])})
+	}
+}
+
+func parseText(r io.Reader) ([]Entry, error) {
+	scanner := bufio.NewScanner(r)
+
+	var entries []Entry
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: expected code and description", line)
+		}
+		code := Normalize(fields[0])
+		if !Valid(code) {
+			return nil, fmt.Errorf("line %d: invalid code %q", line, fields[0])
+		}
+		description := strings.TrimSpace(strings.TrimPrefix(text, fields[0]))
+		entries = append(entries, Entry{Code: code, Description: description})
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,154 @@
+// Package interactions reads the drug interaction and allergen class tables
+// used for prescribing checks.
+package interactions
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Interaction is a pair of generic drug names that should not, or only with
+// care, be given together.
+type Interaction struct {
+	DrugA       string
+	DrugB       string
+	Severity    string // Minor, Moderate, Severe
+	Description string
+}
+
+// ClassMember puts a generic drug in an allergen class, e.g. amoxicillin in
+// penicillins, so an allergy to one flags the others.
+type ClassMember struct {
+	Class       string
+	GenericName string
+}
+
+// ParseInteractions reads a CSV file with drug_a, drug_b, severity and
+// description columns. A header row is required.
+func ParseInteractions(r io.Reader) ([]Interaction, error) {
+	rows, err := readCSV(r, []string{"drug_a", "drug_b", "severity"}, "description")
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Interaction, 0, len(rows))
+	for _, row := range rows {
+		a, b := NormalizeName(row.get("drug_a")), NormalizeName(row.get("drug_b"))
+		if a == "" || b == "" {
+			return nil, fmt.Errorf("line %d: both drugs are required", row.line)
+		}
+		if a == b {
+			return nil, fmt.Errorf("line %d: a drug cannot interact with itself", row.line)
+		}
+		severity, ok := NormalizeSeverity(row.get("severity"))
+		if !ok {
+			return nil, fmt.Errorf("line %d: unknown severity %q", row.line, row.get("severity"))
+		}
+		if b < a {
+			a, b = b, a
+		}
+		entries = append(entries, Interaction{DrugA: a, DrugB: b, Severity: severity, Description: row.get("description")})
+	}
+	return entries, nil
+}
+
+// ParseClasses reads a CSV file with class and drug columns. A header row
+// is required.
+func ParseClasses(r io.Reader) ([]ClassMember, error) {
+	rows, err := readCSV(r, []string{"class", "drug"})
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]ClassMember, 0, len(rows))
+	for _, row := range rows {
+		class, drug := NormalizeName(row.get("class")), NormalizeName(row.get("drug"))
+		if class == "" || drug == "" {
+			return nil, fmt.Errorf("line %d: class and drug are required", row.line)
+		}
+		entries = append(entries, ClassMember{Class: class, GenericName: drug})
+	}
+	return entries, nil
+}
+
+// NormalizeName lower-cases a drug or class name and collapses whitespace.
+func NormalizeName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// NormalizeSeverity maps the severities found in common datasets onto
+// Minor, Moderate and Severe. Major and contraindicated pairs are Severe.
+func NormalizeSeverity(severity string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(severity)) {
+	case "minor", "low", "mild":
+		return "Minor", true
+	case "moderate", "medium":
+		return "Moderate", true
+	case "major", "severe", "high", "contraindicated":
+		return "Severe", true
+	}
+	return "", false
+}
+
+type row struct {
+	line   int
+	fields map[string]string
+}
+
+func (r row) get(column string) string {
+	return r.fields[column]
+}
+
+// readCSV reads the named columns of every non-empty row, failing when a
+// required column is missing from the header.
+func readCSV(r io.Reader, required []string, optional ...string) ([]row, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	index := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		index[strings.ReplaceAll(name, " ", "_")] = i
+	}
+	for _, column := range required {
+		if _, ok := index[column]; !ok {
+			return nil, fmt.Errorf("header must include a %s column", column)
+		}
+	}
+
+	columns := append(append([]string{}, required...), optional...)
+
+	var rows []row
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+
+		fields := map[string]string{}
+		for _, column := range columns {
+			if i, ok := index[column]; ok && i < len(record) {
+				fields[column] = strings.TrimSpace(record[i])
+			}
+		}
+		rows = append(rows, row{line: line, fields: fields})
+	}
+}
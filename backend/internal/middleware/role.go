@@ -93,3 +93,13 @@ func AdminOrDoctor() gin.HandlerFunc {
 func AdminOrReceptionist() gin.HandlerFunc {
 	return RoleMiddleware("admin", "receptionist")
 }
+
+// AdminOrPharmacist middleware - admin or pharmacist can access
+func AdminOrPharmacist() gin.HandlerFunc {
+	return RoleMiddleware("admin", "pharmacist")
+}
+
+// Staff middleware - admin, doctor or receptionist can access
+func Staff() gin.HandlerFunc {
+	return RoleMiddleware("admin", "doctor", "receptionist")
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Admission is a patient's inpatient stay from admission to discharge. The
+// rooms the patient occupied along the way are its room stays, one per
+// transfer.
+type Admission struct {
+	ID               uint       `gorm:"primaryKey" json:"id"`
+	Reference        string     `gorm:"index" json:"reference"`
+	PatientID        uint       `gorm:"index" json:"patientId"`
+	Patient          Patient    `gorm:"foreignKey:PatientID" json:"patient,omitempty"`
+	DoctorID         uint       `gorm:"index" json:"doctorId"` // admitting doctor
+	Doctor           Doctor     `gorm:"foreignKey:DoctorID" json:"doctor,omitempty"`
+	EncounterID      uint       `gorm:"index" json:"encounterId"` // the Admission encounter documenting the stay
+	Reason           string     `json:"reason"`
+	Status           string     `gorm:"index" json:"status"` // Admitted, Discharged
+	AdmittedAt       time.Time  `json:"admittedAt"`
+	AdmittedBy       uint       `json:"admittedBy"`
+	DischargedAt     *time.Time `json:"dischargedAt,omitempty"`
+	DischargedBy     *uint      `json:"dischargedBy,omitempty"`
+	Disposition      string     `json:"disposition,omitempty"` // Home, Transferred, AgainstMedicalAdvice, Deceased, Other
+	DischargeSummary string     `gorm:"type:text" json:"dischargeSummary,omitempty"`
+	Stays            []RoomStay `gorm:"foreignKey:AdmissionID" json:"stays,omitempty"`
+	LengthOfStay     int        `gorm:"-" json:"lengthOfStay"` // midnights spent admitted so far
+	CreatedAt        time.Time  `json:"createdAt"`
+	UpdatedAt        time.Time  `json:"updatedAt"`
+}
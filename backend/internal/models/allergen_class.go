@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// AllergenClass lists a generic drug as a member of an allergen class such
+// as penicillins, so allergies are checked across the whole class.
+type AllergenClass struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Class       string    `gorm:"uniqueIndex:idx_allergen_class_member" json:"class"`
+	GenericName string    `gorm:"uniqueIndex:idx_allergen_class_member" json:"genericName"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+type Allergy struct {
+	ID                 uint      `gorm:"primaryKey" json:"id"`
+	PatientID          uint      `gorm:"index" json:"patientId"`
+	Substance          string    `json:"substance"`
+	Category           string    `json:"category"` // Drug, Food, Environment, Other
+	Reaction           string    `json:"reaction"`
+	Severity           string    `json:"severity"`           // Mild, Moderate, Severe
+	VerificationStatus string    `json:"verificationStatus"` // Unconfirmed, Confirmed, Refuted
+	Notes              string    `json:"notes"`
+	RecordedBy         uint      `json:"recordedBy"`
+	CreatedAt          time.Time `json:"createdAt"`
+	UpdatedAt          time.Time `json:"updatedAt"`
+}
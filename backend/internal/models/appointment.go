@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 type Appointment struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
@@ -12,6 +15,22 @@ type Appointment struct {
 	Time      string    `json:"time"`   // e.g., "10:00 AM"
 	Status    string    `json:"status"` // Scheduled, Completed, Cancelled
 	Notes     string    `json:"notes"`
+	SeriesID  *uint     `json:"seriesId,omitempty"`
+	Sequence  int       `json:"sequence"` // bumped on every change, used as the iCalendar SEQUENCE
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 }
+
+var appointmentTimeLayouts = []string{"3:04 PM", "3:04PM", "15:04"}
+
+// ScheduledAt combines Date and Time into the local start time of the
+// appointment. It reports false when Time cannot be parsed.
+func (a Appointment) ScheduledAt() (time.Time, bool) {
+	slot := strings.ToUpper(strings.TrimSpace(a.Time))
+	for _, layout := range appointmentTimeLayouts {
+		if t, err := time.Parse(layout, slot); err == nil {
+			return time.Date(a.Date.Year(), a.Date.Month(), a.Date.Day(), t.Hour(), t.Minute(), 0, 0, time.Local), true
+		}
+	}
+	return time.Date(a.Date.Year(), a.Date.Month(), a.Date.Day(), 0, 0, 0, 0, time.Local), false
+}
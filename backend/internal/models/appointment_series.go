@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+type AppointmentSeries struct {
+	ID           uint          `gorm:"primaryKey" json:"id"`
+	PatientID    uint          `json:"patientId"`
+	Patient      Patient       `gorm:"foreignKey:PatientID" json:"patient,omitempty"`
+	DoctorID     uint          `json:"doctorId"`
+	Doctor       Doctor        `gorm:"foreignKey:DoctorID" json:"doctor,omitempty"`
+	Frequency    string        `json:"frequency"` // Daily, Weekly
+	Interval     int           `json:"interval"`  // every N days or weeks
+	ByDay        string        `json:"byDay"`     // e.g., "MO,WE,FR" for weekly series
+	StartDate    time.Time     `json:"startDate"`
+	Until        *time.Time    `json:"until,omitempty"`
+	Count        int           `json:"count,omitempty"`
+	Time         string        `json:"time"`   // e.g., "10:00 AM"
+	Status       string        `json:"status"` // Active, Cancelled
+	Notes        string        `json:"notes"`
+	CreatedAt    time.Time     `json:"createdAt"`
+	UpdatedAt    time.Time     `json:"updatedAt"`
+	Appointments []Appointment `gorm:"foreignKey:SeriesID" json:"appointments,omitempty"`
+}
@@ -1,15 +1,66 @@
 package models
 
-import "time"
+import (
+	"time"
 
+	"github.com/shopspring/decimal"
+)
+
+// Bill is an invoice made up of line items. Its totals are computed from the
+// items by the server and are never taken from client input. Amounts are
+// exact decimals rounded by the rules of the bill's currency. AmountPaid and
+// the balances are derived from the payments ledger and kept in step with it.
+// Insured bills are split into the patient's and the insurer's portion; the
+// insurer's is settled by ledger entries with the Insurance method. Every
+// bill carries a gap-free invoice number, so issued bills are voided rather
+// than deleted.
 type Bill struct {
-	ID          uint       `gorm:"primaryKey" json:"id"`
-	PatientID   uint       `json:"patientId"`
-	Patient     Patient    `gorm:"foreignKey:PatientID" json:"patient,omitempty"`
-	Amount      float64    `json:"amount"`
-	Status      string     `json:"status"` // Paid, Unpaid
-	PaymentDate *time.Time `json:"paymentDate,omitempty"`
-	Description string     `json:"description"`
-	CreatedAt   time.Time  `json:"createdAt"`
-	UpdatedAt   time.Time  `json:"updatedAt"`
+	ID             uint            `gorm:"primaryKey" json:"id"`
+	InvoiceNumber  string          `gorm:"uniqueIndex:idx_bill_invoice_number,where:invoice_number <> ''" json:"invoiceNumber"`
+	FiscalYear     int             `json:"fiscalYear"` // year the fiscal year of the invoice starts in
+	PatientID      uint            `json:"patientId"`
+	Patient        Patient         `gorm:"foreignKey:PatientID" json:"patient,omitempty"`
+	EncounterID    *uint           `json:"encounterId,omitempty"`
+	Currency       string          `gorm:"size:3" json:"currency"` // ISO 4217 code, the default currency when empty
+	Subtotal       decimal.Decimal `gorm:"type:numeric(19,4)" json:"subtotal"`
+	DiscountTotal  decimal.Decimal `gorm:"type:numeric(19,4)" json:"discountTotal"`
+	TaxTotal       decimal.Decimal `gorm:"type:numeric(19,4)" json:"taxTotal"`
+	Amount         decimal.Decimal `gorm:"type:numeric(19,4)" json:"amount"` // Grand total
+	AmountPaid     decimal.Decimal `gorm:"type:numeric(19,4)" json:"amountPaid"`
+	Balance        decimal.Decimal `gorm:"type:numeric(19,4)" json:"balance"`
+	PolicyID       *uint           `json:"policyId,omitempty"` // insurance policy the bill is claimed under
+	PatientAmount  decimal.Decimal `gorm:"type:numeric(19,4)" json:"patientAmount"`
+	PayerAmount    decimal.Decimal `gorm:"type:numeric(19,4)" json:"payerAmount"`
+	PatientBalance decimal.Decimal `gorm:"type:numeric(19,4)" json:"patientBalance"`
+	PayerBalance   decimal.Decimal `gorm:"type:numeric(19,4)" json:"payerBalance"`
+	Status         string          `json:"status"` // Unpaid, PartiallyPaid, Paid, Overdue, WrittenOff, Void
+	DueDate        *time.Time      `json:"dueDate,omitempty"`
+	PaymentDate    *time.Time      `json:"paymentDate,omitempty"` // when the bill was settled in full
+	WriteOffReason string          `json:"writeOffReason,omitempty"`
+	VoidedAt       *time.Time      `json:"voidedAt,omitempty"`
+	VoidReason     string          `json:"voidReason,omitempty"`
+	Description    string          `json:"description"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	UpdatedAt      time.Time       `json:"updatedAt"`
+
+	// Relations
+	Items    []BillItem `gorm:"foreignKey:BillID" json:"items,omitempty"`
+	Payments []Payment  `gorm:"foreignKey:BillID" json:"payments,omitempty"`
+}
+
+// BillItem is one charge on a bill, optionally linked to what it bills for.
+type BillItem struct {
+	ID         uint            `gorm:"primaryKey" json:"id"`
+	BillID     uint            `gorm:"index" json:"billId"`
+	SourceType string          `gorm:"index:idx_bill_item_source" json:"sourceType"` // Appointment, Procedure, RoomStay, Dispense, Other
+	SourceID   *uint           `gorm:"index:idx_bill_item_source" json:"sourceId,omitempty"`
+	ServiceID  *uint           `json:"serviceId,omitempty"` // catalog entry the item was priced from
+	Service    string          `json:"service"`
+	Quantity   decimal.Decimal `gorm:"type:numeric(19,4)" json:"quantity"`
+	UnitPrice  decimal.Decimal `gorm:"type:numeric(19,4)" json:"unitPrice"`
+	TaxRate    decimal.Decimal `gorm:"type:numeric(7,4)" json:"taxRate"`   // Percent, applied after the discount
+	Discount   decimal.Decimal `gorm:"type:numeric(19,4)" json:"discount"` // Amount taken off the line
+	Subtotal   decimal.Decimal `gorm:"type:numeric(19,4)" json:"subtotal"`
+	TaxAmount  decimal.Decimal `gorm:"type:numeric(19,4)" json:"taxAmount"`
+	Total      decimal.Decimal `gorm:"type:numeric(19,4)" json:"total"`
 }
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// CashierSession is a cashier's shift at the cash desk, from counting the
+// opening float into the drawer to counting the drawer at the end. Payments
+// and refunds taken at the desk are attributed to the session they were
+// taken in.
+type CashierSession struct {
+	ID           uint             `gorm:"primaryKey" json:"id"`
+	Reference    string           `gorm:"index" json:"reference"`
+	CashierID    uint             `gorm:"index" json:"cashierId"`
+	Cashier      *User            `gorm:"foreignKey:CashierID" json:"cashier,omitempty"`
+	Currency     string           `gorm:"size:3" json:"currency"` // of the cash in the drawer
+	OpeningFloat decimal.Decimal  `gorm:"type:numeric(19,4)" json:"openingFloat"`
+	Status       string           `gorm:"index" json:"status"` // Open, Closed
+	OpenedAt     time.Time        `json:"openedAt"`
+	ClosedAt     *time.Time       `json:"closedAt,omitempty"`
+	ClosedBy     *uint            `json:"closedBy,omitempty"`
+	ExpectedCash *decimal.Decimal `gorm:"type:numeric(19,4)" json:"expectedCash,omitempty"` // float plus net cash taken
+	CountedCash  *decimal.Decimal `gorm:"type:numeric(19,4)" json:"countedCash,omitempty"`
+	Variance     *decimal.Decimal `gorm:"type:numeric(19,4)" json:"variance,omitempty"` // counted less expected
+	Notes        string           `json:"notes,omitempty"`
+	CreatedAt    time.Time        `json:"createdAt"`
+	UpdatedAt    time.Time        `json:"updatedAt"`
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// CheckoutSession is an online payment page opened with a payment provider
+// for a bill's outstanding balance.
+type CheckoutSession struct {
+	ID                uint            `gorm:"primaryKey" json:"id"`
+	Reference         string          `gorm:"index" json:"reference"`
+	BillID            uint            `gorm:"index" json:"billId"`
+	PatientID         uint            `gorm:"index" json:"patientId"`
+	Provider          string          `gorm:"index:idx_checkout_provider_session" json:"provider"`
+	ProviderSessionID string          `gorm:"index:idx_checkout_provider_session" json:"providerSessionId"`
+	URL               string          `json:"url"`
+	Amount            decimal.Decimal `gorm:"type:numeric(19,4)" json:"amount"`
+	Currency          string          `gorm:"size:3" json:"currency"`
+	Status            string          `json:"status"` // Open, Paid, Failed, Expired
+	ExpiresAt         time.Time       `json:"expiresAt"`
+	TransactionID     string          `json:"transactionId,omitempty"`
+	PaymentID         *uint           `json:"paymentId,omitempty"`                  // ledger entry recorded for it
+	PaidAmount        decimal.Decimal `gorm:"type:numeric(19,4)" json:"paidAmount"` // what the provider settled
+	Exception         string          `json:"exception,omitempty"`                  // why the payment needs follow-up, e.g. a refund
+	PaidAt            *time.Time      `json:"paidAt,omitempty"`
+	CreatedBy         uint            `json:"createdBy"`
+	CreatedAt         time.Time       `json:"createdAt"`
+	UpdatedAt         time.Time       `json:"updatedAt"`
+}
+
+// GatewayEvent is a webhook received from a payment provider. Events are
+// stored once, so a redelivered webhook is not applied twice.
+type GatewayEvent struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	Provider   string    `gorm:"uniqueIndex:idx_gateway_event" json:"provider"`
+	EventID    string    `gorm:"uniqueIndex:idx_gateway_event" json:"eventId"`
+	Type       string    `json:"type"`
+	SessionID  string    `json:"sessionId"`
+	Payload    string    `gorm:"type:text" json:"payload"`
+	Error      string    `json:"error,omitempty"`
+	ReceivedAt time.Time `json:"receivedAt"`
+}
+
+// GatewayReconciliation is one run comparing the provider's settled
+// transactions with the payments recorded for them.
+type GatewayReconciliation struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	Provider     string    `json:"provider"`
+	From         time.Time `json:"from"`
+	To           time.Time `json:"to"`
+	Transactions int       `json:"transactions"`
+	Matched      int       `json:"matched"`
+	Recovered    int       `json:"recovered"` // paid at the provider but missed by webhooks
+	Mismatched   int       `json:"mismatched"`
+	Expired      int       `json:"expired"` // open sessions closed as expired
+	Notes        string    `gorm:"type:text" json:"notes,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"clinic-backend/internal/money"
+
+	"github.com/shopspring/decimal"
+)
+
+// Currency is an ISO 4217 currency bills can be issued in, with the rule used
+// to round its amounts.
+type Currency struct {
+	ID                uint            `gorm:"primaryKey" json:"id"`
+	Code              string          `gorm:"size:3;uniqueIndex" json:"code"` // e.g. USD
+	Name              string          `json:"name"`
+	Symbol            string          `json:"symbol,omitempty"`
+	Decimals          int32           `json:"decimals"`
+	RoundingMode      string          `json:"roundingMode"` // HalfUp, HalfEven, Down, Up
+	RoundingIncrement decimal.Decimal `gorm:"type:numeric(19,4);default:0" json:"roundingIncrement"`
+	IsDefault         bool            `json:"isDefault"`
+	CreatedAt         time.Time       `json:"createdAt"`
+	UpdatedAt         time.Time       `json:"updatedAt"`
+}
+
+// Rule returns the rounding rule for amounts in the currency.
+func (c Currency) Rule() money.Rule {
+	return money.Rule{Decimals: c.Decimals, Mode: c.RoundingMode, Increment: c.RoundingIncrement}
+}
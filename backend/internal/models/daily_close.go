@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// DailyClose is the end-of-day close of the payments ledger. Once a day is
+// closed no ledger entries can be dated on it.
+type DailyClose struct {
+	ID        uint             `gorm:"primaryKey" json:"id"`
+	Date      time.Time        `gorm:"uniqueIndex" json:"date"` // midnight of the closed day
+	Payments  int              `json:"payments"`                // ledger entries of the day
+	Sessions  int              `json:"sessions"`                // cashier sessions opened that day
+	Lines     []DailyCloseLine `json:"lines,omitempty"`
+	Notes     string           `json:"notes,omitempty"`
+	ClosedBy  uint             `json:"closedBy"`
+	CreatedAt time.Time        `json:"createdAt"`
+}
+
+// DailyCloseLine totals the day's ledger entries of one method and currency.
+type DailyCloseLine struct {
+	ID           uint            `gorm:"primaryKey" json:"id"`
+	DailyCloseID uint            `gorm:"index" json:"dailyCloseId"`
+	Method       string          `json:"method"`
+	Currency     string          `gorm:"size:3" json:"currency"`
+	Entries      int             `json:"entries"`
+	Received     decimal.Decimal `gorm:"type:numeric(19,4)" json:"received"` // payments and adjustments
+	Returned     decimal.Decimal `gorm:"type:numeric(19,4)" json:"returned"` // refunds and reversals
+	Net          decimal.Decimal `gorm:"type:numeric(19,4)" json:"net"`
+}
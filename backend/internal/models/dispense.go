@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Dispense is one handover of medication against a prescription. A
+// prescription may be dispensed over several visits.
+type Dispense struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	PrescriptionID uint      `gorm:"index" json:"prescriptionId"`
+	PatientID      uint      `json:"patientId"`
+	DispensedBy    uint      `json:"dispensedBy"`
+	DispensedAt    time.Time `json:"dispensedAt"`
+	BillID         *uint     `json:"billId,omitempty"`
+	Notes          string    `json:"notes,omitempty"`
+	CreatedAt      time.Time `json:"createdAt"`
+
+	// Relations
+	Items []DispenseItem `gorm:"foreignKey:DispenseID" json:"items,omitempty"`
+}
+
+// DispenseItem is the quantity of one prescription item taken from one batch.
+type DispenseItem struct {
+	ID                 uint            `gorm:"primaryKey" json:"id"`
+	DispenseID         uint            `gorm:"index" json:"dispenseId"`
+	PrescriptionItemID uint            `json:"prescriptionItemId"`
+	DrugID             uint            `json:"drugId"`
+	StockBatchID       uint            `json:"stockBatchId"`
+	BatchNumber        string          `json:"batchNumber"`
+	Quantity           float64         `json:"quantity"`
+	UnitPrice          decimal.Decimal `gorm:"type:numeric(19,4)" json:"unitPrice"`
+	Amount             decimal.Decimal `gorm:"type:numeric(19,4)" json:"amount"`
+}
@@ -3,14 +3,18 @@ package models
 import "time"
 
 type Doctor struct {
-	ID             uint      `gorm:"primaryKey" json:"id"`
-	Name           string    `json:"name"`
-	Email          string    `gorm:"unique" json:"email"`
-	Phone          string    `json:"phone"`
-	Specialization string    `json:"specialization"`
-	Availability   string    `json:"availability"` // e.g., "Monday-Friday, 9AM-5PM"
-	CreatedAt      time.Time `json:"createdAt"`
-	UpdatedAt      time.Time `json:"updatedAt"`
+	ID                 uint      `gorm:"primaryKey" json:"id"`
+	Name               string    `json:"name"`
+	Email              string    `gorm:"unique" json:"email"`
+	Phone              string    `json:"phone"`
+	Specialization     string    `json:"specialization"`
+	Availability       string    `json:"availability"`             // e.g., "Monday-Friday, 9AM-5PM"
+	CalendarToken      string    `gorm:"index" json:"-"`           // secret for the iCalendar subscription feed
+	Qualifications     string    `json:"qualifications,omitempty"` // e.g. "MBBS, MD"
+	RegistrationNumber string    `json:"registrationNumber,omitempty"`
+	SignaturePath      string    `json:"-"` // scanned signature printed on documents
+	CreatedAt          time.Time `json:"createdAt"`
+	UpdatedAt          time.Time `json:"updatedAt"`
 
 	// Relations
 	Appointments   []Appointment   `gorm:"foreignKey:DoctorID" json:"appointments,omitempty"`
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Drug is a formulary entry. A product is identified by its generic name,
+// strength, form and route.
+type Drug struct {
+	ID           uint            `gorm:"primaryKey" json:"id"`
+	Code         string          `gorm:"index" json:"code,omitempty"`
+	GenericName  string          `gorm:"uniqueIndex:idx_drug_product" json:"genericName"`
+	BrandName    string          `json:"brandName,omitempty"`
+	Strength     string          `gorm:"uniqueIndex:idx_drug_product" json:"strength"` // e.g. "500 mg", "250 mg/5 ml"
+	Form         string          `gorm:"uniqueIndex:idx_drug_product" json:"form"`     // e.g. Tablet, Capsule, Syrup, Injection
+	Route        string          `gorm:"uniqueIndex:idx_drug_product" json:"route"`    // e.g. Oral, IV, IM, Topical
+	UnitPrice    decimal.Decimal `gorm:"type:numeric(19,4)" json:"unitPrice"`          // price per dispensed unit
+	ReorderLevel float64         `json:"reorderLevel"`                                 // stock at or below this is reported as low
+	Discontinued bool            `json:"discontinued"`
+	CreatedAt    time.Time       `json:"createdAt"`
+	UpdatedAt    time.Time       `json:"updatedAt"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// DrugInteraction is a known interaction between two generic drugs. Names are
+// stored lower-cased with DrugA sorting before DrugB.
+type DrugInteraction struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	DrugA       string    `gorm:"uniqueIndex:idx_drug_interaction_pair" json:"drugA"`
+	DrugB       string    `gorm:"uniqueIndex:idx_drug_interaction_pair" json:"drugB"`
+	Severity    string    `json:"severity"` // Minor, Moderate, Severe
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
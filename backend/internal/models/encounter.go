@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+type Encounter struct {
+	ID             uint         `gorm:"primaryKey" json:"id"`
+	Type           string       `json:"type"`   // Outpatient, Admission, Emergency
+	Status         string       `json:"status"` // Open, Closed
+	PatientID      uint         `json:"patientId"`
+	Patient        Patient      `gorm:"foreignKey:PatientID" json:"patient,omitempty"`
+	DoctorID       uint         `json:"doctorId"` // attending doctor
+	Doctor         Doctor       `gorm:"foreignKey:DoctorID" json:"doctor,omitempty"`
+	AppointmentID  *uint        `gorm:"uniqueIndex" json:"appointmentId,omitempty"`
+	Appointment    *Appointment `gorm:"foreignKey:AppointmentID" json:"appointment,omitempty"`
+	ChiefComplaint string       `json:"chiefComplaint"`
+	Vitals         string       `json:"vitals,omitempty"` // free text from before vital signs were recorded, read only
+	Notes          string       `json:"notes"`
+	StartedAt      time.Time    `json:"startedAt"`
+	EndedAt        *time.Time   `json:"endedAt,omitempty"`
+	CreatedAt      time.Time    `json:"createdAt"`
+	UpdatedAt      time.Time    `json:"updatedAt"`
+
+	// Relations
+	MedicalRecords []MedicalRecord   `gorm:"foreignKey:EncounterID" json:"medicalRecords,omitempty"`
+	Prescriptions  []Prescription    `gorm:"foreignKey:EncounterID" json:"prescriptions,omitempty"`
+	Bills          []Bill            `gorm:"foreignKey:EncounterID" json:"bills,omitempty"`
+	VitalSigns     []VitalSign       `gorm:"foreignKey:EncounterID" json:"vitalSigns,omitempty"`
+	Diagnoses      []RecordDiagnosis `gorm:"-" json:"diagnoses,omitempty"` // coded diagnoses of the encounter's records
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+type ICD10Code struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Code        string    `gorm:"uniqueIndex" json:"code"` // e.g., "E11.9"
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
@@ -0,0 +1,125 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Insurer is an insurance company bills are claimed from.
+type Insurer struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Code      string    `gorm:"uniqueIndex" json:"code"`
+	Name      string    `json:"name"`
+	PayerID   string    `json:"payerId,omitempty"` // identifier used on electronic claims
+	Phone     string    `json:"phone,omitempty"`
+	Email     string    `json:"email,omitempty"`
+	Address   string    `json:"address,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	// Relations
+	Plans []InsurancePlan `gorm:"foreignKey:InsurerID" json:"plans,omitempty"`
+}
+
+// InsurancePlan holds the coverage rules of an insurer's product. The patient
+// pays the copay, then the coinsurance percentage of the rest; the insurer
+// pays the remainder up to the plan's limits. Zero limits mean no limit.
+type InsurancePlan struct {
+	ID           uint            `gorm:"primaryKey" json:"id"`
+	InsurerID    uint            `gorm:"index" json:"insurerId"`
+	Insurer      *Insurer        `gorm:"foreignKey:InsurerID" json:"insurer,omitempty"`
+	Name         string          `json:"name"`
+	Copay        decimal.Decimal `gorm:"type:numeric(19,4)" json:"copay"`        // per bill
+	Coinsurance  decimal.Decimal `gorm:"type:numeric(7,4)" json:"coinsurance"`   // percent paid by the patient
+	PerBillLimit decimal.Decimal `gorm:"type:numeric(19,4)" json:"perBillLimit"` // most the insurer pays on one bill
+	AnnualLimit  decimal.Decimal `gorm:"type:numeric(19,4)" json:"annualLimit"`  // most the insurer pays per policy per calendar year
+	Currency     string          `gorm:"size:3" json:"currency"`
+	Discontinued bool            `json:"discontinued"`
+	CreatedAt    time.Time       `json:"createdAt"`
+	UpdatedAt    time.Time       `json:"updatedAt"`
+}
+
+// PatientPolicy is a patient's membership of an insurance plan.
+type PatientPolicy struct {
+	ID           uint           `gorm:"primaryKey" json:"id"`
+	PatientID    uint           `gorm:"index" json:"patientId"`
+	PlanID       uint           `gorm:"index" json:"planId"`
+	Plan         *InsurancePlan `gorm:"foreignKey:PlanID" json:"plan,omitempty"`
+	PolicyNumber string         `json:"policyNumber"`
+	HolderName   string         `json:"holderName,omitempty"`
+	Relationship string         `json:"relationship"` // Self, Spouse, Child, Other
+	ValidFrom    time.Time      `json:"validFrom"`
+	ValidTo      *time.Time     `json:"validTo,omitempty"`
+	Status       string         `json:"status"` // Active, Suspended, Cancelled
+	CreatedAt    time.Time      `json:"createdAt"`
+	UpdatedAt    time.Time      `json:"updatedAt"`
+}
+
+// Claim asks the insurer to pay its portion of one bill. A bill has one open
+// claim at a time; what a denied or partially approved claim left uncovered
+// can be claimed again on a new one.
+type Claim struct {
+	ID               uint            `gorm:"primaryKey" json:"id"`
+	ClaimNumber      string          `gorm:"index" json:"claimNumber"`
+	BillID           uint            `gorm:"uniqueIndex:idx_claim_open_bill,where:status = 'Pending' OR status = 'Submitted'" json:"billId"`
+	Bill             *Bill           `gorm:"foreignKey:BillID" json:"bill,omitempty"`
+	PatientID        uint            `gorm:"index" json:"patientId"`
+	PolicyID         uint            `json:"policyId"`
+	Policy           *PatientPolicy  `gorm:"foreignKey:PolicyID" json:"policy,omitempty"`
+	InsurerID        uint            `gorm:"index" json:"insurerId"`
+	BatchID          *uint           `gorm:"index" json:"batchId,omitempty"`
+	Currency         string          `gorm:"size:3" json:"currency"`
+	ClaimedAmount    decimal.Decimal `gorm:"type:numeric(19,4)" json:"claimedAmount"`
+	ApprovedAmount   decimal.Decimal `gorm:"type:numeric(19,4)" json:"approvedAmount"`
+	AdjustmentAmount decimal.Decimal `gorm:"type:numeric(19,4)" json:"adjustmentAmount"` // written off under the insurer contract
+	PaidAmount       decimal.Decimal `gorm:"type:numeric(19,4)" json:"paidAmount"`
+	Status           string          `json:"status"` // Pending, Submitted, Approved, PartiallyApproved, Denied, Paid
+	DenialReason     string          `json:"denialReason,omitempty"`
+	SubmittedAt      *time.Time      `json:"submittedAt,omitempty"`
+	AdjudicatedAt    *time.Time      `json:"adjudicatedAt,omitempty"`
+	CreatedAt        time.Time       `json:"createdAt"`
+	UpdatedAt        time.Time       `json:"updatedAt"`
+}
+
+// ClaimBatch groups the claims sent to an insurer in one submission.
+type ClaimBatch struct {
+	ID          uint            `gorm:"primaryKey" json:"id"`
+	BatchNumber string          `gorm:"index" json:"batchNumber"`
+	InsurerID   uint            `gorm:"index" json:"insurerId"`
+	Insurer     *Insurer        `gorm:"foreignKey:InsurerID" json:"insurer,omitempty"`
+	ClaimCount  int             `json:"claimCount"`
+	TotalAmount decimal.Decimal `gorm:"type:numeric(19,4)" json:"totalAmount"`
+	Currency    string          `gorm:"size:3" json:"currency"`
+	CreatedBy   uint            `json:"createdBy"`
+	SubmittedAt time.Time       `json:"submittedAt"`
+	CreatedAt   time.Time       `json:"createdAt"`
+
+	// Relations
+	Claims []Claim `gorm:"foreignKey:BatchID" json:"claims,omitempty"`
+}
+
+// Remittance is a payment received from an insurer, settling one or more
+// claims.
+type Remittance struct {
+	ID         uint            `gorm:"primaryKey" json:"id"`
+	InsurerID  uint            `gorm:"index" json:"insurerId"`
+	Reference  string          `json:"reference"` // e.g. the insurer's payment advice number
+	Amount     decimal.Decimal `gorm:"type:numeric(19,4)" json:"amount"`
+	Currency   string          `gorm:"size:3" json:"currency"`
+	ReceivedAt time.Time       `json:"receivedAt"`
+	RecordedBy uint            `json:"recordedBy"`
+	CreatedAt  time.Time       `json:"createdAt"`
+
+	// Relations
+	Lines []RemittanceLine `gorm:"foreignKey:RemittanceID" json:"lines,omitempty"`
+}
+
+// RemittanceLine is the part of a remittance paid against one claim.
+type RemittanceLine struct {
+	ID           uint            `gorm:"primaryKey" json:"id"`
+	RemittanceID uint            `gorm:"index" json:"remittanceId"`
+	ClaimID      uint            `gorm:"index" json:"claimId"`
+	PaymentID    uint            `json:"paymentId"` // ledger entry on the claim's bill
+	Amount       decimal.Decimal `gorm:"type:numeric(19,4)" json:"amount"`
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// InvoiceSequence is the last invoice number issued in a fiscal year. Its row
+// is locked while a number is taken, so numbers are issued without gaps.
+type InvoiceSequence struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	FiscalYear int       `gorm:"uniqueIndex" json:"fiscalYear"`
+	LastNumber int       `json:"lastNumber"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Letterhead is a hospital letterhead template used on printed documents.
+type Letterhead struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Name        string    `json:"name"` // template name, e.g. "Main campus"
+	Hospital    string    `json:"hospital"`
+	Tagline     string    `json:"tagline,omitempty"`
+	Address     string    `json:"address"`
+	Phone       string    `json:"phone"`
+	Email       string    `json:"email"`
+	Website     string    `json:"website,omitempty"`
+	TaxNumber   string    `json:"taxNumber,omitempty"` // printed on invoices and receipts
+	LogoPath    string    `json:"logoPath,omitempty"`  // PNG or JPEG on the server
+	Footer      string    `json:"footer,omitempty"`
+	AccentColor string    `json:"accentColor,omitempty"` // hex, e.g. "#1f4e79"
+	IsDefault   bool      `json:"isDefault"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
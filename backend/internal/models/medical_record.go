@@ -3,15 +3,26 @@ package models
 import "time"
 
 type MedicalRecord struct {
-	ID           uint      `gorm:"primaryKey" json:"id"`
-	PatientID    uint      `json:"patientId"`
-	Patient      Patient   `gorm:"foreignKey:PatientID" json:"patient,omitempty"`
-	DoctorID     uint      `json:"doctorId"`
-	Doctor       Doctor    `gorm:"foreignKey:DoctorID" json:"doctor,omitempty"`
-	Diagnosis    string    `json:"diagnosis"`
-	Prescription string    `json:"prescription"`
-	Notes        string    `json:"notes"`
-	Date         time.Time `json:"date"`
-	CreatedAt    time.Time `json:"createdAt"`
-	UpdatedAt    time.Time `json:"updatedAt"`
+	ID           uint        `gorm:"primaryKey" json:"id"`
+	PatientID    uint        `json:"patientId"`
+	Patient      Patient     `gorm:"foreignKey:PatientID" json:"patient,omitempty"`
+	DoctorID     uint        `json:"doctorId"`
+	Doctor       Doctor      `gorm:"foreignKey:DoctorID" json:"doctor,omitempty"`
+	EncounterID  *uint       `json:"encounterId,omitempty"`
+	Diagnosis    string      `json:"diagnosis"`
+	Prescription string      `json:"prescription"`
+	Notes        string      `json:"notes"`                // plain text, rendered from Content for structured notes
+	TemplateID   *uint       `json:"templateId,omitempty"` // note template Content follows
+	Content      NoteContent `gorm:"type:text" json:"content,omitempty"`
+	Date         time.Time   `json:"date"`
+	Status       string      `gorm:"default:Draft" json:"status"` // Draft, Signed, Amended
+	Version      int         `gorm:"default:1" json:"version"`    // latest MedicalRecordVersion
+	SignedBy     *uint       `json:"signedBy,omitempty"`
+	SignedAt     *time.Time  `json:"signedAt,omitempty"`
+	CreatedAt    time.Time   `json:"createdAt"`
+	UpdatedAt    time.Time   `json:"updatedAt"`
+
+	// Relations
+	Diagnoses []RecordDiagnosis       `gorm:"foreignKey:MedicalRecordID" json:"diagnoses,omitempty"`
+	Addenda   []MedicalRecordAddendum `gorm:"foreignKey:MedicalRecordID" json:"addenda,omitempty"`
 }
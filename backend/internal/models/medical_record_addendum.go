@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+type MedicalRecordAddendum struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	MedicalRecordID uint      `gorm:"index" json:"medicalRecordId"`
+	AuthorID        uint      `json:"authorId"`
+	Reason          string    `json:"reason"`
+	Content         string    `json:"content"`
+	CreatedAt       time.Time `json:"createdAt"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// MedicalRecordVersion is an immutable snapshot of a record's content,
+// written every time the content changes.
+type MedicalRecordVersion struct {
+	ID              uint        `gorm:"primaryKey" json:"id"`
+	MedicalRecordID uint        `gorm:"uniqueIndex:idx_record_version" json:"medicalRecordId"`
+	Version         int         `gorm:"uniqueIndex:idx_record_version" json:"version"`
+	Diagnosis       string      `json:"diagnosis"`
+	Prescription    string      `json:"prescription"`
+	Notes           string      `json:"notes"`
+	Content         NoteContent `gorm:"type:text" json:"content,omitempty"`
+	Date            time.Time   `json:"date"`
+	AuthorID        uint        `json:"authorId"` // user who made the change
+	Reason          string      `json:"reason"`
+	CreatedAt       time.Time   `json:"createdAt"`
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// NoteTemplate describes the sections of a structured clinical note such as
+// a SOAP note or a discharge summary.
+type NoteTemplate struct {
+	ID             uint         `gorm:"primaryKey" json:"id"`
+	Name           string       `json:"name"`
+	Kind           string       `gorm:"index" json:"kind"`           // SOAP, DischargeSummary, OperativeNote, ReferralLetter
+	Specialization string       `gorm:"index" json:"specialization"` // empty for any specialization
+	IsDefault      bool         `json:"isDefault"`                   // default for its kind and specialization
+	Sections       NoteSections `gorm:"type:text" json:"sections"`
+	CreatedAt      time.Time    `json:"createdAt"`
+	UpdatedAt      time.Time    `json:"updatedAt"`
+}
+
+type NoteSection struct {
+	Key      string `json:"key"`
+	Label    string `json:"label"`
+	Type     string `json:"type"` // text, list, number, date
+	Required bool   `json:"required"`
+}
+
+// NoteSections is stored as a JSON array.
+type NoteSections []NoteSection
+
+func (s NoteSections) Value() (driver.Value, error) {
+	if s == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal(s)
+	return string(b), err
+}
+
+func (s *NoteSections) Scan(value interface{}) error {
+	return scanJSON(value, s)
+}
+
+// NoteContent holds the values of a structured note keyed by section, stored
+// as a JSON object.
+type NoteContent map[string]interface{}
+
+func (n NoteContent) Value() (driver.Value, error) {
+	if n == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(n)
+	return string(b), err
+}
+
+func (n *NoteContent) Scan(value interface{}) error {
+	return scanJSON(value, n)
+}
+
+func scanJSON(value interface{}, dest interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		if len(v) == 0 {
+			return nil
+		}
+		return json.Unmarshal(v, dest)
+	case string:
+		if v == "" {
+			return nil
+		}
+		return json.Unmarshal([]byte(v), dest)
+	default:
+		return fmt.Errorf("cannot scan %T into %T", value, dest)
+	}
+}
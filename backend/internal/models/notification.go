@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+type Notification struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	AppointmentID uint       `gorm:"index" json:"appointmentId"`
+	PatientID     uint       `json:"patientId"`
+	Kind          string     `json:"kind"`    // Confirmation, Cancellation, Reminder
+	Channel       string     `json:"channel"` // email, sms, webhook, log
+	Email         string     `json:"email,omitempty"`
+	Phone         string     `json:"phone,omitempty"`
+	Subject       string     `json:"subject"`
+	Body          string     `json:"body"`
+	Status        string     `json:"status"` // Pending, Sent, Failed, Skipped
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"lastError,omitempty"`
+	ScheduledFor  time.Time  `json:"scheduledFor"`
+	AppointmentAt *time.Time `json:"appointmentAt,omitempty"` // appointment start the message was written for
+	NextAttemptAt time.Time  `gorm:"index" json:"nextAttemptAt"`
+	SentAt        *time.Time `json:"sentAt,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+type NotificationPreference struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	PatientID       uint      `gorm:"uniqueIndex" json:"patientId"`
+	OptOutAll       bool      `json:"optOutAll"`
+	OptOutReminders bool      `json:"optOutReminders"`
+	OptOutChannels  string    `json:"optOutChannels"` // e.g., "sms,email"
+	CreatedAt       time.Time `json:"createdAt"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+}
@@ -17,4 +17,6 @@ type Patient struct {
 	MedicalRecords []MedicalRecord `gorm:"foreignKey:PatientID" json:"medicalRecords,omitempty"`
 	Prescriptions  []Prescription  `gorm:"foreignKey:PatientID" json:"prescriptions,omitempty"`
 	Bills          []Bill          `gorm:"foreignKey:PatientID" json:"bills,omitempty"`
+	Allergies      []Allergy       `gorm:"foreignKey:PatientID" json:"allergies,omitempty"`
+	Problems       []Problem       `gorm:"foreignKey:PatientID" json:"problems,omitempty"`
 }
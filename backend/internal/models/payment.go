@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Payment is an entry in a bill's payments ledger. Payments and insurer
+// adjustments are positive; refunds and reversals are negative, so a bill's
+// paid amount is the plain sum of its entries. Entries are never edited or
+// deleted.
+type Payment struct {
+	ID            uint            `gorm:"primaryKey" json:"id"`
+	BillID        uint            `gorm:"index" json:"billId"`
+	PatientID     uint            `gorm:"index" json:"patientId"`
+	Kind          string          `json:"kind"`   // Payment, Refund, Reversal, Adjustment
+	Method        string          `json:"method"` // Cash, Card, MobileMoney, Insurance, Online, Other
+	Amount        decimal.Decimal `gorm:"type:numeric(19,4)" json:"amount"`
+	Currency      string          `gorm:"size:3" json:"currency"`
+	Reference     string          `json:"reference,omitempty"`              // card authorisation, mobile money transaction, claim number
+	RelatedID     *uint           `gorm:"index" json:"relatedId,omitempty"` // payment a refund or reversal applies to
+	Reason        string          `json:"reason,omitempty"`
+	ReceiptNumber string          `gorm:"index" json:"receiptNumber"`
+	BalanceAfter  decimal.Decimal `gorm:"type:numeric(19,4)" json:"balanceAfter"`
+	ReceivedBy    uint            `json:"receivedBy"`
+	SessionID     *uint           `gorm:"index" json:"sessionId,omitempty"` // cashier session it was taken in
+	ReceivedAt    time.Time       `gorm:"index" json:"receivedAt"`
+	CreatedAt     time.Time       `json:"createdAt"`
+}
@@ -3,15 +3,23 @@ package models
 import "time"
 
 type Prescription struct {
-	ID           uint      `gorm:"primaryKey" json:"id"`
-	PatientID    uint      `json:"patientId"`
-	Patient      Patient   `gorm:"foreignKey:PatientID" json:"patient,omitempty"`
-	DoctorID     uint      `json:"doctorId"`
-	Doctor       Doctor    `gorm:"foreignKey:DoctorID" json:"doctor,omitempty"`
-	MedicineName string    `json:"medicineName"`
-	Dosage       string    `json:"dosage"`
-	Instructions string    `json:"instructions"`
-	Date         time.Time `json:"date"`
-	CreatedAt    time.Time `json:"createdAt"`
-	UpdatedAt    time.Time `json:"updatedAt"`
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	PatientID      uint      `json:"patientId"`
+	Patient        Patient   `gorm:"foreignKey:PatientID" json:"patient,omitempty"`
+	DoctorID       uint      `json:"doctorId"`
+	Doctor         Doctor    `gorm:"foreignKey:DoctorID" json:"doctor,omitempty"`
+	EncounterID    *uint     `json:"encounterId,omitempty"`
+	MedicineName   string    `json:"medicineName"` // summary of the items for existing consumers
+	Dosage         string    `json:"dosage"`       // summary of the items for existing consumers
+	Instructions   string    `json:"instructions"`
+	OverrideReason string    `json:"overrideReason,omitempty"` // why severe alerts were overridden
+	OverriddenBy   *uint     `json:"overriddenBy,omitempty"`
+	Status         string    `gorm:"default:Pending" json:"status"` // Pending, PartiallyDispensed, Dispensed; Legacy for free-text prescriptions
+	Date           time.Time `json:"date"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+
+	// Relations
+	Items  []PrescriptionItem  `gorm:"foreignKey:PrescriptionID" json:"items,omitempty"`
+	Alerts []PrescriptionAlert `gorm:"foreignKey:PrescriptionID" json:"alerts,omitempty"`
 }
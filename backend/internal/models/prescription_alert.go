@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// PrescriptionAlert records a warning raised when the prescription was
+// written, kept for audit together with any override.
+type PrescriptionAlert struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	PrescriptionID uint      `gorm:"index" json:"prescriptionId"`
+	Type           string    `json:"type"`     // DrugAllergy, DrugDrug, Duplicate
+	Severity       string    `json:"severity"` // Minor, Moderate, Severe
+	DrugID         uint      `json:"drugId"`
+	Drug           string    `json:"drug"`
+	Interacting    string    `json:"interacting"` // allergen or other drug
+	Message        string    `json:"message"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// PrescriptionItem is one drug ordered on a prescription.
+type PrescriptionItem struct {
+	ID                uint      `gorm:"primaryKey" json:"id"`
+	PrescriptionID    uint      `gorm:"index" json:"prescriptionId"`
+	DrugID            uint      `json:"drugId"`
+	Drug              Drug      `gorm:"foreignKey:DrugID" json:"drug,omitempty"`
+	Dose              float64   `json:"dose"`
+	DoseUnit          string    `json:"doseUnit"`  // e.g. mg, ml, tablet
+	Frequency         string    `json:"frequency"` // e.g. OD, BID, TID, Q8H, PRN
+	Route             string    `json:"route"`
+	DurationDays      int       `json:"durationDays"`
+	Quantity          float64   `json:"quantity"` // amount to dispense
+	DispensedQuantity float64   `json:"dispensedQuantity"`
+	Instructions      string    `json:"instructions,omitempty"`
+	CreatedAt         time.Time `json:"createdAt"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Problem is an entry on a patient's problem list, such as a chronic condition.
+type Problem struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	PatientID   uint       `gorm:"index" json:"patientId"`
+	Code        string     `json:"code,omitempty"` // optional ICD-10 code
+	Description string     `json:"description"`
+	Status      string     `json:"status"` // Active, Inactive, Resolved
+	OnsetDate   *time.Time `json:"onsetDate,omitempty"`
+	ResolvedAt  *time.Time `json:"resolvedAt,omitempty"`
+	Notes       string     `json:"notes"`
+	RecordedBy  uint       `json:"recordedBy"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+}
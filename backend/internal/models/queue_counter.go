@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// QueueCounter is the last token number issued in a queue on a day. Its row
+// is locked while a number is taken, so no two tokens share a number.
+type QueueCounter struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	Scope      string    `gorm:"uniqueIndex:idx_queue_counter" json:"scope"` // "doctor:<id>" or "department:<name>"
+	QueueDate  time.Time `gorm:"uniqueIndex:idx_queue_counter" json:"queueDate"`
+	LastNumber int       `json:"lastNumber"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+type QueueToken struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	Number      int        `json:"number"`
+	QueueDate   time.Time  `gorm:"index" json:"queueDate"` // numbering restarts every day
+	DoctorID    *uint      `json:"doctorId,omitempty"`
+	Doctor      *Doctor    `gorm:"foreignKey:DoctorID" json:"doctor,omitempty"`
+	Department  string     `json:"department"`
+	PatientID   *uint      `json:"patientId,omitempty"`
+	Patient     *Patient   `gorm:"foreignKey:PatientID" json:"patient,omitempty"`
+	PatientName string     `json:"patientName,omitempty"` // walk-ins without a patient record
+	Status      string     `json:"status"`                // Waiting, Called, Served, Skipped
+	IssuedAt    time.Time  `json:"issuedAt"`
+	CalledAt    *time.Time `json:"calledAt,omitempty"`
+	ServedAt    *time.Time `json:"servedAt,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+type RecordDiagnosis struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	MedicalRecordID uint      `gorm:"index" json:"medicalRecordId"`
+	Code            string    `gorm:"index" json:"code"` // ICD-10 code, e.g., "E11.9"
+	Description     string    `json:"description"`
+	Primary         bool      `gorm:"column:is_primary" json:"primary"`
+	Status          string    `json:"status"` // Provisional, Confirmed, RuledOut
+	Notes           string    `json:"notes"`
+	CreatedAt       time.Time `json:"createdAt"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+}
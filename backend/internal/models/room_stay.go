@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// RoomStay is a patient's occupancy of a room. Each midnight passed in the
+// room is charged as a day at the rate for the room's type. Stays of an
+// admitted patient belong to the admission; moving the patient to another
+// room ends one stay and starts the next.
+type RoomStay struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	RoomID      uint       `gorm:"index" json:"roomId"`
+	Room        Room       `gorm:"foreignKey:RoomID" json:"room,omitempty"`
+	PatientID   uint       `gorm:"index" json:"patientId"`
+	AdmissionID *uint      `gorm:"index" json:"admissionId,omitempty"`
+	Reason      string     `json:"reason,omitempty"` // why the patient moved into the room
+	StartedAt   time.Time  `json:"startedAt"`
+	EndedAt     *time.Time `json:"endedAt,omitempty"`
+	ChargedDays int        `json:"chargedDays"`
+	BillID      *uint      `json:"billId,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Service is a billable entry in the service catalog. Consultations are
+// priced per doctor specialization and room rates per room type, per day.
+type Service struct {
+	ID             uint            `gorm:"primaryKey" json:"id"`
+	Code           string          `gorm:"uniqueIndex" json:"code"`
+	Name           string          `json:"name"`
+	Category       string          `gorm:"index" json:"category"`            // Consultation, Procedure, LabTest, RoomRate, Other
+	Specialization string          `json:"specialization,omitempty"`         // Consultation fees; empty applies to every specialization
+	RoomType       string          `json:"roomType,omitempty"`               // Room rates, matching Room.Type
+	Unit           string          `json:"unit"`                             // e.g. visit, test, day
+	TaxRate        decimal.Decimal `gorm:"type:numeric(7,4)" json:"taxRate"` // percent
+	Discontinued   bool            `json:"discontinued"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	UpdatedAt      time.Time       `json:"updatedAt"`
+
+	// Relations
+	Prices []ServicePrice `gorm:"foreignKey:ServiceID" json:"prices,omitempty"`
+}
+
+// ServicePrice is a service's price from a date on. The price in force on a
+// day is the one with the latest EffectiveFrom not after it.
+type ServicePrice struct {
+	ID            uint            `gorm:"primaryKey" json:"id"`
+	ServiceID     uint            `gorm:"uniqueIndex:idx_service_price" json:"serviceId"`
+	Currency      string          `gorm:"size:3;uniqueIndex:idx_service_price" json:"currency"`
+	EffectiveFrom time.Time       `gorm:"uniqueIndex:idx_service_price" json:"effectiveFrom"`
+	Amount        decimal.Decimal `gorm:"type:numeric(19,4)" json:"amount"`
+	CreatedBy     uint            `json:"createdBy"`
+	CreatedAt     time.Time       `json:"createdAt"`
+}
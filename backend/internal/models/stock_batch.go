@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// StockBatch is a received batch of a drug. Quantity is what is left.
+type StockBatch struct {
+	ID               uint            `gorm:"primaryKey" json:"id"`
+	DrugID           uint            `gorm:"uniqueIndex:idx_stock_batch" json:"drugId"`
+	Drug             Drug            `gorm:"foreignKey:DrugID" json:"drug,omitempty"`
+	BatchNumber      string          `gorm:"uniqueIndex:idx_stock_batch" json:"batchNumber"`
+	ExpiryDate       time.Time       `json:"expiryDate"`
+	ReceivedQuantity float64         `json:"receivedQuantity"`
+	Quantity         float64         `json:"quantity"`
+	CostPrice        decimal.Decimal `gorm:"type:numeric(19,4)" json:"costPrice"` // per unit
+	Supplier         string          `json:"supplier,omitempty"`
+	ReceivedAt       time.Time       `json:"receivedAt"`
+	CreatedAt        time.Time       `json:"createdAt"`
+	UpdatedAt        time.Time       `json:"updatedAt"`
+}
@@ -7,6 +7,6 @@ type User struct {
 	Name      string    `json:"name"`
 	Email     string    `gorm:"unique" json:"email"`
 	Password  string    `json:"-"`
-	Role      string    `json:"role"` // admin, doctor, receptionist, patient
+	Role      string    `json:"role"` // admin, doctor, receptionist, pharmacist, patient
 	CreatedAt time.Time `json:"createdAt"`
 }
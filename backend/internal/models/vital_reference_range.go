@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+type VitalReferenceRange struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	Parameter    string    `gorm:"index" json:"parameter"`
+	Sex          string    `json:"sex"` // Male, Female, or empty for any
+	MinAge       int       `json:"minAge"`
+	MaxAge       int       `json:"maxAge"` // inclusive, in years
+	Low          *float64  `json:"low,omitempty"`
+	High         *float64  `json:"high,omitempty"`
+	CriticalLow  *float64  `json:"criticalLow,omitempty"`
+	CriticalHigh *float64  `json:"criticalHigh,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+type VitalSign struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	PatientID   uint      `gorm:"index" json:"patientId"`
+	EncounterID *uint     `gorm:"index" json:"encounterId,omitempty"`
+	Parameter   string    `gorm:"index" json:"parameter"` // e.g., systolic_bp, pulse, temperature, bmi
+	Value       float64   `json:"value"`
+	Unit        string    `json:"unit"` // stored in the parameter's standard unit
+	Flag        string    `json:"flag"` // Normal, Low, High, CriticalLow, CriticalHigh, or empty without a reference range
+	RecordedAt  time.Time `json:"recordedAt"`
+	RecordedBy  uint      `json:"recordedBy"` // user who took the reading
+	CreatedAt   time.Time `json:"createdAt"`
+}
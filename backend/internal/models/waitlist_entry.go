@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+type WaitlistEntry struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	PatientID      uint       `json:"patientId"`
+	Patient        Patient    `gorm:"foreignKey:PatientID" json:"patient,omitempty"`
+	DoctorID       *uint      `json:"doctorId,omitempty"` // nil means any doctor with the specialization
+	Doctor         *Doctor    `gorm:"foreignKey:DoctorID" json:"doctor,omitempty"`
+	Specialization string     `json:"specialization"`
+	PreferredDays  string     `json:"preferredDays"` // e.g., "MO,WE,FR", empty for any day
+	EarliestDate   *time.Time `json:"earliestDate,omitempty"`
+	LatestDate     *time.Time `json:"latestDate,omitempty"`
+	Priority       int        `json:"priority"` // higher is offered first
+	Status         string     `json:"status"`   // Waiting, Offered, Booked
+	Notes          string     `json:"notes"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+}
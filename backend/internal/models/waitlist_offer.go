@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+type WaitlistOffer struct {
+	ID              uint          `gorm:"primaryKey" json:"id"`
+	WaitlistEntryID uint          `json:"waitlistEntryId"`
+	WaitlistEntry   WaitlistEntry `gorm:"foreignKey:WaitlistEntryID" json:"waitlistEntry,omitempty"`
+	PatientID       uint          `json:"patientId"`
+	Patient         Patient       `gorm:"foreignKey:PatientID" json:"patient,omitempty"`
+	DoctorID        uint          `json:"doctorId"`
+	Doctor          Doctor        `gorm:"foreignKey:DoctorID" json:"doctor,omitempty"`
+	Date            time.Time     `json:"date"`
+	Time            string        `json:"time"`
+	Status          string        `json:"status"` // Pending, Accepted, Declined, Expired
+	ExpiresAt       time.Time     `json:"expiresAt"`
+	AppointmentID   *uint         `json:"appointmentId,omitempty"`
+	CreatedAt       time.Time     `json:"createdAt"`
+	UpdatedAt       time.Time     `json:"updatedAt"`
+}
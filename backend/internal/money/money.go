@@ -0,0 +1,71 @@
+// Package money rounds exact decimal amounts by per-currency rules.
+package money
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// Rounding modes
+const (
+	HalfUp   = "HalfUp"   // halves away from zero
+	HalfEven = "HalfEven" // halves to the even neighbour (banker's rounding)
+	Down     = "Down"     // towards zero
+	Up       = "Up"       // away from zero
+)
+
+// Rule describes how amounts in one currency are rounded.
+type Rule struct {
+	Decimals int32  // minor unit digits, e.g. 2 for USD and 0 for JPY
+	Mode     string // one of the rounding modes, HalfUp when empty
+	// Increment is the smallest amount that can be charged, e.g. 0.05 where
+	// one and two cent coins are not used. Zero means one minor unit.
+	Increment decimal.Decimal
+}
+
+// Validate reports whether the rule can be applied.
+func (r Rule) Validate() error {
+	if r.Decimals < 0 || r.Decimals > 4 {
+		return fmt.Errorf("decimals must be between 0 and 4")
+	}
+	switch r.Mode {
+	case "", HalfUp, HalfEven, Down, Up:
+	default:
+		return fmt.Errorf("unknown rounding mode %q", r.Mode)
+	}
+	if r.Increment.IsNegative() {
+		return fmt.Errorf("rounding increment cannot be negative")
+	}
+	if !r.Increment.IsZero() && !r.Increment.Mod(decimal.New(1, -r.Decimals)).IsZero() {
+		return fmt.Errorf("rounding increment must be a multiple of the minor unit")
+	}
+	return nil
+}
+
+// Round rounds the amount to the rule's increment.
+func (r Rule) Round(amount decimal.Decimal) decimal.Decimal {
+	if !r.Increment.IsZero() {
+		steps := r.round(amount.Div(r.Increment), 0)
+		return steps.Mul(r.Increment).Round(r.Decimals)
+	}
+	return r.round(amount, r.Decimals)
+}
+
+// Format renders the amount with exactly the currency's minor unit digits.
+func (r Rule) Format(amount decimal.Decimal) string {
+	return r.Round(amount).StringFixed(r.Decimals)
+}
+
+func (r Rule) round(amount decimal.Decimal, places int32) decimal.Decimal {
+	switch r.Mode {
+	case HalfEven:
+		return amount.RoundBank(places)
+	case Down:
+		return amount.RoundDown(places)
+	case Up:
+		return amount.RoundUp(places)
+	default:
+		return amount.Round(places)
+	}
+}
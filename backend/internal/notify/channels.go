@@ -0,0 +1,229 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var httpClient = &http.Client{Timeout: 15 * time.Second}
+
+// EmailChannel sends plain text email through an SMTP server.
+type EmailChannel struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (e *EmailChannel) Name() string { return "email" }
+
+func (e *EmailChannel) Send(ctx context.Context, msg Message) error {
+	if msg.Email == "" {
+		return ErrNoRecipient
+	}
+	if e.Host == "" || e.From == "" {
+		return fmt.Errorf("smtp is not configured")
+	}
+
+	port := e.Port
+	if port == "" {
+		port = "587"
+	}
+
+	var auth smtp.Auth
+	if e.Username != "" {
+		auth = smtp.PlainAuth("", e.Username, e.Password, e.Host)
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "From: %s\r\n", e.From)
+	fmt.Fprintf(&body, "To: %s\r\n", msg.Email)
+	fmt.Fprintf(&body, "Subject: %s\r\n", msg.Subject)
+	body.WriteString("MIME-Version: 1.0\r\n")
+	body.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	body.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+
+	return e.sendMail(ctx, net.JoinHostPort(e.Host, port), auth, msg.Email, []byte(body.String()))
+}
+
+// sendMail is smtp.SendMail bounded by ctx: the connection is dialled with
+// the context and every read and write is held to its deadline, so a slow or
+// unresponsive server cannot stall delivery.
+func (e *EmailChannel) sendMail(ctx context.Context, addr string, auth smtp.Auth, to string, message []byte) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	// Unblock a pending read or write as soon as the context is cancelled
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	client, err := smtp.NewClient(conn, e.Host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: e.Host}); err != nil {
+			return err
+		}
+	}
+	if auth != nil {
+		if ok, _ := client.Extension("AUTH"); ok {
+			if err := client.Auth(auth); err != nil {
+				return err
+			}
+		}
+	}
+	if err := client.Mail(e.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// SMSChannel posts messages to an HTTP SMS gateway as
+// {"to": "...", "message": "..."} with a bearer API key.
+type SMSChannel struct {
+	URL    string
+	APIKey string
+}
+
+func (s *SMSChannel) Name() string { return "sms" }
+
+func (s *SMSChannel) Send(ctx context.Context, msg Message) error {
+	if msg.Phone == "" {
+		return ErrNoRecipient
+	}
+	if s.URL == "" {
+		return fmt.Errorf("sms gateway is not configured")
+	}
+
+	payload, err := json.Marshal(map[string]string{"to": msg.Phone, "message": msg.Body})
+	if err != nil {
+		return err
+	}
+
+	headers := map[string]string{}
+	if s.APIKey != "" {
+		headers["Authorization"] = "Bearer " + s.APIKey
+	}
+	return postJSON(ctx, s.URL, payload, headers)
+}
+
+// WebhookChannel posts the whole message as JSON, signed with an
+// HMAC-SHA256 of the body in the X-Signature header when a secret is set.
+type WebhookChannel struct {
+	URL    string
+	Secret string
+}
+
+func (w *WebhookChannel) Name() string { return "webhook" }
+
+func (w *WebhookChannel) Send(ctx context.Context, msg Message) error {
+	if w.URL == "" {
+		return fmt.Errorf("webhook url is not configured")
+	}
+
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	headers := map[string]string{}
+	if w.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(w.Secret))
+		mac.Write(payload)
+		headers["X-Signature"] = "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	return postJSON(ctx, w.URL, payload, headers)
+}
+
+// LogChannel writes messages to a local file, or to the server log when no
+// path is set. It is meant for development and testing.
+type LogChannel struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func (l *LogChannel) Name() string { return "log" }
+
+func (l *LogChannel) Send(ctx context.Context, msg Message) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	if l.Path == "" {
+		log.Println("notification:", string(payload))
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := os.OpenFile(l.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "%s %s\n", time.Now().Format(time.RFC3339), payload)
+	return err
+}
+
+func postJSON(ctx context.Context, url string, payload []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+// Package notify delivers patient notifications over pluggable channels.
+package notify
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strings"
+)
+
+// ErrNoRecipient is returned when a message has no address the channel can use.
+var ErrNoRecipient = errors.New("no recipient address for channel")
+
+type Message struct {
+	Kind          string `json:"kind"` // Confirmation, Cancellation, Reminder
+	AppointmentID uint   `json:"appointmentId"`
+	Email         string `json:"email,omitempty"`
+	Phone         string `json:"phone,omitempty"`
+	Subject       string `json:"subject"`
+	Body          string `json:"body"`
+}
+
+// Channel is a delivery mechanism such as email or SMS.
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, msg Message) error
+}
+
+// FromEnv builds the channels listed in NOTIFY_CHANNELS (e.g. "email,sms").
+// Only the local log channel is enabled when nothing is configured.
+func FromEnv() []Channel {
+	names := os.Getenv("NOTIFY_CHANNELS")
+	if names == "" {
+		names = "log"
+	}
+
+	var channels []Channel
+	for _, name := range strings.Split(names, ",") {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "email":
+			channels = append(channels, &EmailChannel{
+				Host:     os.Getenv("SMTP_HOST"),
+				Port:     os.Getenv("SMTP_PORT"),
+				Username: os.Getenv("SMTP_USER"),
+				Password: os.Getenv("SMTP_PASS"),
+				From:     os.Getenv("SMTP_FROM"),
+			})
+		case "sms":
+			channels = append(channels, &SMSChannel{
+				URL:    os.Getenv("SMS_GATEWAY_URL"),
+				APIKey: os.Getenv("SMS_GATEWAY_KEY"),
+			})
+		case "webhook":
+			channels = append(channels, &WebhookChannel{
+				URL:    os.Getenv("NOTIFY_WEBHOOK_URL"),
+				Secret: os.Getenv("NOTIFY_WEBHOOK_SECRET"),
+			})
+		case "log":
+			channels = append(channels, &LogChannel{Path: os.Getenv("NOTIFY_LOG_FILE")})
+		case "":
+		default:
+			log.Println("Unknown notification channel:", name)
+		}
+	}
+	return channels
+}
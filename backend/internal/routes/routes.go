@@ -12,6 +12,23 @@ func SetupRoutes(r *gin.Engine) {
 	r.POST("/register", controllers.Register)
 	r.POST("/login", controllers.Login)
 
+	// Calendar subscription feeds - authenticated by the token in the URL
+	r.GET("/calendar/feeds/:token", controllers.GetDoctorCalendarFeed)
+
+	// Lobby display screens - no patient details are exposed
+	r.GET("/queue/display", controllers.GetQueueDisplay)
+	r.GET("/queue/display/stream", controllers.StreamQueueDisplay)
+
+	// QR codes on printed documents link here
+	r.GET("/documents/verify", controllers.VerifyDocument)
+
+	// Payment provider callbacks - authenticated by the webhook signature
+	r.POST("/payment-gateway/webhook", controllers.PaymentWebhook)
+	if controllers.FakeCheckoutEnabled() {
+		r.GET("/payment-gateway/fake/checkout/:session", controllers.GetFakeCheckout)
+		r.POST("/payment-gateway/fake/checkout/:session", controllers.CompleteFakeCheckout)
+	}
+
 	// Protected routes - require authentication
 	auth := r.Group("/api")
 	auth.Use(middleware.AuthMiddleware())
@@ -27,6 +44,12 @@ func SetupRoutes(r *gin.Engine) {
 		auth.GET("/doctors/:id", controllers.GetDoctorByID)
 		auth.PUT("/doctors/:id", middleware.AdminOnly(), controllers.UpdateDoctor)
 		auth.DELETE("/doctors/:id", middleware.AdminOnly(), controllers.DeleteDoctor)
+		auth.POST("/doctors/:id/calendar-token", middleware.AdminOrDoctor(), controllers.RotateDoctorCalendarToken)
+		auth.POST("/doctors/:id/signature", middleware.AdminOrDoctor(), controllers.UploadDoctorSignature)
+		auth.GET("/letterheads", controllers.GetLetterheads)
+		auth.POST("/letterheads", middleware.AdminOnly(), controllers.CreateLetterhead)
+		auth.PUT("/letterheads/:id", middleware.AdminOnly(), controllers.UpdateLetterhead)
+		auth.DELETE("/letterheads/:id", middleware.AdminOnly(), controllers.DeleteLetterhead)
 
 		// Patient routes - Admin and Receptionist can manage
 		auth.POST("/patients", middleware.AdminOrReceptionist(), controllers.CreatePatient)
@@ -34,34 +57,195 @@ func SetupRoutes(r *gin.Engine) {
 		auth.GET("/patients/:id", controllers.GetPatientByID)
 		auth.PUT("/patients/:id", middleware.AdminOrReceptionist(), controllers.UpdatePatient)
 		auth.DELETE("/patients/:id", middleware.AdminOnly(), controllers.DeletePatient)
+		auth.GET("/patients/:id/notification-preferences", controllers.GetNotificationPreferences)
+		auth.PUT("/patients/:id/notification-preferences", middleware.AdminOrReceptionist(), controllers.UpdateNotificationPreferences)
+		auth.GET("/patients/:id/vitals/trends", controllers.GetVitalSignTrends)
+		auth.GET("/patients/:id/allergies", controllers.GetPatientAllergies)
+		auth.POST("/patients/:id/allergies", middleware.Staff(), controllers.CreatePatientAllergy)
+		auth.PUT("/patients/:id/allergies/:allergyId", middleware.AdminOrDoctor(), controllers.UpdatePatientAllergy)
+		auth.DELETE("/patients/:id/allergies/:allergyId", middleware.AdminOrDoctor(), controllers.DeletePatientAllergy)
+		auth.GET("/patients/:id/problems", controllers.GetPatientProblems)
+		auth.POST("/patients/:id/problems", middleware.AdminOrDoctor(), controllers.CreatePatientProblem)
+		auth.PUT("/patients/:id/problems/:problemId", middleware.AdminOrDoctor(), controllers.UpdatePatientProblem)
+		auth.DELETE("/patients/:id/problems/:problemId", middleware.AdminOrDoctor(), controllers.DeletePatientProblem)
 
 		// Appointment routes
 		auth.POST("/appointments", middleware.AdminOrReceptionist(), controllers.CreateAppointment)
 		auth.GET("/appointments", controllers.GetAppointments)
 		auth.GET("/appointments/:id", controllers.GetAppointmentByID)
+		auth.GET("/appointments/:id/ics", controllers.GetAppointmentICS)
+		auth.GET("/appointments/:id/notifications", controllers.GetAppointmentNotifications)
+		auth.POST("/appointments/:id/encounter", middleware.Staff(), controllers.StartAppointmentEncounter)
+		auth.POST("/notifications/:id/retry", middleware.AdminOrReceptionist(), controllers.RetryNotification)
 		auth.PUT("/appointments/:id", controllers.UpdateAppointment)
 		auth.DELETE("/appointments/:id", middleware.AdminOrReceptionist(), controllers.DeleteAppointment)
 
+		// Recurring appointment series - Admin and Receptionist can manage
+		auth.POST("/appointment-series", middleware.AdminOrReceptionist(), controllers.CreateAppointmentSeries)
+		auth.GET("/appointment-series", controllers.GetAppointmentSeries)
+		auth.GET("/appointment-series/:id", controllers.GetAppointmentSeriesByID)
+		auth.DELETE("/appointment-series/:id", middleware.AdminOrReceptionist(), controllers.CancelAppointmentSeries)
+		auth.PUT("/appointment-series/:id/appointments/:appointmentId", middleware.AdminOrReceptionist(), controllers.UpdateSeriesOccurrence)
+		auth.DELETE("/appointment-series/:id/appointments/:appointmentId", middleware.AdminOrReceptionist(), controllers.CancelSeriesOccurrence)
+
+		// Waitlist routes - Admin and Receptionist can manage
+		auth.POST("/waitlist", middleware.AdminOrReceptionist(), controllers.CreateWaitlistEntry)
+		auth.GET("/waitlist", controllers.GetWaitlist)
+		auth.GET("/waitlist/offers", controllers.GetWaitlistOffers)
+		auth.POST("/waitlist/offers/:id/accept", middleware.AdminOrReceptionist(), controllers.AcceptWaitlistOffer)
+		auth.POST("/waitlist/offers/:id/decline", middleware.AdminOrReceptionist(), controllers.DeclineWaitlistOffer)
+		auth.GET("/waitlist/:id", controllers.GetWaitlistEntryByID)
+		auth.PUT("/waitlist/:id", middleware.AdminOrReceptionist(), controllers.UpdateWaitlistEntry)
+		auth.DELETE("/waitlist/:id", middleware.AdminOrReceptionist(), controllers.DeleteWaitlistEntry)
+
+		// Walk-in queue routes - Receptionists issue tokens, staff call and serve them
+		auth.POST("/queue/tokens", middleware.AdminOrReceptionist(), controllers.IssueQueueToken)
+		auth.GET("/queue", middleware.Staff(), controllers.GetQueue)
+		auth.POST("/queue/next", middleware.Staff(), controllers.CallNextQueueToken)
+		auth.POST("/queue/tokens/:id/call", middleware.Staff(), controllers.CallQueueToken)
+		auth.POST("/queue/tokens/:id/serve", middleware.Staff(), controllers.ServeQueueToken)
+		auth.POST("/queue/tokens/:id/skip", middleware.Staff(), controllers.SkipQueueToken)
+
+		// Encounter routes - Staff open encounters, Doctor and Admin document them
+		auth.POST("/encounters", middleware.Staff(), controllers.CreateEncounter)
+		auth.GET("/encounters", controllers.GetEncounters)
+		auth.GET("/encounters/:id", controllers.GetEncounterByID)
+		auth.PUT("/encounters/:id", middleware.AdminOrDoctor(), controllers.UpdateEncounter)
+		auth.POST("/encounters/:id/close", middleware.AdminOrDoctor(), controllers.CloseEncounter)
+
+		// Vital signs routes - Staff record readings, Admin manages reference ranges
+		auth.POST("/vitals", middleware.Staff(), controllers.RecordVitalSigns)
+		auth.GET("/vitals", controllers.GetVitalSigns)
+		auth.DELETE("/vitals/:id", middleware.AdminOnly(), controllers.DeleteVitalSign)
+		auth.GET("/note-templates", controllers.GetNoteTemplates)
+		auth.GET("/note-templates/default", controllers.GetDefaultNoteTemplate)
+		auth.GET("/note-templates/:id", controllers.GetNoteTemplateByID)
+		auth.POST("/note-templates", middleware.AdminOnly(), controllers.CreateNoteTemplate)
+		auth.PUT("/note-templates/:id", middleware.AdminOnly(), controllers.UpdateNoteTemplate)
+		auth.DELETE("/note-templates/:id", middleware.AdminOnly(), controllers.DeleteNoteTemplate)
+		auth.GET("/vital-reference-ranges", controllers.GetVitalReferenceRanges)
+		auth.POST("/vital-reference-ranges", middleware.AdminOnly(), controllers.CreateVitalReferenceRange)
+		auth.PUT("/vital-reference-ranges/:id", middleware.AdminOnly(), controllers.UpdateVitalReferenceRange)
+		auth.DELETE("/vital-reference-ranges/:id", middleware.AdminOnly(), controllers.DeleteVitalReferenceRange)
+
 		// Medical Records routes - Doctor and Admin can manage
 		auth.POST("/medical-records", middleware.AdminOrDoctor(), controllers.CreateMedicalRecord)
 		auth.GET("/medical-records", controllers.GetMedicalRecords)
 		auth.GET("/medical-records/:id", controllers.GetMedicalRecordByID)
 		auth.PUT("/medical-records/:id", middleware.AdminOrDoctor(), controllers.UpdateMedicalRecord)
 		auth.DELETE("/medical-records/:id", middleware.AdminOnly(), controllers.DeleteMedicalRecord)
+		auth.POST("/medical-records/:id/sign", middleware.AdminOrDoctor(), controllers.SignMedicalRecord)
+		auth.POST("/medical-records/:id/addenda", middleware.AdminOrDoctor(), controllers.AddMedicalRecordAddendum)
+		auth.POST("/medical-records/:id/corrections", middleware.AdminOrDoctor(), controllers.CorrectMedicalRecord)
+		auth.GET("/medical-records/:id/history", controllers.GetMedicalRecordHistory)
+		auth.POST("/medical-records/:id/diagnoses", middleware.AdminOrDoctor(), controllers.AddRecordDiagnosis)
+		auth.PUT("/medical-records/:id/diagnoses/:diagnosisId", middleware.AdminOrDoctor(), controllers.UpdateRecordDiagnosis)
+		auth.DELETE("/medical-records/:id/diagnoses/:diagnosisId", middleware.AdminOrDoctor(), controllers.DeleteRecordDiagnosis)
+
+		// ICD-10 catalog routes - Admin imports, everyone can search
+		auth.POST("/icd10/import", middleware.AdminOnly(), controllers.ImportICD10Codes)
+		auth.GET("/icd10", controllers.SearchICD10Codes)
+		auth.GET("/icd10/:code", controllers.GetICD10Code)
+		auth.GET("/reports/diagnoses", middleware.AdminOrDoctor(), controllers.GetDiagnosisPrevalence)
 
 		// Prescription routes - Doctor and Admin can manage
 		auth.POST("/prescriptions", middleware.AdminOrDoctor(), controllers.CreatePrescription)
+		auth.POST("/prescriptions/check", middleware.AdminOrDoctor(), controllers.CheckPrescription)
 		auth.GET("/prescriptions", controllers.GetPrescriptions)
 		auth.GET("/prescriptions/:id", controllers.GetPrescriptionByID)
 		auth.PUT("/prescriptions/:id", middleware.AdminOrDoctor(), controllers.UpdatePrescription)
 		auth.DELETE("/prescriptions/:id", middleware.AdminOnly(), controllers.DeletePrescription)
 
+		// Drug formulary routes - Admin manages, everyone can search
+		auth.POST("/drugs/import", middleware.AdminOnly(), controllers.ImportDrugs)
+		auth.GET("/drugs", controllers.GetDrugs)
+		auth.GET("/drugs/:id", controllers.GetDrugByID)
+		auth.POST("/drugs", middleware.AdminOnly(), controllers.CreateDrug)
+		auth.PUT("/drugs/:id", middleware.AdminOnly(), controllers.UpdateDrug)
+		auth.DELETE("/drugs/:id", middleware.AdminOnly(), controllers.DeleteDrug)
+
+		// Pharmacy routes - Admin and Pharmacist can dispense and manage stock
+		auth.GET("/pharmacy/queue", middleware.AdminOrPharmacist(), controllers.GetPharmacyQueue)
+		auth.POST("/pharmacy/prescriptions/:id/dispense", middleware.AdminOrPharmacist(), controllers.DispensePrescription)
+		auth.GET("/pharmacy/prescriptions/:id/dispenses", controllers.GetPrescriptionDispenses)
+		auth.POST("/pharmacy/stock", middleware.AdminOrPharmacist(), controllers.ReceiveStock)
+		auth.GET("/pharmacy/stock", middleware.AdminOrPharmacist(), controllers.GetStockBatches)
+		auth.GET("/pharmacy/stock/levels", middleware.AdminOrPharmacist(), controllers.GetStockLevels)
+		auth.PUT("/pharmacy/stock/:id", middleware.AdminOrPharmacist(), controllers.AdjustStockBatch)
+		auth.GET("/pharmacy/reports/low-stock", middleware.AdminOrPharmacist(), controllers.GetLowStockReport)
+		auth.GET("/pharmacy/reports/near-expiry", middleware.AdminOrPharmacist(), controllers.GetNearExpiryReport)
+		auth.POST("/drug-interactions/import", middleware.AdminOnly(), controllers.ImportDrugInteractions)
+		auth.POST("/drug-interactions/allergen-classes/import", middleware.AdminOnly(), controllers.ImportAllergenClasses)
+		auth.GET("/drug-interactions", controllers.GetDrugInteractions)
+
+		// Service catalog routes - Admin only can change prices
+		auth.GET("/services", controllers.GetServices)
+		auth.GET("/services/:id", controllers.GetServiceByID)
+		auth.GET("/services/:id/price", controllers.GetServicePrice)
+		auth.POST("/services", middleware.AdminOnly(), controllers.CreateService)
+		auth.PUT("/services/:id", middleware.AdminOnly(), controllers.UpdateService)
+		auth.DELETE("/services/:id", middleware.AdminOnly(), controllers.DeleteService)
+		auth.POST("/services/:id/prices", middleware.AdminOnly(), controllers.AddServicePrice)
+		auth.DELETE("/services/:id/prices/:priceId", middleware.AdminOnly(), controllers.DeleteServicePrice)
+
+		// Currency routes - Admin only can change rounding rules
+		auth.GET("/currencies", controllers.GetCurrencies)
+		auth.POST("/currencies", middleware.AdminOnly(), controllers.CreateCurrency)
+		auth.PUT("/currencies/:id", middleware.AdminOnly(), controllers.UpdateCurrency)
+		auth.DELETE("/currencies/:id", middleware.AdminOnly(), controllers.DeleteCurrency)
+
 		// Bill routes - Admin and Receptionist can manage
 		auth.POST("/bills", middleware.AdminOrReceptionist(), controllers.CreateBill)
 		auth.GET("/bills", controllers.GetBills)
 		auth.GET("/bills/:id", controllers.GetBillByID)
 		auth.PUT("/bills/:id", middleware.AdminOrReceptionist(), controllers.UpdateBill)
 		auth.DELETE("/bills/:id", middleware.AdminOnly(), controllers.DeleteBill)
+		auth.GET("/bills/:id/payments", controllers.GetBillPayments)
+		auth.POST("/bills/:id/payments", middleware.AdminOrReceptionist(), controllers.RecordPayment)
+		auth.POST("/bills/:id/write-off", middleware.AdminOnly(), controllers.WriteOffBill)
+		auth.GET("/payments", middleware.AdminOrReceptionist(), controllers.GetPayments)
+		auth.GET("/payments/:id/receipt", controllers.GetPaymentReceipt)
+		auth.POST("/payments/:id/refunds", middleware.AdminOrReceptionist(), controllers.RefundPayment)
+		auth.POST("/payments/:id/reverse", middleware.AdminOnly(), controllers.ReversePayment)
+		auth.GET("/cashier-sessions", middleware.AdminOrReceptionist(), controllers.GetCashierSessions)
+		auth.POST("/cashier-sessions", middleware.AdminOrReceptionist(), controllers.OpenCashierSession)
+		auth.GET("/cashier-sessions/current", middleware.AdminOrReceptionist(), controllers.GetCurrentCashierSession)
+		auth.GET("/cashier-sessions/:id", middleware.AdminOrReceptionist(), controllers.GetCashierSessionByID)
+		auth.POST("/cashier-sessions/:id/close", middleware.AdminOrReceptionist(), controllers.CloseCashierSession)
+		auth.GET("/daily-closes", middleware.AdminOnly(), controllers.GetDailyCloses)
+		auth.POST("/daily-closes", middleware.AdminOnly(), controllers.CloseDay)
+		auth.GET("/daily-closes/:id", middleware.AdminOnly(), controllers.GetDailyCloseByID)
+		auth.POST("/bills/:id/checkout", middleware.AdminOrReceptionist(), controllers.CreateCheckout)
+		auth.GET("/bills/:id/checkouts", controllers.GetBillCheckouts)
+		auth.GET("/payment-gateway/reconciliations", middleware.AdminOnly(), controllers.GetReconciliations)
+		auth.POST("/payment-gateway/reconciliations", middleware.AdminOnly(), controllers.RunReconciliation)
+
+		// Financial reports - Admin only, ?format=csv or xlsx to download
+		auth.GET("/reports/revenue", middleware.AdminOnly(), controllers.GetRevenueReport)
+		auth.GET("/reports/receivables-aging", middleware.AdminOnly(), controllers.GetReceivablesAging)
+		auth.GET("/reports/collections", middleware.AdminOnly(), controllers.GetCollectionsReport)
+
+		// Insurance routes - Admin manages payers and plans, Admin and Receptionist handle claims
+		auth.GET("/insurers", controllers.GetInsurers)
+		auth.POST("/insurers", middleware.AdminOnly(), controllers.CreateInsurer)
+		auth.PUT("/insurers/:id", middleware.AdminOnly(), controllers.UpdateInsurer)
+		auth.DELETE("/insurers/:id", middleware.AdminOnly(), controllers.DeleteInsurer)
+		auth.POST("/insurers/:id/plans", middleware.AdminOnly(), controllers.CreateInsurancePlan)
+		auth.PUT("/insurance-plans/:id", middleware.AdminOnly(), controllers.UpdateInsurancePlan)
+		auth.DELETE("/insurance-plans/:id", middleware.AdminOnly(), controllers.DeleteInsurancePlan)
+		auth.GET("/patients/:id/policies", controllers.GetPatientPolicies)
+		auth.POST("/patients/:id/policies", middleware.AdminOrReceptionist(), controllers.CreatePatientPolicy)
+		auth.PUT("/patients/:id/policies/:policyId", middleware.AdminOrReceptionist(), controllers.UpdatePatientPolicy)
+		auth.DELETE("/patients/:id/policies/:policyId", middleware.AdminOrReceptionist(), controllers.DeletePatientPolicy)
+		auth.GET("/claims", middleware.AdminOrReceptionist(), controllers.GetClaims)
+		auth.GET("/claims/batches", middleware.AdminOrReceptionist(), controllers.GetClaimBatches)
+		auth.POST("/claims/batches", middleware.AdminOrReceptionist(), controllers.CreateClaimBatch)
+		auth.GET("/claims/batches/:id", middleware.AdminOrReceptionist(), controllers.GetClaimBatchByID)
+		auth.GET("/claims/:id", middleware.AdminOrReceptionist(), controllers.GetClaimByID)
+		auth.POST("/claims/:id/adjudication", middleware.AdminOrReceptionist(), controllers.AdjudicateClaim)
+		auth.POST("/claims/:id/resubmission", middleware.AdminOrReceptionist(), controllers.ResubmitClaim)
+		auth.GET("/remittances", middleware.AdminOrReceptionist(), controllers.GetRemittances)
+		auth.POST("/remittances", middleware.AdminOrReceptionist(), controllers.RecordRemittance)
 
 		// Room routes - Admin and Receptionist can manage
 		auth.POST("/rooms", middleware.AdminOnly(), controllers.CreateRoom)
@@ -69,6 +253,15 @@ func SetupRoutes(r *gin.Engine) {
 		auth.GET("/rooms/:id", controllers.GetRoomByID)
 		auth.PUT("/rooms/:id", middleware.AdminOrReceptionist(), controllers.UpdateRoom)
 		auth.POST("/rooms/:id/assign", middleware.AdminOrReceptionist(), controllers.AssignRoomToPatient)
+		auth.GET("/rooms/:id/stays", controllers.GetRoomStays)
 		auth.DELETE("/rooms/:id", middleware.AdminOnly(), controllers.DeleteRoom)
+
+		// Admission routes - Staff admit and transfer, Doctor and Admin discharge
+		auth.GET("/admissions", controllers.GetAdmissions)
+		auth.POST("/admissions", middleware.Staff(), controllers.AdmitPatient)
+		auth.GET("/admissions/:id", controllers.GetAdmissionByID)
+		auth.POST("/admissions/:id/transfer", middleware.Staff(), controllers.TransferPatient)
+		auth.POST("/admissions/:id/discharge", middleware.AdminOrDoctor(), controllers.DischargePatient)
+		auth.GET("/patients/:id/admissions", controllers.GetPatientAdmissions)
 	}
 }
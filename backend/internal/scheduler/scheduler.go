@@ -0,0 +1,40 @@
+// Package scheduler runs periodic background jobs.
+package scheduler
+
+import (
+	"log"
+	"time"
+)
+
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func()
+}
+
+// Start runs every job once right away, then on its own ticker until the
+// process exits. A job that panics is logged and retried on its next tick.
+func Start(jobs ...Job) {
+	for _, job := range jobs {
+		go loop(job)
+	}
+}
+
+func loop(job Job) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	runOnce(job)
+	for range ticker.C {
+		runOnce(job)
+	}
+}
+
+func runOnce(job Job) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Job %s panicked: %v", job.Name, r)
+		}
+	}()
+	job.Run()
+}
@@ -4,8 +4,12 @@ import (
 	"log"
 
 	"clinic-backend/internal/config"
+	"clinic-backend/internal/controllers"
+	"clinic-backend/internal/gateway"
 	"clinic-backend/internal/models"
+	"clinic-backend/internal/notify"
 	"clinic-backend/internal/routes"
+	"clinic-backend/internal/scheduler"
 
 	"time"
 
@@ -20,12 +24,90 @@ func main() {
 	config.DB.AutoMigrate(
 		&models.User{},
 		&models.Patient{},
+		&models.Allergy{},
+		&models.Problem{},
 		&models.Doctor{},
 		&models.Appointment{},
+		&models.AppointmentSeries{},
+		&models.WaitlistEntry{},
+		&models.WaitlistOffer{},
+		&models.QueueToken{},
+		&models.QueueCounter{},
+		&models.Notification{},
+		&models.NotificationPreference{},
+		&models.Encounter{},
+		&models.VitalSign{},
+		&models.VitalReferenceRange{},
+		&models.NoteTemplate{},
 		&models.MedicalRecord{},
+		&models.MedicalRecordVersion{},
+		&models.MedicalRecordAddendum{},
+		&models.ICD10Code{},
+		&models.RecordDiagnosis{},
+		&models.Drug{},
 		&models.Prescription{},
+		&models.PrescriptionItem{},
+		&models.PrescriptionAlert{},
+		&models.DrugInteraction{},
+		&models.AllergenClass{},
+		&models.StockBatch{},
+		&models.Dispense{},
+		&models.DispenseItem{},
+		&models.Currency{},
+		&models.Service{},
+		&models.ServicePrice{},
+		&models.InvoiceSequence{},
 		&models.Bill{},
+		&models.BillItem{},
+		&models.Payment{},
+		&models.CashierSession{},
+		&models.DailyClose{},
+		&models.DailyCloseLine{},
+		&models.CheckoutSession{},
+		&models.GatewayEvent{},
+		&models.GatewayReconciliation{},
+		&models.Insurer{},
+		&models.InsurancePlan{},
+		&models.PatientPolicy{},
+		&models.Claim{},
+		&models.ClaimBatch{},
+		&models.Remittance{},
+		&models.RemittanceLine{},
 		&models.Room{},
+		&models.RoomStay{},
+		&models.Admission{},
+		&models.Letterhead{},
+	)
+
+	// A bill may have several claims since claims can be resubmitted
+	if config.DB.Migrator().HasIndex(&models.Claim{}, "idx_claims_bill_id") {
+		config.DB.Migrator().DropIndex(&models.Claim{}, "idx_claims_bill_id")
+	}
+	// Invoice numbers are covered by a unique index now
+	if config.DB.Migrator().HasIndex(&models.Bill{}, "idx_bills_invoice_number") {
+		config.DB.Migrator().DropIndex(&models.Bill{}, "idx_bills_invoice_number")
+	}
+
+	controllers.SeedVitalReferenceRanges()
+	controllers.SeedNoteTemplates()
+	controllers.SeedMedicalRecordVersions()
+	controllers.SeedPrescriptionStatuses()
+	controllers.SeedCurrencies()
+	controllers.SeedPaymentLedger()
+	controllers.SeedBillShares()
+	controllers.SeedInvoiceNumbers()
+	controllers.SeedRoomStays()
+
+	// Background jobs
+	controllers.SetNotificationChannels(notify.FromEnv())
+	controllers.SetPaymentProvider(gateway.FromEnv())
+	scheduler.Start(
+		scheduler.Job{Name: "expire-waitlist-offers", Interval: time.Minute, Run: controllers.ExpireWaitlistOffers},
+		scheduler.Job{Name: "queue-appointment-reminders", Interval: time.Minute, Run: controllers.QueueAppointmentReminders},
+		scheduler.Job{Name: "dispatch-notifications", Interval: 30 * time.Second, Run: controllers.DispatchNotifications},
+		scheduler.Job{Name: "mark-overdue-bills", Interval: time.Hour, Run: controllers.MarkOverdueBills},
+		scheduler.Job{Name: "charge-room-stays", Interval: time.Hour, Run: controllers.ChargeRoomStays},
+		scheduler.Job{Name: "reconcile-online-payments", Interval: 24 * time.Hour, Run: controllers.ReconcilePayments},
 	)
 
 	r := gin.Default()